@@ -0,0 +1,227 @@
+package controllers
+
+import (
+	"context"
+	"golang-restaurant-management/helpers"
+	"golang-restaurant-management/models"
+	"golang-restaurant-management/repository"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	passwordResetTTL     = time.Hour
+	emailVerificationTTL = time.Hour * 48
+)
+
+type passwordResetRequest struct {
+	Email string `json:"email" validate:"required"`
+}
+
+type passwordResetConfirmation struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=6"`
+}
+
+type emailVerificationConfirmation struct {
+	Token string `json:"token" validate:"required"`
+}
+
+func RequestPasswordReset(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var body passwordResetRequest
+
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if validationErr := validate.Struct(body); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		// The answer is the same whether or not the email is known, so the
+		// endpoint cannot be used to discover accounts.
+		msg := "If the email is registered, a password reset link has been sent."
+
+		foundUser, err := repos.Users.GetByEmail(ctx, body.Email)
+
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{"message": msg})
+			return
+		}
+
+		token, err := helpers.IssueUserToken(repos, foundUser.User_id, helpers.PurposePasswordReset, passwordResetTTL)
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create the password reset token!"})
+			return
+		}
+
+		err = helpers.DefaultMailer.Send(helpers.MailMessage{
+			To:      *foundUser.Email,
+			Subject: "Reset your password",
+			Body:    "Use the following link within one hour to choose a new password:\n" + helpers.AppLink("/reset-password", token),
+		})
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send the password reset email!"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": msg})
+	}
+}
+
+func ConfirmPasswordReset(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var body passwordResetConfirmation
+
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if validationErr := validate.Struct(body); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		userId, err := helpers.ConsumeUserToken(repos, body.Token, helpers.PurposePasswordReset)
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The reset token is invalid or has expired!"})
+			return
+		}
+
+		foundUser, err := repos.Users.Get(ctx, userId)
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The reset token is invalid or has expired!"})
+			return
+		}
+
+		password := HashPassword(body.Password)
+		foundUser.Password = &password
+		foundUser.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		if err := repos.Users.Update(ctx, foundUser, "password"); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Password update failed!"})
+			return
+		}
+
+		if err := helpers.RevokeAllUserTokens(repos, userId); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke the user's tokens!"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Password has been reset. Please login again!"})
+	}
+}
+
+func RequestEmailVerification(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		foundUser, err := repos.Users.Get(ctx, c.GetString("uid"))
+
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User was not found!"})
+			return
+		}
+
+		if foundUser.Email_verified {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Email is already verified!"})
+			return
+		}
+
+		if err := sendVerificationEmail(repos, foundUser); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send the verification email!"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Verification email sent!"})
+	}
+}
+
+func ConfirmEmailVerification(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var body emailVerificationConfirmation
+
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if validationErr := validate.Struct(body); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		userId, err := helpers.ConsumeUserToken(repos, body.Token, helpers.PurposeEmailVerification)
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The verification token is invalid or has expired!"})
+			return
+		}
+
+		foundUser, err := repos.Users.Get(ctx, userId)
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The verification token is invalid or has expired!"})
+			return
+		}
+
+		foundUser.Email_verified = true
+		foundUser.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		if err := repos.Users.Update(ctx, foundUser, "email_verified"); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Email verification failed!"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Email verified!"})
+	}
+}
+
+func sendVerificationEmail(repos *repository.Repositories, user models.User) error {
+	token, err := helpers.IssueUserToken(repos, user.User_id, helpers.PurposeEmailVerification, emailVerificationTTL)
+
+	if err != nil {
+		return err
+	}
+
+	return helpers.DefaultMailer.Send(helpers.MailMessage{
+		To:      *user.Email,
+		Subject: "Verify your email address",
+		Body:    "Use the following link to verify your email address:\n" + helpers.AppLink("/verify-email", token),
+	})
+}
+
+// sendVerificationEmailInBackground is used right after signup, where a
+// mail failure must not fail the signup itself.
+func sendVerificationEmailInBackground(repos *repository.Repositories, user models.User) {
+	go func() {
+		if err := sendVerificationEmail(repos, user); err != nil {
+			log.Println("Failed to send the verification email:", err)
+		}
+	}()
+}
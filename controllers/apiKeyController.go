@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"context"
+	"golang-restaurant-management/helpers"
+	"golang-restaurant-management/models"
+	"golang-restaurant-management/repository"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func GetApiKeys(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		allApiKeys, err := repos.ApiKeys.List(ctx)
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while listing the API keys!"})
+			return
+		}
+
+		c.JSON(http.StatusOK, allApiKeys)
+	}
+}
+
+func CreateApiKey(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var apiKey models.ApiKey
+
+		if err := c.BindJSON(&apiKey); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if validationErr := validate.Struct(apiKey); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		if !helpers.IsValidRole(*apiKey.Role) || *apiKey.Role == helpers.RoleAdmin {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "API keys must carry a non-admin staff role!"})
+			return
+		}
+
+		if apiKey.Table_id != nil {
+			if _, err := repos.Tables.Get(ctx, *apiKey.Table_id); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Table was not found!"})
+				return
+			}
+		}
+
+		key, prefix, err := helpers.GenerateApiKey()
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate the API key!"})
+			return
+		}
+
+		apiKey.Prefix = prefix
+		apiKey.Key_hash = helpers.HashApiKey(key)
+		apiKey.Created_by = c.GetString("uid")
+		apiKey.Last_used_at = nil
+		apiKey.Revoked_at = nil
+
+		apiKey.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		apiKey.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		apiKey.ID = primitive.NewObjectID()
+		apiKey.Api_key_id = apiKey.ID.Hex()
+
+		if insertErr := repos.ApiKeys.Create(ctx, apiKey); insertErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "API key was not created!"})
+			return
+		}
+
+		// The plaintext key is only ever returned here.
+		c.JSON(http.StatusOK, gin.H{"api_key": key, "details": apiKey})
+	}
+}
+
+func RevokeApiKey(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		apiKeyId := c.Param("api_key_id")
+
+		Revoked_at, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		err := repos.ApiKeys.Revoke(ctx, apiKeyId, Revoked_at)
+
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Active API key was not found!"})
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke the API key!"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "API key revoked!"})
+	}
+}
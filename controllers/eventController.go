@@ -0,0 +1,199 @@
+package controllers
+
+import (
+	"context"
+	"golang-restaurant-management/helpers"
+	"golang-restaurant-management/models"
+	"golang-restaurant-management/repository"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// eventHeartbeat is how often an idle stream sends a ping, which keeps
+// proxies from closing the connection.
+const eventHeartbeat = 30 * time.Second
+
+// StreamEvents subscribes the caller to the comma separated ?topics= and
+// streams their events as server-sent events until the client disconnects
+// or its token expires. Every heartbeat checks again that the token or API
+// key has not been revoked and that the user is still active.
+// Every topic needs the permission that reading its data needs, and events
+// whose own data needs a permission the caller lacks are skipped; table- and
+// station-scoped API keys may only subscribe to their own table or station.
+func StreamEvents(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		var topics []string
+
+		for _, topic := range strings.Split(c.Query("topics"), ",") {
+			if topic = strings.TrimSpace(topic); topic != "" {
+				topics = append(topics, topic)
+			}
+		}
+
+		if len(topics) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "At least one topic is required!"})
+			return
+		}
+
+		for _, topic := range topics {
+			permission, ok := helpers.TopicPermission(topic)
+
+			if !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown topic " + topic + "!"})
+				return
+			}
+
+			if err := helpers.CheckPermission(c, permission); err != nil {
+				c.JSON(http.StatusForbidden, gin.H{"error": "You do not have the " + permission + " permission!"})
+				return
+			}
+
+			if !helpers.TopicInScope(topic, c.GetString("table_id"), c.GetString("station")) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "This device may only follow its own table or station!"})
+				return
+			}
+		}
+
+		sub := helpers.DefaultEventBus.Subscribe(topics)
+		defer helpers.DefaultEventBus.Unsubscribe(sub)
+
+		heartbeat := time.NewTicker(eventHeartbeat)
+		defer heartbeat.Stop()
+
+		var expired <-chan time.Time
+
+		if expiresAt, ok := c.Get("token_expires_at"); ok {
+			expiry := time.NewTimer(time.Until(expiresAt.(time.Time)))
+			defer expiry.Stop()
+
+			expired = expiry.C
+		}
+
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+
+		c.SSEvent("ready", gin.H{"topics": topics})
+		c.Writer.Flush()
+
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case event, ok := <-sub.Events:
+				if !ok {
+					return false
+				}
+
+				if helpers.CheckPermission(c, helpers.EventPermission(event.Type)) == nil {
+					c.SSEvent(event.Type, event)
+				}
+			case <-heartbeat.C:
+				if !streamAuthorized(repos, c) {
+					return false
+				}
+
+				c.SSEvent("ping", gin.H{"at": time.Now().Format(time.RFC3339)})
+			case <-expired:
+				return false
+			case <-c.Request.Context().Done():
+				return false
+			}
+
+			return true
+		})
+	}
+}
+
+// streamAuthorized reports whether the caller of a long-lived stream is
+// still allowed in: API keys must still be active, tokens must not have been
+// revoked and their user must not have been deactivated. Failed lookups end
+// the stream as well; the client reconnects through the usual checks.
+func streamAuthorized(repos *repository.Repositories, c *gin.Context) bool {
+	if c.GetString("auth_method") == "api_key" {
+		_, err := helpers.FindActiveApiKey(repos, c.GetHeader(helpers.ApiKeyHeader))
+
+		return err == nil
+	}
+
+	value, _ := c.Get("token_claims")
+	claims, ok := value.(*helpers.SignedDetails)
+
+	if !ok {
+		return false
+	}
+
+	if revoked, err := helpers.IsTokenRevoked(repos, claims); err != nil || revoked {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	user, err := repos.Users.Get(ctx, claims.Uid)
+
+	return err == nil && user.Deactivated_at == nil
+}
+
+// orderTopics lists the topics that follow an order: all orders, the order
+// itself and its table.
+func orderTopics(order models.Order) []string {
+	topics := []string{helpers.TopicOrders, helpers.OrderTopic(order.Order_id)}
+
+	if order.Table_id != nil {
+		topics = append(topics, helpers.TableTopic(*order.Table_id))
+	}
+
+	return topics
+}
+
+func publishOrder(eventType string, order models.Order) {
+	helpers.DefaultEventBus.Publish(eventType, order, orderTopics(order)...)
+}
+
+// publishOrderChange announces a change to something that belongs to an
+// order, like one of its items. The order is loaded to reach the
+// subscribers of its table; if it is gone the event still goes out on the
+// order topics.
+func publishOrderChange(ctx context.Context, repos *repository.Repositories, eventType string, orderId string, data interface{}) {
+	helpers.DefaultEventBus.Publish(eventType, data, orderTopics(eventOrder(ctx, repos, orderId))...)
+}
+
+// eventOrder loads the order an event is about, falling back to an order
+// that only carries its id.
+func eventOrder(ctx context.Context, repos *repository.Repositories, orderId string) models.Order {
+	order, err := repos.Orders.Get(ctx, orderId)
+
+	if err != nil {
+		return models.Order{Order_id: orderId}
+	}
+
+	return order
+}
+
+// publishTicket announces a ticket to its station as well as to everyone
+// following its order.
+func publishTicket(eventType string, ticket models.Ticket) {
+	topics := orderTopics(models.Order{Order_id: ticket.Order_id, Table_id: ticket.Table_id})
+
+	helpers.DefaultEventBus.Publish(eventType, ticket, append(topics, helpers.StationTopic(ticket.Station))...)
+}
+
+func publishTable(eventType string, tableId string, data interface{}) {
+	helpers.DefaultEventBus.Publish(eventType, data, helpers.TopicTables, helpers.TableTopic(tableId))
+}
+
+// publishInvoice announces an invoice to everyone following invoices and,
+// when orderId is set, to the followers of its order.
+func publishInvoice(eventType string, orderId string, data interface{}) {
+	topics := []string{helpers.TopicInvoices}
+
+	if orderId != "" {
+		topics = append(topics, helpers.OrderTopic(orderId))
+	}
+
+	helpers.DefaultEventBus.Publish(eventType, data, topics...)
+}
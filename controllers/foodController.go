@@ -2,10 +2,8 @@ package controllers
 
 import (
 	"context"
-	"golang-restaurant-management/database"
-	"golang-restaurant-management/helpers"
 	"golang-restaurant-management/models"
-	"log"
+	"golang-restaurant-management/repository"
 	"math"
 	"net/http"
 	"strconv"
@@ -13,103 +11,61 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var foodCollection *mongo.Collection = database.OpenCollection(database.Client, "food")
-
 var validate = validator.New()
 
-func GetFoods() gin.HandlerFunc {
+func GetFoods(repos *repository.Repositories) gin.HandlerFunc {
 
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		recordPerPage, err := strconv.Atoi(c.Query("recordPerPage"))
-		if err != nil || recordPerPage < 1 {
-			recordPerPage = 10
-		}
-
-		page, err := strconv.Atoi(c.Query("page"))
-		if err != nil || page < 1 {
-			page = 1
-		}
-
-		startIndex := (page - 1) * recordPerPage
-		startIndex, err = strconv.Atoi(c.Query("startIndex"))
-
-		matchStage := bson.D{
-			{Key: "$match", Value: bson.D{{}}},
-		}
-
-		groupStage := bson.D{
-			{Key: "$group", Value: bson.D{{Key: "_id", Value: bson.D{{Key: "_id", Value: "null"}}},
-				{Key: "total_count", Value: bson.D{{Key: "$sum", Value: 1}}},
-				{Key: "data", Value: bson.D{{Key: "$push", Value: "$$ROOT"}}},
-			}}}
-
-		projectStage := bson.D{
-			{Key: "$project", Value: bson.D{
-				{Key: "_id", Value: 0},
-				{Key: "total_count", Value: 1},
-				{Key: "food_items", Value: bson.D{
-					{Key: "$slice", Value: bson.A{"$data", startIndex, recordPerPage}},
-				}},
-			}},
-		}
+		page := pageFromQuery(c)
 
-		result, err := foodCollection.Aggregate(ctx, mongo.Pipeline{
-			matchStage, groupStage, projectStage,
-		})
+		foods, total, err := repos.Foods.List(ctx, page)
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while listing food items!"})
+			return
 		}
 
-		var allFoods []bson.M
-
-		if err := result.All(ctx, &allFoods); err != nil {
-			log.Fatal(err)
-		}
-
-		c.JSON(http.StatusOK, allFoods[0])
-
+		c.JSON(http.StatusOK, gin.H{"total_count": total, "food_items": foods})
 	}
 }
 
-func GetFood() gin.HandlerFunc {
+func GetFood(repos *repository.Repositories) gin.HandlerFunc {
 
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
 		foodId := c.Param("food_id")
-		var food models.Food
-		err := foodCollection.FindOne(ctx, bson.M{"food_id": foodId}).Decode(&food)
+
+		food, err := repos.Foods.Get(ctx, foodId)
+
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Food item was not found!"})
+			return
+		}
+
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while fetching the food item!"})
+			return
 		}
+
 		c.JSON(http.StatusOK, food)
 	}
 }
 
-func CreateFood() gin.HandlerFunc {
+func CreateFood(repos *repository.Repositories) gin.HandlerFunc {
 
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		if err := helpers.CheckUserType(c, "ADMIN"); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-
 		var food models.Food
-		var menu models.Menu
 
 		if err := c.BindJSON(&food); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -121,11 +77,8 @@ func CreateFood() gin.HandlerFunc {
 			return
 		}
 
-		err := menuCollection.FindOne(ctx, bson.M{"menu_id": food.Menu_id}).Decode(&menu)
-
-		if err != nil {
-			msg := "Menu not found!"
-			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		if _, err := repos.Menus.Get(ctx, *food.Menu_id); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Menu not found!"})
 			return
 		}
 
@@ -137,122 +90,153 @@ func CreateFood() gin.HandlerFunc {
 
 		var num = toFixed(*food.Price, 2)
 		food.Price = &num
+		food.Variants = roundedVariants(food.Variants)
 
-		result, insertErr := foodCollection.InsertOne(ctx, food)
-		if insertErr != nil {
-			msg := "Food item was not created!"
-			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		if insertErr := repos.Foods.Create(ctx, food); insertErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Food item was not created!"})
 			return
 		}
 
-		c.JSON(http.StatusOK, result)
+		c.JSON(http.StatusOK, food)
 	}
 }
 
-func UpdateFood() gin.HandlerFunc {
+func UpdateFood(repos *repository.Repositories) gin.HandlerFunc {
 
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		if err := helpers.CheckUserType(c, "ADMIN"); err != nil {
+		var body models.Food
+
+		foodId := c.Param("food_id")
+
+		if err := c.BindJSON(&body); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		var menu models.Menu
-		var food models.Food
-
-		var foodId = c.Param("food_id")
+		food, err := repos.Foods.Get(ctx, foodId)
 
-		if err := c.BindJSON(&food); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Food item was not found!"})
 			return
 		}
 
-		var updateObj primitive.D
-
-		if food.Name != nil {
-			updateObj = append(updateObj, bson.E{Key: "name", Value: food.Name})
+		if body.Name != nil {
+			food.Name = body.Name
 		}
 
-		if food.Price != nil {
-			updateObj = append(updateObj, bson.E{Key: "price", Value: food.Price})
+		if body.Price != nil {
+			var num = toFixed(*body.Price, 2)
+			food.Price = &num
+		}
 
+		if body.Food_image != nil {
+			food.Food_image = body.Food_image
 		}
 
-		if food.Food_image != nil {
-			updateObj = append(updateObj, bson.E{Key: "food_image", Value: food.Food_image})
+		// Variants are replaced as a whole; an empty list removes them.
+		if body.Variants != nil {
+			if validationErr := validate.Var(body.Variants, "unique=Name,dive"); validationErr != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+				return
+			}
 
+			food.Variants = roundedVariants(body.Variants)
 		}
 
-		if food.Menu_id != nil {
-			err := menuCollection.FindOne(ctx, bson.M{"menu_id": food.Menu_id}).Decode(&menu)
-			defer cancel()
-
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Menu was not found!"})
+		// Modifier groups are replaced as a whole as well.
+		if body.Modifier_groups != nil {
+			if validationErr := validate.Var(body.Modifier_groups, "unique=Name,dive"); validationErr != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
 				return
 			}
-			updateObj = append(updateObj, bson.E{Key: "menu_id", Value: food.Menu_id})
 
+			food.Modifier_groups = body.Modifier_groups
 		}
 
-		food.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: food.Updated_at})
-
-		upsert := true
+		if body.Station != nil {
+			if validationErr := validate.Var(*body.Station, "min=1,max=30"); validationErr != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+				return
+			}
 
-		opt := options.UpdateOptions{
-			Upsert: &upsert,
+			food.Station = body.Station
 		}
 
-		filter := bson.M{"food_id": foodId}
+		if body.Menu_id != nil {
+			if _, err := repos.Menus.Get(ctx, *body.Menu_id); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Menu was not found!"})
+				return
+			}
 
-		result, err := foodCollection.UpdateOne(
-			ctx,
-			filter,
-			bson.D{
-				{Key: "$set", Value: updateObj},
-			},
-			&opt,
-		)
+			food.Menu_id = body.Menu_id
+		}
 
-		if err != nil {
-			msg := "Food item update failed!"
-			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		food.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		if err := repos.Foods.Update(ctx, food); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Food item update failed!"})
 			return
 		}
 
-		c.JSON(http.StatusOK, result)
-
+		c.JSON(http.StatusOK, food)
 	}
 }
 
-func DeleteFood() gin.HandlerFunc {
+func DeleteFood(repos *repository.Repositories) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		if err := helpers.CheckUserType(c, "ADMIN"); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-
 		foodId := c.Param("food_id")
 
-		filter := bson.M{"food_id": foodId}
+		err := repos.Foods.Delete(ctx, foodId)
 
-		res, err := foodCollection.DeleteOne(ctx, filter)
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Food item was not found!"})
+			return
+		}
 
 		if err != nil {
-			log.Fatal(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete the food item!"})
 			return
 		}
 
-		c.JSON(http.StatusOK, res)
+		c.JSON(http.StatusOK, gin.H{"message": "Food item deleted!"})
+	}
+}
+
+func roundedVariants(variants []models.FoodVariant) []models.FoodVariant {
+	for i := range variants {
+		var num = toFixed(*variants[i].Price, 2)
+		variants[i].Price = &num
+	}
+
+	return variants
+}
+
+// pageFromQuery reads the recordPerPage, page and startIndex query
+// parameters shared by the paginated listings.
+func pageFromQuery(c *gin.Context) repository.Page {
+	recordPerPage, err := strconv.Atoi(c.Query("recordPerPage"))
+	if err != nil || recordPerPage < 1 {
+		recordPerPage = 10
+	}
 
+	page, err := strconv.Atoi(c.Query("page"))
+	if err != nil || page < 1 {
+		page = 1
 	}
+
+	startIndex := (page - 1) * recordPerPage
+
+	if index, err := strconv.Atoi(c.Query("startIndex")); err == nil && index >= 0 {
+		startIndex = index
+	}
+
+	return repository.Page{Offset: startIndex, Limit: recordPerPage}
 }
 
 func round(num float64) int {
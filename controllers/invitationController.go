@@ -0,0 +1,223 @@
+package controllers
+
+import (
+	"context"
+	"golang-restaurant-management/helpers"
+	"golang-restaurant-management/models"
+	"golang-restaurant-management/repository"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const invitationTTL = time.Hour * 24 * 7
+
+type invitationAcceptance struct {
+	Token      string `json:"token" validate:"required"`
+	First_name string `json:"first_name" validate:"required,min=2,max=100"`
+	Last_name  string `json:"last_name" validate:"required,min=2,max=100"`
+	Password   string `json:"password" validate:"required,min=6"`
+	Phone      string `json:"phone" validate:"required"`
+}
+
+func GetInvitations(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		allInvitations, err := repos.Invitations.ListPending(ctx, time.Now())
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while listing the invitations!"})
+			return
+		}
+
+		c.JSON(http.StatusOK, allInvitations)
+	}
+}
+
+// CreateInvitation emails a single-use link that lets the invitee create an
+// account with the role chosen here. Earlier pending invitations for the
+// same address are revoked so only the latest link works.
+func CreateInvitation(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var invitation models.Invitation
+
+		if err := c.BindJSON(&invitation); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if validationErr := validate.Struct(invitation); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		// Managers may invite staff, but only admins may hand out the
+		// admin role.
+		if *invitation.User_type == helpers.RoleAdmin {
+			if err := helpers.CheckPermission(c, helpers.PermUserManage); err != nil {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can invite admins!"})
+				return
+			}
+		}
+
+		taken, err := repos.Users.EmailOrPhoneTaken(ctx, *invitation.Email, "", "")
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while checking user existence!"})
+			return
+		}
+
+		if taken {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A user with this email already exists!"})
+			return
+		}
+
+		token, err := helpers.GenerateOpaqueToken()
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create the invitation token!"})
+			return
+		}
+
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		if err := repos.Invitations.RevokePendingForEmail(ctx, *invitation.Email, now); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke earlier invitations!"})
+			return
+		}
+
+		invitation.Token_hash = helpers.HashOpaqueToken(token)
+		invitation.Invited_by = c.GetString("uid")
+		invitation.Expires_at = now.Add(invitationTTL)
+		invitation.Accepted_at = nil
+		invitation.Revoked_at = nil
+		invitation.User_id = nil
+		invitation.Created_at = now
+		invitation.Updated_at = now
+
+		invitation.ID = primitive.NewObjectID()
+		invitation.Invitation_id = invitation.ID.Hex()
+
+		if insertErr := repos.Invitations.Create(ctx, invitation); insertErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invitation was not created!"})
+			return
+		}
+
+		err = helpers.DefaultMailer.Send(helpers.MailMessage{
+			To:      *invitation.Email,
+			Subject: "You have been invited",
+			Body:    "Use the following link within seven days to create your account:\n" + helpers.AppLink("/accept-invitation", token),
+		})
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send the invitation email!"})
+			return
+		}
+
+		c.JSON(http.StatusOK, invitation)
+	}
+}
+
+func RevokeInvitation(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		Revoked_at, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		err := repos.Invitations.Revoke(ctx, c.Param("invitation_id"), Revoked_at)
+
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pending invitation was not found!"})
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke the invitation!"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Invitation revoked!"})
+	}
+}
+
+// AcceptInvitation creates the invited account. The email address counts as
+// verified because the invitee received the link there.
+func AcceptInvitation(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var body invitationAcceptance
+
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if validationErr := validate.Struct(body); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		invitation, err := helpers.FindPendingInvitation(repos, body.Token)
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The invitation is invalid or has expired!"})
+			return
+		}
+
+		taken, err := repos.Users.EmailOrPhoneTaken(ctx, *invitation.Email, body.Phone, "")
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while checking user existence!"})
+			return
+		}
+
+		if taken {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Email or phone already exists!"})
+			return
+		}
+
+		var user models.User
+
+		user.ID = primitive.NewObjectID()
+		user.User_id = user.ID.Hex()
+
+		if err := helpers.AcceptInvitation(repos, invitation.Invitation_id, user.User_id); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The invitation is invalid or has expired!"})
+			return
+		}
+
+		password := HashPassword(body.Password)
+
+		user.First_name = &body.First_name
+		user.Last_name = &body.Last_name
+		user.Password = &password
+		user.Email = invitation.Email
+		user.Email_verified = true
+		user.Phone = &body.Phone
+		user.User_type = invitation.User_type
+
+		user.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		user.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		if insertErr := repos.Users.Create(ctx, user); insertErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "User was not created!"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Invitation accepted!", "user_id": user.User_id})
+	}
+}
@@ -2,18 +2,14 @@ package controllers
 
 import (
 	"context"
-	"golang-restaurant-management/database"
 	"golang-restaurant-management/helpers"
 	"golang-restaurant-management/models"
-	"log"
+	"golang-restaurant-management/repository"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type InvoiceViewFormat struct {
@@ -27,36 +23,28 @@ type InvoiceViewFormat struct {
 	Order_details    interface{}
 }
 
-var invoiceCollection *mongo.Collection = database.OpenCollection(database.Client, "invoice")
-
-func GetInvoices() gin.HandlerFunc {
+func GetInvoices(repos *repository.Repositories) gin.HandlerFunc {
 
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		if err := helpers.CheckUserType(c, "ADMIN"); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
+		allInvoices, err := repos.Invoices.List(ctx)
+
+		if err == nil {
+			allInvoices, err = invoicesInScope(ctx, c, repos, allInvoices)
 		}
 
-		res, err := invoiceCollection.Find(ctx, bson.M{})
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while listing the invoice items!"})
 			return
 		}
 
-		var allInvoices []bson.M
-
-		if err = res.All(ctx, &allInvoices); err != nil {
-			log.Fatal(err)
-		}
-
 		c.JSON(http.StatusOK, allInvoices)
 	}
 }
 
-func GetInvoice() gin.HandlerFunc {
+func GetInvoice(repos *repository.Repositories) gin.HandlerFunc {
 
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
@@ -64,17 +52,30 @@ func GetInvoice() gin.HandlerFunc {
 
 		invoiceId := c.Param("invoice_id")
 
-		var invoice models.Invoice
+		invoice, err := repos.Invoices.Get(ctx, invoiceId)
 
-		err := invoiceCollection.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&invoice)
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invoice was not found!"})
+			return
+		}
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error ocurred while listing the invoice item!"})
+			return
+		}
+
+		if !invoiceInScope(ctx, c, repos, invoice.Order_id) {
+			return
 		}
 
 		var invoiceView InvoiceViewFormat
 
-		allOrderItems, _ := ItemsByOrder(invoice.Order_id)
+		summary, err := ItemsByOrder(ctx, repos, invoice.Order_id)
+
+		if err != nil && err != repository.ErrNotFound {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error ocurred while listing the invoice order!"})
+			return
+		}
 
 		invoiceView.Order_id = invoice.Order_id
 		invoiceView.Payment_due_date = invoice.Payment_due_date
@@ -89,38 +90,49 @@ func GetInvoice() gin.HandlerFunc {
 
 		invoiceView.Payment_status = invoice.Payment_status
 
-		invoiceView.Payment_due = allOrderItems[0]["payment_due"]
-		invoiceView.Table_number = allOrderItems[0]["table_number"]
-		invoiceView.Order_details = allOrderItems[0]["order_details"]
+		invoiceView.Payment_due = summary.Payment_due
+		invoiceView.Table_number = summary.Table_number
+		invoiceView.Order_details = summary.Order_items
 
 		c.JSON(http.StatusOK, invoiceView)
 
 	}
 }
 
-func CreateInvoice() gin.HandlerFunc {
+func CreateInvoice(repos *repository.Repositories) gin.HandlerFunc {
 
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
 		var invoice models.Invoice
-		var order models.Order
 
 		if err := c.BindJSON(&invoice); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
+		if invoice.Payment_status != nil && *invoice.Payment_status == "PAID" {
+			if err := helpers.CheckPermission(c, helpers.PermInvoicePay); err != nil {
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
 		if invoice.Order_id == "" {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Order Id is a required field!"})
 			return
 		}
 
-		err := orderCollection.FindOne(ctx, bson.M{"order_id": invoice.Order_id}).Decode(&order)
+		order, err := repos.Orders.Get(ctx, invoice.Order_id)
 
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Order was not found!"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Order was not found!"})
+			return
+		}
+
+		if !orderTableInScope(c, order.Table_id) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This device may only bill orders of its own table!"})
 			return
 		}
 
@@ -144,86 +156,142 @@ func CreateInvoice() gin.HandlerFunc {
 			return
 		}
 
-		res, insertErr := invoiceCollection.InsertOne(ctx, invoice)
-
-		if insertErr != nil {
+		if insertErr := repos.Invoices.Create(ctx, invoice); insertErr != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invoice was not created in database!"})
 			return
 		}
 
-		c.JSON(http.StatusOK, res)
+		publishInvoice(helpers.EventInvoiceCreated, invoice.Order_id, invoice)
+
+		c.JSON(http.StatusOK, invoice)
 	}
 }
 
-func UpdateInvoice() gin.HandlerFunc {
+func UpdateInvoice(repos *repository.Repositories) gin.HandlerFunc {
 
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		var invoice models.Invoice
+		var body models.Invoice
 
 		invoiceId := c.Param("invoice_id")
 
-		if err := c.BindJSON(&invoice); err != nil {
+		if err := c.BindJSON(&body); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		var updateObj primitive.D
-
-		filter := bson.M{"invoice_id": invoiceId}
+		if body.Payment_status != nil && *body.Payment_status == "PAID" {
+			if err := helpers.CheckPermission(c, helpers.PermInvoicePay); err != nil {
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+				return
+			}
+		}
 
-		upsert := true
+		invoice, err := repos.Invoices.Get(ctx, invoiceId)
 
-		if invoice.Payment_method != nil {
-			updateObj = append(updateObj, bson.E{Key: "payment_method", Value: invoice.Payment_method})
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invoice was not found!"})
+			return
 		}
 
-		if invoice.Payment_status != nil {
-			updateObj = append(updateObj, bson.E{Key: "payment_status", Value: invoice.Payment_status})
+		if !invoiceInScope(ctx, c, repos, invoice.Order_id) {
+			return
 		}
 
-		invoice.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: invoice.Updated_at})
-
-		opt := options.UpdateOptions{
-			Upsert: &upsert,
+		if body.Payment_method != nil {
+			invoice.Payment_method = body.Payment_method
 		}
 
-		status := "PENDING"
+		if body.Payment_status != nil {
+			invoice.Payment_status = body.Payment_status
+		}
 
-		if invoice.Payment_status == nil {
-			invoice.Payment_status = &status
+		if validationErr := validate.Struct(invoice); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
 		}
 
-		res, err := invoiceCollection.UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: updateObj}}, &opt)
+		invoice.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 
-		if err != nil {
+		if err := repos.Invoices.Update(ctx, invoice); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invoice update failed!"})
+			return
 		}
 
-		c.JSON(http.StatusOK, res)
+		publishInvoice(helpers.EventInvoiceUpdated, invoice.Order_id, invoice)
+
+		c.JSON(http.StatusOK, invoice)
 	}
 }
 
-func DeleteInvoice() gin.HandlerFunc {
+func DeleteInvoice(repos *repository.Repositories) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
 		invoiceId := c.Param("invoice_id")
 
-		filter := bson.M{"invoice_id": invoiceId}
+		invoice, err := repos.Invoices.Get(ctx, invoiceId)
+
+		if err == nil && !invoiceInScope(ctx, c, repos, invoice.Order_id) {
+			return
+		}
+
+		if err == nil {
+			err = repos.Invoices.Delete(ctx, invoiceId)
+		}
 
-		res, err := invoiceCollection.DeleteOne(ctx, filter)
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invoice was not found!"})
+			return
+		}
 
 		if err != nil {
-			log.Fatal(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete the invoice!"})
 			return
 		}
 
-		c.JSON(http.StatusOK, res)
+		publishInvoice(helpers.EventInvoiceDeleted, "", gin.H{"invoice_id": invoiceId})
 
+		c.JSON(http.StatusOK, gin.H{"message": "Invoice deleted!"})
 	}
 }
+
+// invoiceInScope checks that the caller may work on the invoices of the
+// order. Otherwise it writes the error response and returns false.
+func invoiceInScope(ctx context.Context, c *gin.Context, repos *repository.Repositories, orderId string) bool {
+	inScope, err := orderIdInScope(ctx, c, repos, orderId)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while loading the order!"})
+		return false
+	}
+
+	if !inScope {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This device may only see invoices of its own table!"})
+		return false
+	}
+
+	return true
+}
+
+// invoicesInScope keeps the invoices of orders the caller may see.
+func invoicesInScope(ctx context.Context, c *gin.Context, repos *repository.Repositories, invoices []models.Invoice) ([]models.Invoice, error) {
+	visibleOrders, err := visibleOrderIds(ctx, c, repos)
+
+	if err != nil || visibleOrders == nil {
+		return invoices, err
+	}
+
+	visible := []models.Invoice{}
+
+	for _, invoice := range invoices {
+		if visibleOrders[invoice.Order_id] {
+			visible = append(visible, invoice)
+		}
+	}
+
+	return visible, nil
+}
@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"context"
+	"golang-restaurant-management/helpers"
+	"golang-restaurant-management/models"
+	"golang-restaurant-management/repository"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type itemAdjustmentRequest struct {
+	Reason_code string           `json:"reason_code" validate:"required"`
+	Note        *string          `json:"note" validate:"omitempty,max=200"`
+	Approval    *managerApproval `json:"approval"`
+}
+
+// managerApproval is a manager confirming an adjustment on someone else's
+// terminal with their PIN.
+type managerApproval struct {
+	User_id string `json:"user_id" validate:"required"`
+	Pin     string `json:"pin" validate:"required,numeric,min=4,max=6"`
+}
+
+// VoidOrderItem takes an item that has not reached the kitchen off the
+// bill. The item stays on the order with its adjustment recorded.
+func VoidOrderItem(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		adjustOrderItem(c, repos, models.AdjustmentVoid)
+	}
+}
+
+// CompOrderItem gives away an item the kitchen already prepared. The item
+// stays on the order, priced at zero, with its adjustment recorded.
+func CompOrderItem(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		adjustOrderItem(c, repos, models.AdjustmentComp)
+	}
+}
+
+func adjustOrderItem(c *gin.Context, repos *repository.Repositories, adjustmentType string) {
+	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var body itemAdjustmentRequest
+
+	if err := c.BindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if validationErr := validate.Struct(body); validationErr != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+		return
+	}
+
+	if !helpers.IsValidReasonCode(body.Reason_code) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reason code!"})
+		return
+	}
+
+	orderItem, err := repos.Orders.GetItem(ctx, c.Param("orderItem_id"))
+
+	if err == repository.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order item not found"})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while loading the order item!"})
+		return
+	}
+
+	order, err := repos.Orders.Get(ctx, orderItem.Order_id)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while loading the order!"})
+		return
+	}
+
+	if !orderTableInScope(c, order.Table_id) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This device may only change orders of its own table!"})
+		return
+	}
+
+	if orderItem.Adjustment != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "The item was already voided or comped!"})
+		return
+	}
+
+	switch order.CurrentStatus() {
+	case models.OrderStatusOpen, models.OrderStatusSentToKitchen, models.OrderStatusServed:
+	default:
+		c.JSON(http.StatusConflict, gin.H{"error": "The items of a " + order.CurrentStatus() + " order can no longer be changed!"})
+		return
+	}
+
+	unsent := orderItem.Prep_status == "" || orderItem.Prep_status == models.PrepStatusHeld
+
+	if adjustmentType == models.AdjustmentVoid && !unsent {
+		c.JSON(http.StatusConflict, gin.H{"error": "Items that reached the kitchen can only be comped!"})
+		return
+	}
+
+	if adjustmentType == models.AdjustmentComp && unsent {
+		c.JSON(http.StatusConflict, gin.H{"error": "Items that have not reached the kitchen are voided, not comped!"})
+		return
+	}
+
+	approvedBy, status, msg := approvingManager(ctx, c, repos, body.Approval)
+
+	if status != http.StatusOK {
+		c.JSON(status, gin.H{"error": msg})
+		return
+	}
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	zero := 0.0
+	prepStatus := orderItem.Prep_status
+
+	orderItem.Adjustment = &models.ItemAdjustment{
+		Type:         adjustmentType,
+		Reason_code:  body.Reason_code,
+		Note:         body.Note,
+		Unit_price:   orderItem.Unit_price,
+		Requested_by: c.GetString("uid"),
+		Approved_by:  approvedBy,
+		Adjusted_at:  now,
+	}
+	orderItem.Unit_price = &zero
+	orderItem.Updated_at = now
+
+	if adjustmentType == models.AdjustmentVoid {
+		orderItem.Prep_status = models.PrepStatusVoided
+	}
+
+	err = repos.Orders.AdjustItem(ctx, orderItem, prepStatus)
+
+	if err == repository.ErrNotFound {
+		c.JSON(http.StatusConflict, gin.H{"error": "The item was changed in the meantime, please reload it!"})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update the order item!"})
+		return
+	}
+
+	log.Printf("Order item %s of order %s: %s by %s, approved by %s: %s", orderItem.Order_item_id, orderItem.Order_id, adjustmentType, c.GetString("uid"), approvedBy, body.Reason_code)
+
+	helpers.DefaultEventBus.Publish(helpers.EventOrderItemUpdated, orderItem, orderTopics(order)...)
+
+	c.JSON(http.StatusOK, orderItem)
+}
+
+// approvingManager returns who approves an adjustment. Callers holding the
+// item:void permission approve their own; everyone else needs a manager to
+// confirm with their PIN.
+func approvingManager(ctx context.Context, c *gin.Context, repos *repository.Repositories, approval *managerApproval) (string, int, string) {
+	if approval == nil {
+		if err := helpers.CheckPermission(c, helpers.PermItemVoid); err != nil {
+			return "", http.StatusForbidden, "Voids and comps need a manager's approval!"
+		}
+
+		return c.GetString("uid"), http.StatusOK, ""
+	}
+
+	manager, status, msg := verifyPin(ctx, c, repos, approval.User_id, approval.Pin)
+
+	if status != http.StatusOK {
+		return "", status, msg
+	}
+
+	if manager.User_type == nil || !helpers.HasPermission(*manager.User_type, helpers.PermItemVoid) {
+		return "", http.StatusForbidden, "The approving user may not approve voids and comps!"
+	}
+
+	return manager.User_id, http.StatusOK, ""
+}
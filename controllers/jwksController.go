@@ -0,0 +1,18 @@
+package controllers
+
+import (
+	"golang-restaurant-management/helpers"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetJwks publishes the public keys other services need to verify our
+// tokens without sharing any secret.
+func GetJwks() gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, helpers.JWKS())
+	}
+}
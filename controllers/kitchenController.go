@@ -0,0 +1,499 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"golang-restaurant-management/helpers"
+	"golang-restaurant-management/models"
+	"golang-restaurant-management/repository"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GetTickets lists the tickets a station still has to work on, oldest
+// first. ?station= and ?status= narrow the list; station-scoped API keys
+// only ever see their own station.
+func GetTickets(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		station := c.Query("station")
+
+		if scoped := c.GetString("station"); scoped != "" {
+			if station != "" && station != scoped {
+				c.JSON(http.StatusForbidden, gin.H{"error": "This device may only see the tickets of its own station!"})
+				return
+			}
+
+			station = scoped
+		}
+
+		statuses := []string{models.PrepStatusQueued, models.PrepStatusCooking}
+
+		if status := c.Query("status"); status != "" {
+			if !helpers.IsValidTicketStatus(status) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticket status!"})
+				return
+			}
+
+			statuses = []string{status}
+		}
+
+		allTickets, err := repos.Tickets.List(ctx, station, statuses)
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while listing the tickets!"})
+			return
+		}
+
+		c.JSON(http.StatusOK, allTickets)
+	}
+}
+
+func GetTicket(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		ticket, err := repos.Tickets.Get(ctx, c.Param("ticket_id"))
+
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Ticket was not found!"})
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while loading the ticket!"})
+			return
+		}
+
+		if !ticketInScope(c, ticket) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This device may only see the tickets of its own station!"})
+			return
+		}
+
+		c.JSON(http.StatusOK, ticket)
+	}
+}
+
+// StartTicket marks a queued ticket and its items as cooking.
+func StartTicket(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		moveTicket(c, repos, []string{models.PrepStatusQueued}, models.PrepStatusCooking)
+	}
+}
+
+// BumpTicket marks a ticket and its items as ready to be served. Tickets
+// may be bumped straight from the queue.
+func BumpTicket(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		moveTicket(c, repos, []string{models.PrepStatusQueued, models.PrepStatusCooking}, models.PrepStatusReady)
+	}
+}
+
+// RecallTicket brings a bumped ticket back onto the station, e.g. when a
+// dish was sent back.
+func RecallTicket(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		moveTicket(c, repos, []string{models.PrepStatusReady}, models.PrepStatusCooking)
+	}
+}
+
+func moveTicket(c *gin.Context, repos *repository.Repositories, from []string, to string) {
+	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	ticket, err := repos.Tickets.Get(ctx, c.Param("ticket_id"))
+
+	if err == repository.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ticket was not found!"})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while loading the ticket!"})
+		return
+	}
+
+	if !ticketInScope(c, ticket) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This device may only work on the tickets of its own station!"})
+		return
+	}
+
+	current := ticket.Status
+	allowed := false
+
+	for _, status := range from {
+		allowed = allowed || status == current
+	}
+
+	if !allowed {
+		c.JSON(http.StatusConflict, gin.H{"error": "A ticket cannot move from " + current + " to " + to + "!"})
+		return
+	}
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+	ticket.Status = to
+	ticket.Updated_at = now
+
+	switch to {
+	case models.PrepStatusCooking:
+		if ticket.Started_at == nil {
+			ticket.Started_at = &now
+		}
+
+		ticket.Ready_at = nil
+	case models.PrepStatusReady:
+		ticket.Ready_at = &now
+	}
+
+	err = repos.Tickets.Update(ctx, ticket, current)
+
+	if err == repository.ErrNotFound {
+		c.JSON(http.StatusConflict, gin.H{"error": "The ticket was changed in the meantime, please reload it!"})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ticket update failed!"})
+		return
+	}
+
+	if err := repos.Orders.SetItemsPrepStatus(ctx, ticket.ItemIds(), to); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update the order items!"})
+		return
+	}
+
+	publishTicket(helpers.EventTicketUpdated, ticket)
+
+	c.JSON(http.StatusOK, ticket)
+}
+
+// ticketInScope reports whether the caller may work on the ticket.
+// Station-scoped API keys are limited to their own station.
+func ticketInScope(c *gin.Context, ticket models.Ticket) bool {
+	scoped := c.GetString("station")
+
+	return scoped == "" || ticket.Station == scoped
+}
+
+// FireCourse releases the held items of one course of an order to the
+// kitchen stations. Only orders that are already in the kitchen can fire a
+// course; before that, held items are released by taking them off hold.
+func FireCourse(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		course, err := strconv.Atoi(c.Param("course"))
+
+		if err != nil || course < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course!"})
+			return
+		}
+
+		order, err := repos.Orders.Get(ctx, c.Param("order_id"))
+
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order was not found!"})
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while loading the order!"})
+			return
+		}
+
+		if !orderTableInScope(c, order.Table_id) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This device may only change orders of its own table!"})
+			return
+		}
+
+		if order.CurrentStatus() != models.OrderStatusSentToKitchen {
+			c.JSON(http.StatusConflict, gin.H{"error": "Only orders in the kitchen can fire a course!"})
+			return
+		}
+
+		items, err := repos.Orders.ListItemsByOrder(ctx, order.Order_id)
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while listing the order items!"})
+			return
+		}
+
+		var held []models.OrderItem
+
+		for _, item := range items {
+			if item.Prep_status == models.PrepStatusHeld && item.CourseNumber() == course {
+				held = append(held, item)
+			}
+		}
+
+		tickets, err := sendTickets(ctx, repos, order, held, models.PrepStatusHeld)
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update the kitchen tickets!"})
+			return
+		}
+
+		// Another request may have fired the course in the meantime.
+		if len(tickets) == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "The course has no held items!"})
+			return
+		}
+
+		for _, ticket := range tickets {
+			publishTicket(helpers.EventTicketCreated, ticket)
+		}
+
+		c.JSON(http.StatusOK, tickets)
+	}
+}
+
+// prepareKitchen checks that the kitchen allows the order to move to
+// status. For orders sent to the kitchen it returns every item that is
+// neither held nor sent yet.
+func prepareKitchen(ctx context.Context, repos *repository.Repositories, order models.Order, status string) ([]models.OrderItem, int, string) {
+	if status != models.OrderStatusSentToKitchen && status != models.OrderStatusServed {
+		return nil, http.StatusOK, ""
+	}
+
+	items, err := repos.Orders.ListItemsByOrder(ctx, order.Order_id)
+
+	if err != nil {
+		return nil, http.StatusInternalServerError, "Error occured while listing the order items!"
+	}
+
+	var unsent []models.OrderItem
+
+	active := 0
+
+	for _, item := range items {
+		if item.Prep_status == models.PrepStatusVoided {
+			continue
+		}
+
+		active++
+
+		if status == models.OrderStatusServed && item.Prep_status == models.PrepStatusHeld {
+			return nil, http.StatusConflict, "The order still has held items, fire their course first!"
+		}
+
+		if item.Prep_status == "" {
+			unsent = append(unsent, item)
+		}
+	}
+
+	if status == models.OrderStatusServed {
+		return nil, http.StatusOK, ""
+	}
+
+	if active == 0 {
+		return nil, http.StatusBadRequest, "An order without items cannot be sent to the kitchen!"
+	}
+
+	return unsent, http.StatusOK, ""
+}
+
+// kitchenTickets splits order items into one ticket per station that
+// prepares them. Tickets come in the order their station first appears
+// among the items.
+func kitchenTickets(ctx context.Context, repos *repository.Repositories, order models.Order, items []models.OrderItem) ([]models.Ticket, error) {
+	var tableNumber *int
+
+	if order.Table_id != nil {
+		if table, err := repos.Tables.Get(ctx, *order.Table_id); err == nil {
+			tableNumber = table.Table_number
+		}
+	}
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+	foods := map[string]models.Food{}
+	menus := map[string]*models.Menu{}
+	byStation := map[string]int{}
+	tickets := []models.Ticket{}
+
+	for _, item := range items {
+		var ticketItem = models.TicketItem{
+			Order_item_id: item.Order_item_id,
+			Course:        item.CourseNumber(),
+			Variant:       item.Variant,
+			Quantity:      item.Count(),
+			Modifiers:     item.Modifiers,
+			Notes:         item.Notes,
+		}
+
+		station := helpers.DefaultStation
+
+		if item.Food_id != nil {
+			food, err := orderItemFood(ctx, repos, foods, *item.Food_id)
+
+			if err != nil && err != repository.ErrNotFound {
+				return nil, err
+			}
+
+			if err == nil {
+				menu, err := foodMenu(ctx, repos, menus, food)
+
+				if err != nil {
+					return nil, err
+				}
+
+				ticketItem.Food_name = food.Name
+				station = helpers.FoodStation(food, menu)
+			}
+		}
+
+		index, found := byStation[station]
+
+		if !found {
+			ticket := models.Ticket{
+				ID:           primitive.NewObjectID(),
+				Order_id:     order.Order_id,
+				Table_id:     order.Table_id,
+				Table_number: tableNumber,
+				Station:      station,
+				Status:       models.PrepStatusQueued,
+				Created_at:   now,
+				Updated_at:   now,
+			}
+			ticket.Ticket_id = ticket.ID.Hex()
+
+			index = len(tickets)
+			byStation[station] = index
+			tickets = append(tickets, ticket)
+		}
+
+		tickets[index].Items = append(tickets[index].Items, ticketItem)
+	}
+
+	return tickets, nil
+}
+
+// foodMenu loads the menu of a food, caching it for the other items of the
+// same order. Foods whose menu is gone yield a nil menu.
+func foodMenu(ctx context.Context, repos *repository.Repositories, menus map[string]*models.Menu, food models.Food) (*models.Menu, error) {
+	if food.Menu_id == nil {
+		return nil, nil
+	}
+
+	if menu, ok := menus[*food.Menu_id]; ok {
+		return menu, nil
+	}
+
+	found, err := repos.Menus.Get(ctx, *food.Menu_id)
+
+	if err != nil && err != repository.ErrNotFound {
+		return nil, err
+	}
+
+	var menu *models.Menu
+
+	if err == nil {
+		menu = &found
+	}
+
+	menus[*food.Menu_id] = menu
+
+	return menu, nil
+}
+
+// sendTickets queues those of the items that are still in status from and
+// stores the kitchen tickets for them. Items another request has sent in
+// the meantime are skipped, so an item never ends up on two tickets. If the
+// tickets cannot be stored the items are put back, so the caller can undo
+// its own changes and the request can be retried.
+func sendTickets(ctx context.Context, repos *repository.Repositories, order models.Order, items []models.OrderItem, from string) ([]models.Ticket, error) {
+	if len(items) == 0 {
+		return nil, nil
+	}
+
+	var ids []string
+
+	for _, item := range items {
+		ids = append(ids, item.Order_item_id)
+	}
+
+	moved, err := repos.Orders.MoveItemsPrepStatus(ctx, ids, from, models.PrepStatusQueued)
+
+	var queued []models.OrderItem
+
+	for _, item := range items {
+		if slices.Contains(moved, item.Order_item_id) {
+			queued = append(queued, item)
+		}
+	}
+
+	var tickets []models.Ticket
+
+	if err == nil && len(queued) > 0 {
+		tickets, err = kitchenTickets(ctx, repos, order, queued)
+	}
+
+	if err == nil {
+		err = repos.Tickets.CreateMany(ctx, tickets)
+	}
+
+	if err != nil {
+		var ticketIds []string
+
+		for _, ticket := range tickets {
+			ticketIds = append(ticketIds, ticket.Ticket_id)
+		}
+
+		_, restoreErr := repos.Orders.MoveItemsPrepStatus(ctx, moved, models.PrepStatusQueued, from)
+
+		return nil, errors.Join(err, repos.Tickets.DeleteMany(ctx, ticketIds), restoreErr)
+	}
+
+	return tickets, nil
+}
+
+// updateKitchen brings the kitchen in line with the new status of an
+// order: sent orders get tickets for their unsent items, which it returns,
+// served orders mark their items as served and cancelled orders disappear
+// from the stations.
+func updateKitchen(ctx context.Context, repos *repository.Repositories, order models.Order, unsent []models.OrderItem) ([]models.Ticket, error) {
+	switch order.Status {
+	case models.OrderStatusSentToKitchen:
+		return sendTickets(ctx, repos, order, unsent, "")
+	case models.OrderStatusServed:
+		items, err := repos.Orders.ListItemsByOrder(ctx, order.Order_id)
+
+		if err != nil {
+			return nil, err
+		}
+
+		ids := []string{}
+
+		for _, item := range items {
+			if item.Prep_status != models.PrepStatusVoided {
+				ids = append(ids, item.Order_item_id)
+			}
+		}
+
+		if len(ids) == 0 {
+			return nil, nil
+		}
+
+		return nil, repos.Orders.SetItemsPrepStatus(ctx, ids, models.PrepStatusServed)
+	case models.OrderStatusCancelled:
+		return nil, repos.Tickets.DeleteByOrder(ctx, order.Order_id)
+	}
+
+	return nil, nil
+}
@@ -2,73 +2,62 @@ package controllers
 
 import (
 	"context"
-	"fmt"
-	"golang-restaurant-management/database"
-	"golang-restaurant-management/helpers"
 	"golang-restaurant-management/models"
-	"log"
+	"golang-restaurant-management/repository"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var menuCollection *mongo.Collection = database.OpenCollection(database.Client, "menu")
-
-func GetMenus() gin.HandlerFunc {
+func GetMenus(repos *repository.Repositories) gin.HandlerFunc {
 
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
-		result, err := menuCollection.Find(context.TODO(), bson.M{})
+
+		allMenus, err := repos.Menus.List(ctx)
+
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while listing the menu items!"})
+			return
 		}
 
-		var allMenus []bson.M
-
-		if err = result.All(ctx, &allMenus); err != nil {
-			log.Fatal(err)
-		}
 		c.JSON(http.StatusOK, allMenus)
 	}
 }
 
-func GetMenu() gin.HandlerFunc {
+func GetMenu(repos *repository.Repositories) gin.HandlerFunc {
 
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
 		menuId := c.Param("menu_id")
-		fmt.Println("Searching for menu_id", menuId)
 
-		var menu models.Menu
+		menu, err := repos.Menus.Get(ctx, menuId)
+
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Menu not found!"})
+			return
+		}
 
-		err := menuCollection.FindOne(ctx, bson.M{"menu_id": menuId}).Decode(&menu)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Menu not found!"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while fetching the menu!"})
 			return
 		}
+
 		c.JSON(http.StatusOK, menu)
 	}
 }
 
-func CreateMenu() gin.HandlerFunc {
+func CreateMenu(repos *repository.Repositories) gin.HandlerFunc {
 
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		if err := helpers.CheckUserType(c, "ADMIN"); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-
 		var menu models.Menu
 
 		if err := c.BindJSON(&menu); err != nil {
@@ -88,103 +77,92 @@ func CreateMenu() gin.HandlerFunc {
 		menu.ID = primitive.NewObjectID()
 		menu.Menu_id = menu.ID.Hex()
 
-		result, insertErr := menuCollection.InsertOne(ctx, menu)
-		if insertErr != nil {
+		if insertErr := repos.Menus.Create(ctx, menu); insertErr != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Menu was was not created!"})
 			return
 		}
-		c.JSON(http.StatusOK, result)
+
+		c.JSON(http.StatusOK, menu)
 	}
 }
 
-func UpdateMenu() gin.HandlerFunc {
+func UpdateMenu(repos *repository.Repositories) gin.HandlerFunc {
 
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		if err := helpers.CheckUserType(c, "ADMIN"); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-
-		var menu models.Menu
+		var body models.Menu
 
-		if err := c.BindJSON(&menu); err != nil {
+		if err := c.BindJSON(&body); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
 		menuId := c.Param("menu_id")
-		filter := bson.M{"menu_id": menuId}
 
-		var updateObj primitive.D
+		menu, err := repos.Menus.Get(ctx, menuId)
 
-		if menu.Name != "" {
-			updateObj = append(updateObj, bson.E{Key: "name", Value: menu.Name})
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Menu not found!"})
+			return
 		}
 
-		if menu.Category != "" {
-			updateObj = append(updateObj, bson.E{Key: "category", Value: menu.Category})
+		if body.Name != "" {
+			menu.Name = body.Name
 		}
 
-		if !menu.Start_Date.IsZero() {
-			updateObj = append(updateObj, bson.E{Key: "start_date", Value: menu.Start_Date})
+		if body.Category != "" {
+			menu.Category = body.Category
 		}
 
-		if !menu.End_Date.IsZero() {
-			updateObj = append(updateObj, bson.E{Key: "end_date", Value: menu.End_Date})
+		if !body.Start_Date.IsZero() {
+			menu.Start_Date = body.Start_Date
 		}
 
-		menu.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: menu.Updated_at})
+		if !body.End_Date.IsZero() {
+			menu.End_Date = body.End_Date
+		}
 
-		upsert := true
+		if body.Station != nil {
+			if validationErr := validate.Var(*body.Station, "min=1,max=30"); validationErr != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+				return
+			}
 
-		opt := options.UpdateOptions{
-			Upsert: &upsert,
+			menu.Station = body.Station
 		}
 
-		result, err := menuCollection.UpdateOne(ctx, filter, bson.D{
-			{Key: "$set", Value: updateObj},
-		}, &opt)
+		menu.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 
-		if err != nil {
-			msg := "Menu update failed!"
-			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		if err := repos.Menus.Update(ctx, menu); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Menu update failed!"})
 			return
 		}
-		c.JSON(http.StatusOK, result)
 
+		c.JSON(http.StatusOK, menu)
 	}
 }
 
-func DeleteMenu() gin.HandlerFunc {
+func DeleteMenu(repos *repository.Repositories) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		if err := helpers.CheckUserType(c, "ADMIN"); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-
 		menuId := c.Param("menu_id")
 
-		filter := bson.M{"menu_id": menuId}
+		err := repos.Menus.Delete(ctx, menuId)
 
-		res, err := menuCollection.DeleteOne(ctx, filter)
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Menu not found!"})
+			return
+		}
 
 		if err != nil {
-			log.Fatal(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete the menu!"})
 			return
 		}
 
-		c.JSON(http.StatusOK, res)
-
+		c.JSON(http.StatusOK, gin.H{"message": "Menu deleted!"})
 	}
 }
-
-// func inTimeSpan(start, end, check time.Time) bool {
-// 	return start.After(time.Now()) && end.After(start)
-// }
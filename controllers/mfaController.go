@@ -0,0 +1,480 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"golang-restaurant-management/helpers"
+	"golang-restaurant-management/models"
+	"golang-restaurant-management/repository"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	errInvalidMfaCode    = errors.New("the MFA code is invalid")
+	errMfaAlreadyEnabled = errors.New("MFA is already enabled")
+	errMfaNotStarted     = errors.New("MFA enrollment has not been started")
+)
+
+type mfaCodeRequest struct {
+	Code          string `json:"code"`
+	Recovery_code string `json:"recovery_code"`
+}
+
+// mfaDisableRequest proves both factors: a session alone must not be enough
+// to turn off the second one.
+type mfaDisableRequest struct {
+	Current_password string `json:"current_password" validate:"required"`
+	Code             string `json:"code"`
+	Recovery_code    string `json:"recovery_code"`
+}
+
+type mfaLoginRequest struct {
+	Mfa_token     string `json:"mfa_token" validate:"required"`
+	Code          string `json:"code"`
+	Recovery_code string `json:"recovery_code"`
+}
+
+func EnrollMfa(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		foundUser, err := repos.Users.Get(ctx, c.GetString("uid"))
+
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User was not found!"})
+			return
+		}
+
+		startMfaEnrollment(c, repos, foundUser)
+	}
+}
+
+func ActivateMfa(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var body mfaCodeRequest
+
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		foundUser, err := repos.Users.Get(ctx, c.GetString("uid"))
+
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User was not found!"})
+			return
+		}
+
+		recoveryCodes, err := activateMfa(repos, &foundUser, body.Code)
+
+		if err != nil {
+			respondMfaError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "MFA enabled!", "recovery_codes": recoveryCodes})
+	}
+}
+
+func DisableMfa(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var body mfaDisableRequest
+
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if validationErr := validate.Struct(body); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		foundUser, err := repos.Users.Get(ctx, c.GetString("uid"))
+
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User was not found!"})
+			return
+		}
+
+		if passwordIsValid, msg := VerifyPassword(body.Current_password, *foundUser.Password); !passwordIsValid {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": msg})
+			return
+		}
+
+		if helpers.MfaRequiredForRole(*foundUser.User_type) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "MFA is mandatory for this account!"})
+			return
+		}
+
+		if !foundUser.Mfa_enabled {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "MFA is not enabled!"})
+			return
+		}
+
+		if err := verifySecondFactor(repos, &foundUser, body.Code, body.Recovery_code); err != nil {
+			respondMfaError(c, err)
+			return
+		}
+
+		foundUser.Mfa_enabled = false
+		foundUser.Mfa_secret = nil
+		foundUser.Mfa_recovery_codes = nil
+		foundUser.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		if err := repos.Users.Update(ctx, foundUser, "mfa_enabled", "mfa_secret", "mfa_recovery_codes"); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable MFA!"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "MFA disabled!"})
+	}
+}
+
+// EnrollMfaAtLogin lets accounts that are required to use MFA but have not
+// set it up yet enroll with the token returned by Login.
+func EnrollMfaAtLogin(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var body mfaLoginRequest
+
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if validationErr := validate.Struct(body); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		claims, msg := helpers.ValidateMfaPendingToken(body.Mfa_token)
+
+		if msg != "" || !claims.Mfa_enroll {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "The MFA token is invalid!"})
+			return
+		}
+
+		revoked, err := helpers.IsTokenRevoked(repos, claims)
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check the token status!"})
+			return
+		}
+
+		if revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "The MFA token is invalid!"})
+			return
+		}
+
+		foundUser, err := repos.Users.Get(ctx, claims.Uid)
+
+		if err != nil || foundUser.Deactivated_at != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "The MFA token is invalid!"})
+			return
+		}
+
+		// Otherwise whoever holds the token could swap the secret the user
+		// is about to confirm for one of their own.
+		if foundUser.Mfa_secret != nil && foundUser.Mfa_enrollment_jti != nil && *foundUser.Mfa_enrollment_jti == claims.ID {
+			c.JSON(http.StatusConflict, gin.H{"error": "MFA enrollment was already started with this token!"})
+			return
+		}
+
+		foundUser.Mfa_enrollment_jti = &claims.ID
+
+		startMfaEnrollment(c, repos, foundUser)
+	}
+}
+
+// LoginMfa completes a login with a TOTP or recovery code. For accounts
+// that enrolled during this login the code also activates MFA, and the
+// recovery codes are returned alongside the user. Each MFA token completes
+// at most one login.
+func LoginMfa(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var body mfaLoginRequest
+
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if validationErr := validate.Struct(body); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		claims, msg := helpers.ValidateMfaPendingToken(body.Mfa_token)
+
+		if msg != "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "The MFA token is invalid!"})
+			return
+		}
+
+		revoked, err := helpers.IsTokenRevoked(repos, claims)
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check the token status!"})
+			return
+		}
+
+		if revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "The MFA token is invalid!"})
+			return
+		}
+
+		attemptKey := "mfa:" + claims.Uid
+
+		wait, err := helpers.LoginRetryAfter(repos, attemptKey)
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check login attempts!"})
+			return
+		}
+
+		if wait > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed MFA attempts. Please try again later!"})
+			return
+		}
+
+		foundUser, err := repos.Users.Get(ctx, claims.Uid)
+
+		if err != nil || foundUser.Deactivated_at != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "The MFA token is invalid!"})
+			return
+		}
+
+		if claims.Mfa_enroll && !foundUser.Mfa_enabled {
+			recoveryCodes, err := activateMfa(repos, &foundUser, body.Code)
+
+			if err != nil {
+				respondMfaFailure(c, repos, attemptKey, err)
+				return
+			}
+
+			if !consumeMfaToken(c, repos, claims) || !issueLoginTokens(c, repos, &foundUser) {
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"user": foundUser, "recovery_codes": recoveryCodes})
+			return
+		}
+
+		if !foundUser.Mfa_enabled {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "The MFA token is invalid!"})
+			return
+		}
+
+		if err := verifySecondFactor(repos, &foundUser, body.Code, body.Recovery_code); err != nil {
+			respondMfaFailure(c, repos, attemptKey, err)
+			return
+		}
+
+		if err := helpers.ResetLoginFailures(repos, attemptKey); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset login attempts!"})
+			return
+		}
+
+		if !consumeMfaToken(c, repos, claims) {
+			return
+		}
+
+		completeLogin(c, repos, foundUser)
+	}
+}
+
+// respondMfaFailure answers a failed MFA login. Wrong codes count towards
+// the lockout of the account's MFA attempts.
+func respondMfaFailure(c *gin.Context, repos *repository.Repositories, attemptKey string, err error) {
+	if err == errInvalidMfaCode {
+		if recordErr := helpers.RecordLoginFailure(repos, attemptKey); recordErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record the login attempt!"})
+			return
+		}
+	}
+
+	respondMfaError(c, err)
+}
+
+// consumeMfaToken denylists an MFA token that is completing a login so it
+// cannot be used for another one. On failure it writes the error response
+// and returns false.
+func consumeMfaToken(c *gin.Context, repos *repository.Repositories, claims *helpers.SignedDetails) bool {
+	if err := helpers.RevokeToken(repos, claims.ID, claims.Uid, claims.ExpiresAt.Time); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to consume the MFA token!"})
+		return false
+	}
+
+	return true
+}
+
+// startMfaEnrollment stores a new, not yet active secret, along with the MFA
+// token that started it if any, and returns it with its provisioning URI. MFA only becomes active once a code generated from
+// the secret has been confirmed.
+func startMfaEnrollment(c *gin.Context, repos *repository.Repositories, foundUser models.User) {
+	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	if foundUser.Mfa_enabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "MFA is already enabled!"})
+		return
+	}
+
+	secret, err := helpers.GenerateTotpSecret()
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate the MFA secret!"})
+		return
+	}
+
+	foundUser.Mfa_secret = &secret
+	foundUser.Mfa_last_step = 0
+	foundUser.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+	if err := repos.Users.Update(ctx, foundUser, "mfa_secret", "mfa_last_step", "mfa_enrollment_jti"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store the MFA secret!"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":           secret,
+		"provisioning_uri": helpers.TotpProvisioningURI(secret, *foundUser.Email),
+	})
+}
+
+// activateMfa confirms the pending secret with a code and enables MFA on
+// foundUser, both in the store and in place.
+func activateMfa(repos *repository.Repositories, foundUser *models.User, code string) ([]string, error) {
+	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	if foundUser.Mfa_enabled {
+		return nil, errMfaAlreadyEnabled
+	}
+
+	if foundUser.Mfa_secret == nil {
+		return nil, errMfaNotStarted
+	}
+
+	if err := useTotpCode(repos, foundUser, code); err != nil {
+		return nil, err
+	}
+
+	recoveryCodes, hashes, err := helpers.GenerateRecoveryCodes()
+
+	if err != nil {
+		return nil, err
+	}
+
+	foundUser.Mfa_enabled = true
+	foundUser.Mfa_recovery_codes = hashes
+	foundUser.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+	if err := repos.Users.Update(ctx, *foundUser, "mfa_enabled", "mfa_recovery_codes"); err != nil {
+		return nil, err
+	}
+
+	return recoveryCodes, nil
+}
+
+// verifySecondFactor accepts either a TOTP code or one of the recovery
+// codes, which are single-use. The used step or code is also recorded on
+// foundUser so a later update of the user does not undo it.
+func verifySecondFactor(repos *repository.Repositories, foundUser *models.User, code string, recoveryCode string) error {
+	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	if recoveryCode == "" {
+		return useTotpCode(repos, foundUser, code)
+	}
+
+	hash := helpers.HashOpaqueToken(recoveryCode)
+
+	err := repos.Users.UseRecoveryCode(ctx, foundUser.User_id, hash)
+
+	if err == repository.ErrNotFound {
+		return errInvalidMfaCode
+	}
+
+	if err != nil {
+		return err
+	}
+
+	remaining := []string{}
+
+	for _, stored := range foundUser.Mfa_recovery_codes {
+		if stored != hash {
+			remaining = append(remaining, stored)
+		}
+	}
+
+	foundUser.Mfa_recovery_codes = remaining
+
+	return nil
+}
+
+// useTotpCode validates the code and records its time step so the same code
+// cannot be replayed within its validity window.
+func useTotpCode(repos *repository.Repositories, foundUser *models.User, code string) error {
+	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	if foundUser.Mfa_secret == nil {
+		return errInvalidMfaCode
+	}
+
+	step, ok := helpers.ValidateTotp(*foundUser.Mfa_secret, code, time.Now())
+
+	if !ok {
+		return errInvalidMfaCode
+	}
+
+	err := repos.Users.UseMfaStep(ctx, foundUser.User_id, step)
+
+	if err == repository.ErrNotFound {
+		return errInvalidMfaCode
+	}
+
+	if err != nil {
+		return err
+	}
+
+	foundUser.Mfa_last_step = step
+
+	return nil
+}
+
+func respondMfaError(c *gin.Context, err error) {
+	switch err {
+	case errInvalidMfaCode:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "The MFA code is invalid!"})
+	case errMfaAlreadyEnabled, errMfaNotStarted:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "MFA verification failed!"})
+	}
+}
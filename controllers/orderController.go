@@ -0,0 +1,334 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"golang-restaurant-management/helpers"
+	"golang-restaurant-management/models"
+	"golang-restaurant-management/repository"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type orderUpdate struct {
+	Table_id *string `json:"table_id"`
+	Status   *string `json:"status"`
+}
+
+func GetOrders(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		status := c.Query("status")
+
+		if status != "" && !helpers.IsValidOrderStatus(status) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order status!"})
+			return
+		}
+
+		allOrders, err := repos.Orders.List(ctx, status)
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while listing the orders!"})
+			return
+		}
+
+		c.JSON(http.StatusOK, ordersInScope(c, allOrders))
+	}
+}
+
+func GetOrder(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		order, err := repos.Orders.Get(ctx, c.Param("order_id"))
+
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order was not found!"})
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while loading the order!"})
+			return
+		}
+
+		if !orderTableInScope(c, order.Table_id) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This device may only see orders of its own table!"})
+			return
+		}
+
+		c.JSON(http.StatusOK, order)
+	}
+}
+
+// CreateOrder opens an empty order for a table. Items are added to it with
+// AddOrderItems.
+func CreateOrder(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var order models.Order
+
+		if err := c.BindJSON(&order); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !orderTableInScope(c, order.Table_id) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This device may only order for its own table!"})
+			return
+		}
+
+		order.Order_Date, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		order.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		order.ID = primitive.NewObjectID()
+		order.Order_id = order.ID.Hex()
+		order.Status_history = nil
+
+		helpers.SetOrderStatus(&order, models.OrderStatusOpen, c.GetString("uid"))
+
+		if validationErr := validate.Struct(order); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		if _, err := repos.Tables.Get(ctx, *order.Table_id); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Table was not found!"})
+			return
+		}
+
+		if insertErr := repos.Orders.Create(ctx, order); insertErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Order was not created!"})
+			return
+		}
+
+		publishOrder(helpers.EventOrderCreated, order)
+
+		c.JSON(http.StatusOK, order)
+	}
+}
+
+// UpdateOrder moves an order to another table and/or advances its status.
+// Status changes have to follow the order lifecycle and are recorded in the
+// order's status history.
+func UpdateOrder(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		var body orderUpdate
+
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if body.Table_id == nil && body.Status == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing to update!"})
+			return
+		}
+
+		if body.Status != nil && !helpers.IsValidOrderStatus(*body.Status) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order status!"})
+			return
+		}
+
+		updateOrder(c, repos, body)
+	}
+}
+
+func CancelOrder(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		status := models.OrderStatusCancelled
+
+		updateOrder(c, repos, orderUpdate{Status: &status})
+	}
+}
+
+func updateOrder(c *gin.Context, repos *repository.Repositories, body orderUpdate) {
+	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	order, err := repos.Orders.Get(ctx, c.Param("order_id"))
+
+	if err == repository.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order was not found!"})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while loading the order!"})
+		return
+	}
+
+	previous := order
+
+	if !orderTableInScope(c, order.Table_id) || (body.Table_id != nil && !orderTableInScope(c, body.Table_id)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This device may only change orders of its own table!"})
+		return
+	}
+
+	from := order.CurrentStatus()
+
+	if body.Status != nil && !helpers.CanTransitionOrder(from, *body.Status) {
+		c.JSON(http.StatusConflict, gin.H{"error": "An order cannot move from " + from + " to " + *body.Status + "!"})
+		return
+	}
+
+	if body.Table_id != nil {
+		if _, err := repos.Tables.Get(ctx, *body.Table_id); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Table was not found!"})
+			return
+		}
+
+		order.Table_id = body.Table_id
+		order.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	}
+
+	var unsent []models.OrderItem
+
+	if body.Status != nil {
+		var status int
+		var msg string
+
+		if unsent, status, msg = prepareKitchen(ctx, repos, order, *body.Status); status != http.StatusOK {
+			c.JSON(status, gin.H{"error": msg})
+			return
+		}
+
+		helpers.SetOrderStatus(&order, *body.Status, c.GetString("uid"))
+	}
+
+	err = repos.Orders.Update(ctx, order, from)
+
+	if err == repository.ErrNotFound {
+		c.JSON(http.StatusConflict, gin.H{"error": "The order was changed in the meantime, please reload it!"})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Order update failed!"})
+		return
+	}
+
+	var tickets []models.Ticket
+
+	if body.Status != nil {
+		if tickets, err = updateKitchen(ctx, repos, order, unsent); err != nil {
+			// Put the order back so that the status change can be retried.
+			if rollbackErr := repos.Orders.Update(ctx, previous, order.CurrentStatus()); rollbackErr != nil {
+				err = errors.Join(err, rollbackErr)
+			}
+
+			log.Println("Failed to update the kitchen for order", order.Order_id, "with its new status:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update the kitchen tickets!"})
+			return
+		}
+	}
+
+	publishOrder(helpers.EventOrderUpdated, order)
+
+	for _, ticket := range tickets {
+		publishTicket(helpers.EventTicketCreated, ticket)
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+// orderTableInScope reports whether the caller may act on orders of the
+// table. Table-scoped API keys are limited to their own table.
+func orderTableInScope(c *gin.Context, tableId *string) bool {
+	scopedTable := c.GetString("table_id")
+
+	return scopedTable == "" || (tableId != nil && *tableId == scopedTable)
+}
+
+// ordersInScope keeps the orders the caller may see.
+func ordersInScope(c *gin.Context, orders []models.Order) []models.Order {
+	if c.GetString("table_id") == "" {
+		return orders
+	}
+
+	visible := []models.Order{}
+
+	for _, order := range orders {
+		if orderTableInScope(c, order.Table_id) {
+			visible = append(visible, order)
+		}
+	}
+
+	return visible
+}
+
+// orderIdInScope reports whether the caller may act on the order with the
+// given id, or on something that belongs to it like an item or an invoice.
+// Unknown orders are out of scope for table-scoped callers.
+func orderIdInScope(ctx context.Context, c *gin.Context, repos *repository.Repositories, orderId string) (bool, error) {
+	if c.GetString("table_id") == "" {
+		return true, nil
+	}
+
+	order, err := repos.Orders.Get(ctx, orderId)
+
+	if err == repository.ErrNotFound {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return orderTableInScope(c, order.Table_id), nil
+}
+
+// visibleOrderIds returns the ids of the orders a table-scoped caller may
+// see, or nil for callers without a table scope.
+func visibleOrderIds(ctx context.Context, c *gin.Context, repos *repository.Repositories) (map[string]bool, error) {
+	if c.GetString("table_id") == "" {
+		return nil, nil
+	}
+
+	orders, err := repos.Orders.List(ctx, "")
+
+	if err != nil {
+		return nil, err
+	}
+
+	visible := map[string]bool{}
+
+	for _, order := range ordersInScope(c, orders) {
+		visible[order.Order_id] = true
+	}
+
+	return visible, nil
+}
+
+// orderItemsInScope keeps the items of orders the caller may see.
+func orderItemsInScope(ctx context.Context, c *gin.Context, repos *repository.Repositories, items []models.OrderItem) ([]models.OrderItem, error) {
+	visibleOrders, err := visibleOrderIds(ctx, c, repos)
+
+	if err != nil || visibleOrders == nil {
+		return items, err
+	}
+
+	visible := []models.OrderItem{}
+
+	for _, item := range items {
+		if visibleOrders[item.Order_id] {
+			visible = append(visible, item)
+		}
+	}
+
+	return visible, nil
+}
@@ -2,178 +2,202 @@ package controllers
 
 import (
 	"context"
-	"golang-restaurant-management/database"
+	"errors"
+	"fmt"
+	"golang-restaurant-management/helpers"
 	"golang-restaurant-management/models"
+	"golang-restaurant-management/repository"
 	"log"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var orderCollection *mongo.Collection = database.OpenCollection(database.Client, "orders")
-
 type OrderItemPack struct {
 	Table_id    *string
 	Order_items []models.OrderItem
 }
 
-var orderItemCollection *mongo.Collection = database.OpenCollection(database.Client, "order_items")
+type addOrderItemsRequest struct {
+	Order_items []models.OrderItem `json:"order_items"`
+}
 
-func GetOrderItems() gin.HandlerFunc {
+// OrderSummary is an order joined with its table and with the food of each
+// of its items, as returned by ItemsByOrder.
+type OrderSummary struct {
+	Order_id     string             `json:"order_id"`
+	Table_id     *string            `json:"table_id"`
+	Table_number *int               `json:"table_number"`
+	Payment_due  float64            `json:"payment_due"`
+	Total_count  int                `json:"total_count"`
+	Order_items  []OrderSummaryItem `json:"order_items"`
+}
+
+// OrderSummaryItem is one line of an order summary. Unit_price includes the
+// price deltas of the modifiers and Amount is the line total, i.e.
+// Quantity times Unit_price. Voided and comped lines are listed with their
+// Adjustment but do not count towards the payment due.
+type OrderSummaryItem struct {
+	Order_item_id string                    `json:"order_item_id"`
+	Food_id       *string                   `json:"food_id"`
+	Food_name     *string                   `json:"food_name"`
+	Food_image    *string                   `json:"food_image"`
+	Course        int                       `json:"course"`
+	Variant       *string                   `json:"variant"`
+	Modifiers     []models.SelectedModifier `json:"modifiers"`
+	Notes         *string                   `json:"notes"`
+	Quantity      int                       `json:"quantity"`
+	Unit_price    float64                   `json:"unit_price"`
+	Amount        float64                   `json:"amount"`
+	Adjustment    *models.ItemAdjustment    `json:"adjustment"`
+}
+
+func GetOrderItems(repos *repository.Repositories) gin.HandlerFunc {
 
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		res, err := orderItemCollection.Find(ctx, bson.M{})
+		allOrderItems, err := repos.Orders.ListItems(ctx)
 
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while listing orders items"})
-			return
+		if err == nil {
+			allOrderItems, err = orderItemsInScope(ctx, c, repos, allOrderItems)
 		}
 
-		var allOrderItems []bson.M
-
-		if err = res.All(ctx, &allOrderItems); err != nil {
-			log.Fatal(err)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while listing orders items"})
 			return
 		}
 
 		c.JSON(http.StatusOK, allOrderItems)
-
 	}
 }
 
-func GetOrderItemsByOrder() gin.HandlerFunc {
+func GetOrderItemsByOrder(repos *repository.Repositories) gin.HandlerFunc {
 
 	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
 
 		orderId := c.Param("order_id")
 
-		allOrderItems, err := ItemsByOrder(orderId)
+		summary, err := ItemsByOrder(ctx, repos, orderId)
+
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order was not found!"})
+			return
+		}
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error occurered while listing order items by order ID."})
 			return
 		}
 
-		c.JSON(http.StatusOK, allOrderItems)
+		if !orderTableInScope(c, summary.Table_id) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This device may only see orders of its own table!"})
+			return
+		}
+
+		// The endpoint has always answered with a list of order groups.
+		c.JSON(http.StatusOK, []OrderSummary{summary})
 	}
 }
 
-func ItemsByOrder(id string) (OrderItems []primitive.M, err error) {
-	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
-
-	matchStage := bson.D{{Key: "$match", Value: bson.D{
-		{Key: "order_id", Value: id},
-	}}}
-
-	lookupStage := bson.D{{Key: "$lookup", Value: bson.D{
-		{Key: "from", Value: "food"},
-		{Key: "localField", Value: "food_id"},
-		{Key: "foreignField", Value: "food_id"},
-		{Key: "as", Value: "food"},
-	}}}
-
-	unwindStage := bson.D{{Key: "$unwind", Value: bson.D{{Key: "path", Value: "$food"}, {Key: "preserveNullAndEmptyArrays", Value: true}}}}
-
-	lookupOrderStage := bson.D{{Key: "$lookup", Value: bson.D{
-		{Key: "from", Value: "orders"},
-		{Key: "localField", Value: "order_id"},
-		{Key: "foreignField", Value: "order_id"},
-		{Key: "as", Value: "order"},
-	}}}
-
-	unwindOrderStage := bson.D{{Key: "$unwind", Value: bson.D{
-		{Key: "path", Value: "$order"},
-		{Key: "preserveNullAndEmptyArrays", Value: true},
-	}}}
-
-	lookupTableStage := bson.D{{Key: "$lookup", Value: bson.D{
-		{Key: "from", Value: "table"},
-		{Key: "localField", Value: "order.table_id"},
-		{Key: "foreignField", Value: "table_id"},
-		{Key: "as", Value: "table"},
-	}}}
-
-	unwindTableStage := bson.D{{Key: "$unwind", Value: bson.D{
-		{Key: "path", Value: "$table"},
-		{Key: "preserveNullAndEmptyArrays", Value: true},
-	}}}
-
-	projectStage := bson.D{{Key: "$project", Value: bson.D{
-		{Key: "amount", Value: "$food.price"},
-		{Key: "total_count", Value: 1},
-		{Key: "food_name", Value: "$food.name"},
-		{Key: "food_image", Value: "$food.food_image"},
-		{Key: "table_number", Value: "$table.table_number"},
-		{Key: "table_id", Value: "$table.table_id"},
-		{Key: "order_id", Value: "$order.order_id"},
-		{Key: "price", Value: "$food.price"},
-		{Key: "quantity", Value: 1},
-	}}}
-
-	groupStage := bson.D{{Key: "$group", Value: bson.D{
-		{Key: "_id", Value: bson.D{
-			{Key: "order_id", Value: "$order_id"},
-		}},
-		{Key: "table_number", Value: bson.D{
-			{Key: "$first", Value: "$table_number"},
-		}},
-		{Key: "table_id", Value: bson.D{
-			{Key: "$first", Value: "$table_id"},
-		}},
-		{Key: "payment_due", Value: bson.D{
-			{Key: "$sum", Value: "$amount"},
-		}},
-		{Key: "total_count", Value: bson.D{
-			{Key: "$sum", Value: 1},
-		}},
-		{Key: "order_items", Value: bson.D{
-			{Key: "$push", Value: "$$ROOT"},
-		}},
-	}}}
-
-	projectStage2 := bson.D{{Key: "$project", Value: bson.D{
-		{Key: "payment_due", Value: 1},
-		{Key: "total_count", Value: 1},
-		{Key: "table_number", Value: 1},
-		{Key: "table_id", Value: 1},
-		{Key: "order_items", Value: 1},
-	}}}
-
-	res, err := orderItemCollection.Aggregate(ctx, mongo.Pipeline{
-		matchStage,
-		lookupStage,
-		unwindStage,
-		lookupOrderStage,
-		unwindOrderStage,
-		lookupTableStage,
-		unwindTableStage,
-		projectStage,
-		groupStage,
-		projectStage2,
-	})
+// ItemsByOrder joins the order's items with their food and the order's
+// table and totals the amount due. Each line is charged quantity times the
+// unit price, including modifiers, snapshotted when it was ordered; items
+// stored without one fall back to the current price of their variant, or
+// count as zero if their food has been deleted.
+func ItemsByOrder(ctx context.Context, repos *repository.Repositories, id string) (OrderSummary, error) {
+	var summary OrderSummary
+
+	order, err := repos.Orders.Get(ctx, id)
 
 	if err != nil {
-		panic(err)
+		return summary, err
 	}
 
-	if res.All(ctx, &OrderItems); err != nil {
-		panic(err)
+	summary.Order_id = order.Order_id
+	summary.Table_id = order.Table_id
+
+	if order.Table_id != nil {
+		if table, err := repos.Tables.Get(ctx, *order.Table_id); err == nil {
+			summary.Table_number = table.Table_number
+		}
 	}
 
-	return OrderItems, err
+	items, err := repos.Orders.ListItemsByOrder(ctx, id)
+
+	if err != nil {
+		return summary, err
+	}
+
+	foods := map[string]*models.Food{}
+	summary.Order_items = []OrderSummaryItem{}
+
+	for _, item := range items {
+		line := OrderSummaryItem{
+			Order_item_id: item.Order_item_id,
+			Food_id:       item.Food_id,
+			Course:        item.CourseNumber(),
+			Variant:       item.Variant,
+			Modifiers:     item.Modifiers,
+			Notes:         item.Notes,
+			Quantity:      item.Count(),
+		}
+
+		if item.Food_id != nil {
+			food, cached := foods[*item.Food_id]
+
+			if !cached {
+				if found, err := repos.Foods.Get(ctx, *item.Food_id); err == nil {
+					food = &found
+				} else if err != repository.ErrNotFound {
+					return summary, err
+				}
+
+				foods[*item.Food_id] = food
+			}
 
+			if food != nil {
+				line.Food_name = food.Name
+				line.Food_image = food.Food_image
+
+				if price, ok := food.VariantPrice(item.VariantName()); ok {
+					line.Unit_price = price
+				} else if food.Price != nil {
+					line.Unit_price = *food.Price
+				}
+
+				for _, modifier := range item.Modifiers {
+					line.Unit_price += modifier.Price_delta
+				}
+			}
+		}
+
+		if item.Unit_price != nil {
+			line.Unit_price = *item.Unit_price
+		}
+
+		if item.Adjustment != nil {
+			line.Adjustment = item.Adjustment
+			line.Unit_price = 0
+		}
+
+		line.Amount = toFixed(float64(line.Quantity)*line.Unit_price, 2)
+
+		summary.Payment_due += line.Amount
+		summary.Order_items = append(summary.Order_items, line)
+	}
+
+	summary.Total_count = len(summary.Order_items)
+
+	return summary, nil
 }
 
-func GetOrderItem() gin.HandlerFunc {
+func GetOrderItem(repos *repository.Repositories) gin.HandlerFunc {
 
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
@@ -181,19 +205,39 @@ func GetOrderItem() gin.HandlerFunc {
 
 		orderItemId := c.Param("orderItem_id")
 
-		var orderItem models.OrderItem
+		orderItem, err := repos.Orders.GetItem(ctx, orderItemId)
 
-		err := orderItemCollection.FindOne(ctx, bson.M{"order_item_id": orderItemId}).Decode(&orderItem)
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order item not found"})
+			return
+		}
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while listing the order item."})
+			return
+		}
+
+		inScope, err := orderIdInScope(ctx, c, repos, orderItem.Order_id)
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while loading the order!"})
+			return
+		}
+
+		if !inScope {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This device may only see orders of its own table!"})
+			return
 		}
 
 		c.JSON(http.StatusOK, orderItem)
 	}
 }
 
-func CreateOrderItem() gin.HandlerFunc {
+// CreateOrderItem opens an order for the table and adds the pack's items to
+// it. The whole pack is validated before anything is written, and the order
+// and its items are stored together, so a rejected pack leaves no empty
+// order behind.
+func CreateOrderItem(repos *repository.Repositories) gin.HandlerFunc {
 
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
@@ -207,153 +251,513 @@ func CreateOrderItem() gin.HandlerFunc {
 			return
 		}
 
-		order.Order_Date, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-
-		order.Table_id = orderItemPack.Table_id
-		order_id := OrderItemOrderCreator(order)
+		if !orderTableInScope(c, orderItemPack.Table_id) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This device may only order for its own table!"})
+			return
+		}
 
-		orderItemsToBeInserted := []interface{}{}
+		if len(orderItemPack.Order_items) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "An order needs at least one item!"})
+			return
+		}
 
-		for _, orderItem := range orderItemPack.Order_items {
-			orderItem.Order_id = order_id
+		order.Order_Date, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		order.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 
-			validationErr := validate.Struct(orderItem)
+		order.ID = primitive.NewObjectID()
+		order.Order_id = order.ID.Hex()
+		order.Table_id = orderItemPack.Table_id
 
-			if validationErr != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
-				return
-			}
+		helpers.SetOrderStatus(&order, models.OrderStatusOpen, c.GetString("uid"))
 
-			orderItem.ID = primitive.NewObjectID()
-			orderItem.Order_item_id = orderItem.ID.Hex()
+		if validationErr := validate.Struct(order); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
 
-			orderItem.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-			orderItem.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		if _, err := repos.Tables.Get(ctx, *order.Table_id); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Table was not found!"})
+			return
+		}
 
-			var num = toFixed(*orderItem.Unit_price, 2)
-			orderItem.Unit_price = &num
+		orderItemsToBeInserted, status, msg := newOrderItems(ctx, c, repos, order.Order_id, orderItemPack.Order_items)
 
-			orderItemsToBeInserted = append(orderItemsToBeInserted, orderItem)
+		if status != http.StatusOK {
+			c.JSON(status, gin.H{"error": msg})
+			return
+		}
 
+		if insertErr := repos.Orders.CreateWithItems(ctx, order, orderItemsToBeInserted); insertErr != nil {
+			log.Println("Failed to create order", order.Order_id, "with its items:", insertErr)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Order was not created!"})
+			return
 		}
 
-		insertedOrderItems, insertErr := orderItemCollection.InsertMany(ctx, orderItemsToBeInserted)
+		publishOrder(helpers.EventOrderCreated, order)
 
-		if insertErr != nil {
-			log.Fatal(insertErr)
+		for _, orderItem := range orderItemsToBeInserted {
+			helpers.DefaultEventBus.Publish(helpers.EventOrderItemCreated, orderItem, orderTopics(order)...)
 		}
 
-		c.JSON(http.StatusOK, insertedOrderItems)
+		c.JSON(http.StatusOK, orderItemsToBeInserted)
 	}
 }
 
-func UpdateOrderItem() gin.HandlerFunc {
+// AddOrderItems adds items to an existing order. Items can be added while
+// the order is being taken and while it is in the kitchen, e.g. for a
+// further course; in the latter case items that are not held go straight to
+// the kitchen.
+func AddOrderItems(repos *repository.Repositories) gin.HandlerFunc {
 
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		var orderItem models.OrderItem
+		var body addOrderItemsRequest
 
-		orderItemId := c.Param("orderItem_id")
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		order, err := repos.Orders.Get(ctx, c.Param("order_id"))
+
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order was not found!"})
+			return
+		}
 
-		filter := bson.M{"orderItem_id": orderItemId}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while loading the order!"})
+			return
+		}
 
-		var updatedObj primitive.D
+		if !orderTableInScope(c, order.Table_id) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This device may only order for its own table!"})
+			return
+		}
 
-		if orderItem.Unit_price != nil {
-			updatedObj = append(updatedObj, bson.E{Key: "unit_price", Value: orderItem.Unit_price})
+		if status := order.CurrentStatus(); status != models.OrderStatusOpen && status != models.OrderStatusSentToKitchen {
+			c.JSON(http.StatusConflict, gin.H{"error": "Items can only be added to open orders and orders in the kitchen!"})
+			return
 		}
 
-		if orderItem.Quantity != nil {
-			updatedObj = append(updatedObj, bson.E{Key: "quantity", Value: orderItem.Quantity})
+		if len(body.Order_items) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "At least one item is needed!"})
+			return
 		}
 
-		if orderItem.Food_id != nil {
-			updatedObj = append(updatedObj, bson.E{Key: "food_id", Value: orderItem.Food_id})
+		items, status, msg := newOrderItems(ctx, c, repos, order.Order_id, body.Order_items)
+
+		if status != http.StatusOK {
+			c.JSON(status, gin.H{"error": msg})
+			return
 		}
 
-		orderItem.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		var unheld []models.OrderItem
+
+		if order.CurrentStatus() == models.OrderStatusSentToKitchen {
+			for _, item := range items {
+				if item.Prep_status == "" {
+					unheld = append(unheld, item)
+				}
+			}
+		}
 
-		updatedObj = append(updatedObj, bson.E{Key: "updated_at", Value: orderItem.Updated_at})
+		var tickets []models.Ticket
 
-		upsert := true
+		err = repos.Orders.CreateItems(ctx, items)
 
-		opt := options.UpdateOptions{
-			Upsert: &upsert,
+		if err == nil {
+			tickets, err = sendTickets(ctx, repos, order, unheld, "")
 		}
 
-		res, insertErr := orderItemCollection.UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: updatedObj}}, &opt)
+		if err != nil {
+			// Nothing of the request is kept, so it can simply be sent again.
+			var ids []string
 
-		if insertErr != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update the order item!"})
+			for _, item := range items {
+				ids = append(ids, item.Order_item_id)
+			}
+
+			err = errors.Join(err, repos.Orders.DeleteItems(ctx, ids))
+
+			log.Println("Failed to add items to order", order.Order_id, "and send them to the kitchen:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Order items were not created!"})
 			return
 		}
 
-		c.JSON(http.StatusOK, res)
+		for i := range items {
+			if len(tickets) > 0 && items[i].Prep_status == "" {
+				items[i].Prep_status = models.PrepStatusQueued
+			}
+
+			helpers.DefaultEventBus.Publish(helpers.EventOrderItemCreated, items[i], orderTopics(order)...)
+		}
+
+		for _, ticket := range tickets {
+			publishTicket(helpers.EventTicketCreated, ticket)
+		}
+
+		c.JSON(http.StatusOK, items)
 	}
 }
 
-func DeleteOrderItem() gin.HandlerFunc {
+// newOrderItems validates and prices the items of a request for the given
+// order. Nothing is stored; on failure it returns the status and message to
+// reject the whole request with.
+func newOrderItems(ctx context.Context, c *gin.Context, repos *repository.Repositories, orderId string, pack []models.OrderItem) ([]models.OrderItem, int, string) {
+	items := []models.OrderItem{}
+	foods := map[string]models.Food{}
+
+	for _, orderItem := range pack {
+		orderItem.Order_id = orderId
+
+		if validationErr := validate.Struct(orderItem); validationErr != nil {
+			return nil, http.StatusBadRequest, validationErr.Error()
+		}
+
+		food, err := orderItemFood(ctx, repos, foods, *orderItem.Food_id)
+
+		if err == repository.ErrNotFound {
+			return nil, http.StatusBadRequest, "Food was not found!"
+		}
+
+		if err != nil {
+			return nil, http.StatusInternalServerError, "Error occured while loading the food!"
+		}
+
+		if status, msg := priceOrderItem(c, food, &orderItem); status != http.StatusOK {
+			return nil, status, msg
+		}
+
+		orderItem.ID = primitive.NewObjectID()
+		orderItem.Order_item_id = orderItem.ID.Hex()
+		orderItem.Prep_status = ""
+
+		if orderItem.Hold != nil && *orderItem.Hold {
+			orderItem.Prep_status = models.PrepStatusHeld
+		}
+
+		orderItem.Hold = nil
+		orderItem.Adjustment = nil
+
+		orderItem.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		orderItem.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		items = append(items, orderItem)
+	}
+
+	return items, http.StatusOK, ""
+}
+
+func UpdateOrderItem(repos *repository.Repositories) gin.HandlerFunc {
+
 	return func(c *gin.Context) {
-		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
+		var body models.OrderItem
+
 		orderItemId := c.Param("orderItem_id")
 
-		var orderItem models.OrderItem
-		err := orderItemCollection.FindOne(ctx, bson.M{"order_item_id": orderItemId}).Decode(&orderItem)
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		orderItem, err := repos.Orders.GetItem(ctx, orderItemId)
+
 		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Order item not found"})
 			return
 		}
 
-		filter := bson.M{"order_item_id": orderItemId}
-		res, err := orderItemCollection.DeleteOne(ctx, filter)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete order item"})
+		if orderItem.Adjustment != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Voided and comped items cannot be changed!"})
 			return
 		}
 
-		count, err := orderItemCollection.CountDocuments(ctx, bson.M{"order_id": orderItem.Order_id})
+		order, err := repos.Orders.Get(ctx, orderItem.Order_id)
+
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check remaining order items"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while loading the order!"})
+			return
+		}
+
+		if !orderTableInScope(c, order.Table_id) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This device may only change orders of its own table!"})
+			return
+		}
+
+		// What was ordered is fixed once the order leaves order taking or the
+		// item reaches the kitchen; from then on it is voided or comped.
+		changesDish := body.Quantity != nil || body.Food_id != nil || body.Variant != nil || body.Modifiers != nil || body.Notes != nil
+		awaitsKitchen := orderItem.Prep_status == "" || orderItem.Prep_status == models.PrepStatusHeld
+
+		if changesDish && (order.CurrentStatus() != models.OrderStatusOpen || !awaitsKitchen) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Only items of open orders can be changed, void or comp the item instead!"})
+			return
+		}
+
+		if body.Quantity != nil {
+			orderItem.Quantity = body.Quantity
+		}
+
+		if body.Food_id != nil {
+			orderItem.Food_id = body.Food_id
+		}
+
+		if body.Variant != nil {
+			orderItem.Variant = body.Variant
+		}
+
+		if body.Modifiers != nil {
+			orderItem.Modifiers = body.Modifiers
+		}
+
+		if body.Notes != nil {
+			orderItem.Notes = body.Notes
+		}
+
+		// Course and hold only matter until the item reaches the kitchen.
+		if (body.Course != nil || body.Hold != nil) && orderItem.Prep_status != "" && orderItem.Prep_status != models.PrepStatusHeld {
+			c.JSON(http.StatusConflict, gin.H{"error": "The item was already sent to the kitchen!"})
 			return
 		}
 
-		if count == 0 {
-			orderFilter := bson.M{"order_id": orderItem.Order_id}
-			_, err := orderCollection.DeleteOne(ctx, orderFilter)
+		if body.Course != nil {
+			orderItem.Course = body.Course
+		}
+
+		if body.Hold != nil {
+			if order.CurrentStatus() != models.OrderStatusOpen {
+				c.JSON(http.StatusConflict, gin.H{"error": "Held items of an order in the kitchen are released by firing their course!"})
+				return
+			}
+
+			orderItem.Prep_status = ""
+
+			if *body.Hold {
+				orderItem.Prep_status = models.PrepStatusHeld
+			}
+		}
+
+		// A new food, variant, modifier selection or price override
+		// reprices the item; a bare unit_price is ignored like on creation.
+		reprice := body.Food_id != nil || body.Variant != nil || body.Modifiers != nil || body.Price_override != nil
+
+		if reprice {
+			orderItem.Unit_price = body.Unit_price
+			orderItem.Price_override = body.Price_override
+		}
+
+		if validationErr := validate.Struct(orderItem); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		if reprice {
+			food, err := repos.Foods.Get(ctx, *orderItem.Food_id)
+
+			if err == repository.ErrNotFound {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Food was not found!"})
+				return
+			}
+
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete order"})
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while loading the food!"})
 				return
 			}
 
-			c.JSON(http.StatusOK, gin.H{"message": "Order and all items deleted"})
+			if status, msg := priceOrderItem(c, food, &orderItem); status != http.StatusOK {
+				c.JSON(status, gin.H{"error": msg})
+				return
+			}
+		}
+
+		orderItem.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		if err := repos.Orders.UpdateItem(ctx, orderItem); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update the order item!"})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "Order item deleted", "deleted_count": res.DeletedCount})
+		publishOrderChange(ctx, repos, helpers.EventOrderItemUpdated, orderItem.Order_id, orderItem)
+
+		c.JSON(http.StatusOK, orderItem)
 	}
 }
 
-func OrderItemOrderCreator(order models.Order) string {
+// orderItemFood loads the food of an item, caching it for the other items
+// of the same request.
+func orderItemFood(ctx context.Context, repos *repository.Repositories, foods map[string]models.Food, foodId string) (models.Food, error) {
+	if food, ok := foods[foodId]; ok {
+		return food, nil
+	}
+
+	food, err := repos.Foods.Get(ctx, foodId)
 
-	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
+	if err == nil {
+		foods[foodId] = food
+	}
 
-	order.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-	order.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	return food, err
+}
 
-	order.ID = primitive.NewObjectID()
-	order.Order_id = order.ID.Hex()
+// priceOrderItem snapshots the menu price of the ordered variant plus its
+// modifiers on the item. A price sent by the client is only kept as a price
+// override, which needs a reason and the price:override permission and is
+// recorded on the item. It returns http.StatusOK or the status and message
+// to reject the item with.
+func priceOrderItem(c *gin.Context, food models.Food, item *models.OrderItem) (int, string) {
+	if item.Variant != nil && *item.Variant == "" {
+		item.Variant = nil
+	}
 
-	_, err := orderCollection.InsertOne(ctx, order)
+	menuPrice, ok := food.VariantPrice(item.VariantName())
 
-	if err != nil {
-		log.Fatal(err)
+	if !ok {
+		return http.StatusBadRequest, "The variant is not offered for this food!"
+	}
+
+	if err := priceModifiers(food, item.Modifiers); err != nil {
+		return http.StatusBadRequest, err.Error()
+	}
+
+	for _, modifier := range item.Modifiers {
+		menuPrice += modifier.Price_delta
 	}
 
-	return order.Order_id
+	menuPrice = toFixed(menuPrice, 2)
 
+	if item.Price_override == nil {
+		item.Unit_price = &menuPrice
+		return http.StatusOK, ""
+	}
+
+	if err := helpers.CheckPermission(c, helpers.PermPriceOverride); err != nil {
+		return http.StatusForbidden, "Only managers can override prices!"
+	}
+
+	if item.Unit_price == nil || *item.Unit_price < 0 {
+		return http.StatusBadRequest, "A price override needs a unit price!"
+	}
+
+	price := toFixed(*item.Unit_price, 2)
+
+	item.Unit_price = &price
+	item.Price_override.Menu_price = menuPrice
+	item.Price_override.Overridden_by = c.GetString("uid")
+	item.Price_override.Overridden_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+	log.Printf("Price of food %s overridden from %.2f to %.2f by %s: %s", food.Food_id, menuPrice, price, item.Price_override.Overridden_by, item.Price_override.Reason)
+
+	return http.StatusOK, ""
+}
+
+// priceModifiers checks the selected modifiers against the modifier groups
+// of the food and copies the price delta of each option onto them.
+func priceModifiers(food models.Food, selected []models.SelectedModifier) error {
+	picked := map[string]int{}
+	seen := map[models.SelectedModifier]bool{}
+
+	for i, modifier := range selected {
+		group, found := modifierGroup(food, modifier.Group)
+
+		if !found {
+			return fmt.Errorf("the food has no modifier group %q", modifier.Group)
+		}
+
+		option, found := modifierOption(group, modifier.Option)
+
+		if !found {
+			return fmt.Errorf("modifier group %q has no option %q", group.Name, modifier.Option)
+		}
+
+		key := models.SelectedModifier{Group: group.Name, Option: option.Name}
+
+		if seen[key] {
+			return fmt.Errorf("option %q of modifier group %q is selected twice", option.Name, group.Name)
+		}
+
+		seen[key] = true
+		picked[group.Name]++
+		selected[i].Price_delta = option.Price_delta
+	}
+
+	for _, group := range food.Modifier_groups {
+		min, max := group.Selections()
+
+		if picked[group.Name] < min || picked[group.Name] > max {
+			return fmt.Errorf("modifier group %q needs between %d and %d selections", group.Name, min, max)
+		}
+	}
+
+	return nil
+}
+
+func modifierGroup(food models.Food, name string) (models.ModifierGroup, bool) {
+	for _, group := range food.Modifier_groups {
+		if group.Name == name {
+			return group, true
+		}
+	}
+
+	return models.ModifierGroup{}, false
+}
+
+func modifierOption(group models.ModifierGroup, name string) (models.ModifierOption, bool) {
+	for _, option := range group.Options {
+		if option.Name == name {
+			return option, true
+		}
+	}
+
+	return models.ModifierOption{}, false
+}
+
+// DeleteOrderItem removes an item while its order is still being taken.
+// Once the order has gone to the kitchen, items are voided or comped
+// instead so they stay on record. Orders are kept even when their last item
+// is removed; they are cancelled through the order lifecycle.
+func DeleteOrderItem(repos *repository.Repositories) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		orderItemId := c.Param("orderItem_id")
+
+		orderItem, err := repos.Orders.GetItem(ctx, orderItemId)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order item not found"})
+			return
+		}
+
+		order := eventOrder(ctx, repos, orderItem.Order_id)
+
+		if !orderTableInScope(c, order.Table_id) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This device may only change orders of its own table!"})
+			return
+		}
+
+		if orderItem.Adjustment != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Voided and comped items cannot be deleted!"})
+			return
+		}
+
+		if order.CurrentStatus() != models.OrderStatusOpen {
+			c.JSON(http.StatusConflict, gin.H{"error": "Only items of open orders can be deleted, void or comp the item instead!"})
+			return
+		}
+
+		if err := repos.Orders.DeleteItem(ctx, orderItemId); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete order item"})
+			return
+		}
+
+		helpers.DefaultEventBus.Publish(helpers.EventOrderItemDeleted, orderItem, orderTopics(order)...)
+
+		c.JSON(http.StatusOK, gin.H{"message": "Order item deleted", "deleted_count": 1})
+	}
 }
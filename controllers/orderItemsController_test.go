@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"context"
+	"golang-restaurant-management/models"
+	"golang-restaurant-management/repository"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestItemsByOrder(t *testing.T) {
+	ctx := context.Background()
+	repos := repository.NewMemoryRepositories()
+
+	addFood := func(name string, price float64) models.Food {
+		food := models.Food{ID: primitive.NewObjectID(), Name: &name, Price: &price}
+		food.Food_id = food.ID.Hex()
+
+		if err := repos.Foods.Create(ctx, food); err != nil {
+			t.Fatal(err)
+		}
+
+		return food
+	}
+
+	// line orders quantity portions of the variant at its current price.
+	line := func(food models.Food, quantity int, variant string) models.OrderItem {
+		item := models.OrderItem{ID: primitive.NewObjectID(), Food_id: &food.Food_id, Quantity: &quantity}
+		item.Order_item_id = item.ID.Hex()
+
+		if variant != "" {
+			item.Variant = &variant
+		}
+
+		if price, ok := food.VariantPrice(variant); ok {
+			item.Unit_price = &price
+		}
+
+		return item
+	}
+
+	// unpricedLine is an item stored before quantities and prices were kept.
+	unpricedLine := func(food models.Food, variant string) models.OrderItem {
+		item := line(food, 1, variant)
+		item.Quantity = nil
+		item.Unit_price = nil
+
+		return item
+	}
+
+	addOrder := func(tableId string, items ...models.OrderItem) string {
+		order := models.Order{ID: primitive.NewObjectID(), Table_id: &tableId}
+		order.Order_id = order.ID.Hex()
+
+		if err := repos.Orders.Create(ctx, order); err != nil {
+			t.Fatal(err)
+		}
+
+		for i := range items {
+			items[i].Order_id = order.Order_id
+		}
+
+		if len(items) > 0 {
+			if err := repos.Orders.CreateItems(ctx, items); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return order.Order_id
+	}
+
+	number, guests := 7, 4
+	table := models.Table{ID: primitive.NewObjectID(), Table_number: &number, Number_of_guests: &guests}
+	table.Table_id = table.ID.Hex()
+
+	if err := repos.Tables.Create(ctx, table); err != nil {
+		t.Fatal(err)
+	}
+
+	soup := addFood("Soup", 6.5)
+	steak := addFood("Steak", 24)
+	retired := addFood("Retired", 9)
+	pizza := addFood("Pizza", 8)
+
+	small, large := 8.0, 12.5
+	pizza.Variants = []models.FoodVariant{{Name: "Small", Price: &small}, {Name: "Large", Price: &large}}
+
+	if err := repos.Foods.Update(ctx, pizza); err != nil {
+		t.Fatal(err)
+	}
+
+	full := addOrder(table.Table_id, line(soup, 1, ""), line(steak, 1, ""), line(soup, 1, ""))
+	empty := addOrder(table.Table_id)
+	tableless := addOrder("missing", line(steak, 1, ""))
+	withDeletedFood := addOrder(table.Table_id, line(soup, 1, ""), line(retired, 1, ""))
+	unpriced := addOrder(table.Table_id, unpricedLine(soup, ""), unpricedLine(retired, ""), unpricedLine(pizza, "Large"))
+	cheesy := unpricedLine(soup, "")
+	cheesy.Modifiers = []models.SelectedModifier{{Group: "Extras", Option: "Cheese", Price_delta: 1.5}}
+	unpricedModifiers := addOrder(table.Table_id, cheesy)
+	variants := addOrder(table.Table_id, line(pizza, 3, "Large"), line(pizza, 2, "Small"), line(soup, 2, ""))
+	voided, comped := line(steak, 1, ""), line(soup, 2, "")
+	voided.Adjustment = &models.ItemAdjustment{Type: models.AdjustmentVoid, Reason_code: models.ReasonOrderError}
+	comped.Adjustment = &models.ItemAdjustment{Type: models.AdjustmentComp, Reason_code: models.ReasonLongWait}
+	adjusted := addOrder(table.Table_id, line(soup, 1, ""), voided, comped)
+
+	if err := repos.Foods.Delete(ctx, retired.Food_id); err != nil {
+		t.Fatal(err)
+	}
+
+	raised := 30.0
+	steak.Price = &raised
+
+	if err := repos.Foods.Update(ctx, steak); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name        string
+		orderId     string
+		err         error
+		paymentDue  float64
+		totalCount  int
+		tableNumber *int
+	}{
+		{name: "sums the ordered prices", orderId: full, paymentDue: 37, totalCount: 3, tableNumber: &number},
+		{name: "order without items", orderId: empty, paymentDue: 0, totalCount: 0, tableNumber: &number},
+		{name: "table that no longer exists", orderId: tableless, paymentDue: 24, totalCount: 1},
+		{name: "deleted food keeps its ordered price", orderId: withDeletedFood, paymentDue: 15.5, totalCount: 2, tableNumber: &number},
+		{name: "unpriced items use the menu price", orderId: unpriced, paymentDue: 19, totalCount: 3, tableNumber: &number},
+		{name: "unpriced items add their modifiers", orderId: unpricedModifiers, paymentDue: 8, totalCount: 1, tableNumber: &number},
+		{name: "lines are quantity times variant price", orderId: variants, paymentDue: 66.5, totalCount: 3, tableNumber: &number},
+		{name: "voided and comped lines are not charged", orderId: adjusted, paymentDue: 6.5, totalCount: 3, tableNumber: &number},
+		{name: "unknown order", orderId: "missing", err: repository.ErrNotFound},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			summary, err := ItemsByOrder(ctx, repos, tc.orderId)
+
+			if err != tc.err {
+				t.Fatalf("err = %v, want %v", err, tc.err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if summary.Payment_due != tc.paymentDue {
+				t.Errorf("payment due = %v, want %v", summary.Payment_due, tc.paymentDue)
+			}
+
+			if summary.Total_count != tc.totalCount || len(summary.Order_items) != tc.totalCount {
+				t.Errorf("total count = %d with %d items, want %d", summary.Total_count, len(summary.Order_items), tc.totalCount)
+			}
+
+			if (summary.Table_number == nil) != (tc.tableNumber == nil) || (tc.tableNumber != nil && *summary.Table_number != *tc.tableNumber) {
+				t.Errorf("table number = %v, want %v", summary.Table_number, tc.tableNumber)
+			}
+		})
+	}
+}
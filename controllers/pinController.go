@@ -0,0 +1,181 @@
+package controllers
+
+import (
+	"context"
+	"golang-restaurant-management/helpers"
+	"golang-restaurant-management/models"
+	"golang-restaurant-management/repository"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type setPinRequest struct {
+	Password string `json:"password" validate:"required"`
+	Pin      string `json:"pin" validate:"required,numeric,min=4,max=6"`
+}
+
+type pinLoginRequest struct {
+	User_id string `json:"user_id" validate:"required"`
+	Pin     string `json:"pin" validate:"required,numeric,min=4,max=6"`
+}
+
+// SetPin lets users choose the PIN they use on shared terminals. The
+// current password is required so a borrowed session cannot set one.
+func SetPin(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var body setPinRequest
+
+		if c.GetString("auth_method") != "token" || c.GetString("terminal_id") != "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "A PIN can only be set from a personal login!"})
+			return
+		}
+
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if validationErr := validate.Struct(body); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		foundUser, err := repos.Users.Get(ctx, c.GetString("uid"))
+
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User was not found!"})
+			return
+		}
+
+		if passwordIsValid, msg := VerifyPassword(body.Password, *foundUser.Password); !passwordIsValid {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": msg})
+			return
+		}
+
+		pinHash, err := bcrypt.GenerateFromPassword([]byte(body.Pin), bcrypt.DefaultCost)
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash the PIN!"})
+			return
+		}
+
+		pin := string(pinHash)
+		foundUser.Pin_hash = &pin
+		foundUser.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		if err := repos.Users.Update(ctx, foundUser, "pin_hash"); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "PIN update failed!"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "PIN updated!"})
+	}
+}
+
+// PinLogin signs a staff member in on a terminal that is already
+// authenticated with its API key. The resulting token only works together
+// with that key, is limited to the key's table and station scope and lapses
+// after a period of inactivity. Accounts protected by a second factor cannot
+// sign in with a PIN.
+func PinLogin(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var body pinLoginRequest
+
+		if c.GetString("auth_method") != "api_key" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "PIN login is only available on terminals!"})
+			return
+		}
+
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if validationErr := validate.Struct(body); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		foundUser, status, msg := verifyPin(ctx, c, repos, body.User_id, body.Pin)
+
+		if status != http.StatusOK {
+			c.JSON(status, gin.H{"error": msg})
+			return
+		}
+
+		if foundUser.Mfa_enabled || helpers.MfaRequiredForRole(*foundUser.User_type) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Accounts with two-factor authentication cannot use PIN login!"})
+			return
+		}
+
+		token, err := helpers.GenerateTerminalToken(*foundUser.Email, *foundUser.First_name, *foundUser.Last_name, foundUser.User_id, *foundUser.User_type, c.GetString("api_key_id"))
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate the token!"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"token":       token,
+			"expires_in":  int(helpers.TerminalIdleTimeout().Seconds()),
+			"user_id":     foundUser.User_id,
+			"first_name":  foundUser.First_name,
+			"last_name":   foundUser.Last_name,
+			"user_type":   foundUser.User_type,
+			"terminal_id": c.GetString("api_key_id"),
+			"table_id":    c.GetString("table_id"),
+		})
+	}
+}
+
+// verifyPin checks the PIN of an active user. Failures count towards the
+// same lockout for PIN logins and approvals; a locked out user gets a
+// Retry-After header. On failure it returns the status and message to
+// answer with.
+func verifyPin(ctx context.Context, c *gin.Context, repos *repository.Repositories, userId string, pin string) (models.User, int, string) {
+	attemptKey := "pin:" + userId
+
+	wait, err := helpers.LoginRetryAfter(repos, attemptKey)
+
+	if err != nil {
+		return models.User{}, http.StatusInternalServerError, "Failed to check login attempts!"
+	}
+
+	if wait > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		return models.User{}, http.StatusTooManyRequests, "Too many failed PIN attempts. Please try again later!"
+	}
+
+	foundUser, err := repos.Users.Get(ctx, userId)
+
+	if err != nil || foundUser.Pin_hash == nil || bcrypt.CompareHashAndPassword([]byte(*foundUser.Pin_hash), []byte(pin)) != nil {
+		if err := helpers.RecordLoginFailure(repos, attemptKey); err != nil {
+			return models.User{}, http.StatusInternalServerError, "Failed to record the login attempt!"
+		}
+
+		return models.User{}, http.StatusUnauthorized, "User or PIN is incorrect!"
+	}
+
+	if err := helpers.ResetLoginFailures(repos, attemptKey); err != nil {
+		return models.User{}, http.StatusInternalServerError, "Failed to reset login attempts!"
+	}
+
+	if foundUser.Deactivated_at != nil {
+		return models.User{}, http.StatusForbidden, "This account has been deactivated!"
+	}
+
+	return foundUser, http.StatusOK, ""
+}
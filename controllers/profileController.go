@@ -0,0 +1,316 @@
+package controllers
+
+import (
+	"context"
+	"golang-restaurant-management/helpers"
+	"golang-restaurant-management/repository"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type profileUpdate struct {
+	First_name       *string `json:"first_name" validate:"omitempty,min=2,max=100"`
+	Last_name        *string `json:"last_name" validate:"omitempty,min=2,max=100"`
+	Email            *string `json:"email" validate:"omitempty,email"`
+	Phone            *string `json:"phone" validate:"omitempty,min=1"`
+	Avatar           *string `json:"avatar"`
+	Current_password string  `json:"current_password"`
+}
+
+type passwordChange struct {
+	Current_password string `json:"current_password" validate:"required"`
+	New_password     string `json:"new_password" validate:"required,min=6"`
+}
+
+type roleChange struct {
+	User_type string `json:"user_type" validate:"required"`
+}
+
+// UpdateUser edits the profile fields of an account. Users editing their own
+// profile must confirm the change with their current password; admins
+// managing someone else's account do not.
+func UpdateUser(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userId := c.Param("user_id")
+
+		var body profileUpdate
+
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if validationErr := validate.Struct(body); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		foundUser, err := repos.Users.Get(ctx, userId)
+
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User was not found!"})
+			return
+		}
+
+		if userId == c.GetString("uid") {
+			if passwordIsValid, msg := VerifyPassword(body.Current_password, *foundUser.Password); !passwordIsValid {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": msg})
+				return
+			}
+		}
+
+		changed := false
+
+		if body.First_name != nil {
+			foundUser.First_name = body.First_name
+			changed = true
+		}
+
+		if body.Last_name != nil {
+			foundUser.Last_name = body.Last_name
+			changed = true
+		}
+
+		if body.Avatar != nil {
+			foundUser.Avatar = body.Avatar
+			changed = true
+		}
+
+		var newEmail, newPhone string
+
+		if body.Phone != nil && (foundUser.Phone == nil || *body.Phone != *foundUser.Phone) {
+			newPhone = *body.Phone
+			foundUser.Phone = body.Phone
+			changed = true
+		}
+
+		emailChanged := body.Email != nil && (foundUser.Email == nil || *body.Email != *foundUser.Email)
+
+		if emailChanged {
+			newEmail = *body.Email
+			foundUser.Email = body.Email
+			foundUser.Email_verified = false
+			changed = true
+		}
+
+		if !changed {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing to update!"})
+			return
+		}
+
+		if newEmail != "" || newPhone != "" {
+			taken, err := repos.Users.EmailOrPhoneTaken(ctx, newEmail, newPhone, userId)
+
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while checking user existence!"})
+				return
+			}
+
+			if taken {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Email or phone already exists!"})
+				return
+			}
+		}
+
+		foundUser.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		if err := repos.Users.Update(ctx, foundUser, "first_name", "last_name", "avatar", "phone", "email", "email_verified"); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "User update failed!"})
+			return
+		}
+
+		if emailChanged {
+			sendVerificationEmailInBackground(repos, foundUser)
+		}
+
+		c.JSON(http.StatusOK, publicUser(foundUser))
+	}
+}
+
+// ChangePassword lets users replace their own password. Every other session
+// of the user is ended so a leaked password stops working everywhere else.
+func ChangePassword(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userId := c.Param("user_id")
+
+		var body passwordChange
+
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if validationErr := validate.Struct(body); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		foundUser, err := repos.Users.Get(ctx, userId)
+
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User was not found!"})
+			return
+		}
+
+		if passwordIsValid, msg := VerifyPassword(body.Current_password, *foundUser.Password); !passwordIsValid {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": msg})
+			return
+		}
+
+		password := HashPassword(body.New_password)
+		foundUser.Password = &password
+		foundUser.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		if err := repos.Users.Update(ctx, foundUser, "password"); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change the password!"})
+			return
+		}
+
+		if err := helpers.RevokeUserSessions(repos, userId, c.GetString("session_id")); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to end the other sessions!"})
+			return
+		}
+
+		if err := helpers.RevokeTerminalTokens(repos, userId); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign out the terminals!"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Password changed!"})
+	}
+}
+
+// UpdateUserRole assigns a new role. The user's tokens are revoked so the
+// new role takes effect on the next login instead of when the current
+// access token expires.
+func UpdateUserRole(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userId := c.Param("user_id")
+
+		var body roleChange
+
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !helpers.IsValidRole(body.User_type) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user type!"})
+			return
+		}
+
+		if userId == c.GetString("uid") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot change your own role!"})
+			return
+		}
+
+		foundUser, err := repos.Users.Get(ctx, userId)
+
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User was not found!"})
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change the role!"})
+			return
+		}
+
+		foundUser.User_type = &body.User_type
+		foundUser.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		if err := repos.Users.Update(ctx, foundUser, "user_type"); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change the role!"})
+			return
+		}
+
+		if err := helpers.RevokeAllUserTokens(repos, userId); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke the user's tokens!"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Role changed!"})
+	}
+}
+
+// DeactivateUser disables an account without deleting it, so orders and
+// invoices keep pointing at a valid user. Deactivated users cannot log in
+// or refresh their tokens.
+func DeactivateUser(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userId := c.Param("user_id")
+
+		if userId == c.GetString("uid") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot deactivate your own account!"})
+			return
+		}
+
+		foundUser, err := repos.Users.Get(ctx, userId)
+
+		if err != nil || foundUser.Deactivated_at != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Active user was not found!"})
+			return
+		}
+
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		foundUser.Deactivated_at = &now
+		foundUser.Updated_at = now
+
+		if err := repos.Users.Update(ctx, foundUser, "deactivated_at"); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to deactivate the user!"})
+			return
+		}
+
+		if err := helpers.RevokeAllUserTokens(repos, userId); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke the user's tokens!"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "User deactivated!"})
+	}
+}
+
+func ReactivateUser(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userId := c.Param("user_id")
+
+		foundUser, err := repos.Users.Get(ctx, userId)
+
+		if err != nil || foundUser.Deactivated_at == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Deactivated user was not found!"})
+			return
+		}
+
+		foundUser.Deactivated_at = nil
+		foundUser.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		if err := repos.Users.Update(ctx, foundUser, "deactivated_at"); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reactivate the user!"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "User reactivated!"})
+	}
+}
@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"golang-restaurant-management/helpers"
+	"golang-restaurant-management/models"
+	"golang-restaurant-management/repository"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type sessionView struct {
+	models.Session
+	Current bool `json:"current"`
+}
+
+func GetSessions(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		sessions, err := helpers.ListUserSessions(repos, c.GetString("uid"))
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while listing the sessions!"})
+			return
+		}
+
+		current := c.GetString("session_id")
+		views := []sessionView{}
+
+		for _, session := range sessions {
+			views = append(views, sessionView{Session: session, Current: session.Session_id == current})
+		}
+
+		c.JSON(http.StatusOK, views)
+	}
+}
+
+func RevokeSession(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		sessionId := c.Param("session_id")
+
+		session, err := helpers.FindSession(repos, sessionId)
+
+		if err != nil || session.User_id != c.GetString("uid") || session.Revoked_at != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session was not found!"})
+			return
+		}
+
+		if err := helpers.RevokeSession(repos, sessionId); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke the session!"})
+			return
+		}
+
+		if sessionId == c.GetString("session_id") {
+			clearAuthCookies(c)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Session revoked!"})
+	}
+}
+
+// RevokeOtherSessions signs the user out everywhere except on the device
+// making the request.
+func RevokeOtherSessions(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		current := c.GetString("session_id")
+
+		if current == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "This request is not part of a session!"})
+			return
+		}
+
+		if err := helpers.RevokeUserSessions(repos, c.GetString("uid"), current); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke the sessions!"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "All other sessions revoked!"})
+	}
+}
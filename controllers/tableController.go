@@ -2,52 +2,46 @@ package controllers
 
 import (
 	"context"
-	"golang-restaurant-management/database"
 	"golang-restaurant-management/helpers"
 	"golang-restaurant-management/models"
-	"log"
+	"golang-restaurant-management/repository"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var tableCollection *mongo.Collection = database.OpenCollection(database.Client, "table")
-
-func GetTables() gin.HandlerFunc {
+func GetTables(repos *repository.Repositories) gin.HandlerFunc {
 
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		if err := helpers.CheckUserType(c, "ADMIN"); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-
-		res, err := tableCollection.Find(ctx, bson.M{})
+		allTables, err := repos.Tables.List(ctx)
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list all the tables!"})
 			return
 		}
 
-		var allTables []bson.M
+		if c.GetString("table_id") != "" {
+			visible := []models.Table{}
 
-		if err = res.All(ctx, &allTables); err != nil {
-			log.Fatal(err)
-			return
+			for _, table := range allTables {
+				if orderTableInScope(c, &table.Table_id) {
+					visible = append(visible, table)
+				}
+			}
+
+			allTables = visible
 		}
 
 		c.JSON(http.StatusOK, allTables)
 	}
 }
 
-func GetTable() gin.HandlerFunc {
+func GetTable(repos *repository.Repositories) gin.HandlerFunc {
 
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
@@ -55,9 +49,17 @@ func GetTable() gin.HandlerFunc {
 
 		tableId := c.Param("table_id")
 
-		var table models.Table
+		if !orderTableInScope(c, &tableId) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This device may only see its own table!"})
+			return
+		}
+
+		table, err := repos.Tables.Get(ctx, tableId)
 
-		err := tableCollection.FindOne(ctx, bson.M{"table_id": tableId}).Decode(&table)
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Table was not found!"})
+			return
+		}
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load the table!"})
@@ -68,7 +70,7 @@ func GetTable() gin.HandlerFunc {
 	}
 }
 
-func CreateTable() gin.HandlerFunc {
+func CreateTable(repos *repository.Repositories) gin.HandlerFunc {
 
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
@@ -94,79 +96,90 @@ func CreateTable() gin.HandlerFunc {
 		table.ID = primitive.NewObjectID()
 		table.Table_id = table.ID.Hex()
 
-		res, insertErr := tableCollection.InsertOne(ctx, table)
-
-		if insertErr != nil {
+		if insertErr := repos.Tables.Create(ctx, table); insertErr != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Table item was not created!"})
 			return
 		}
 
-		c.JSON(http.StatusOK, res)
+		publishTable(helpers.EventTableCreated, table.Table_id, table)
 
+		c.JSON(http.StatusOK, table)
 	}
 }
 
-func UpdateTable() gin.HandlerFunc {
+func UpdateTable(repos *repository.Repositories) gin.HandlerFunc {
 
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		var table models.Table
+		var body models.Table
 		tableId := c.Param("table_id")
 
-		if err := c.BindJSON(&table); err != nil {
+		if !orderTableInScope(c, &tableId) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This device may only change its own table!"})
+			return
+		}
+
+		if err := c.BindJSON(&body); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		var updatedObj primitive.D
+		table, err := repos.Tables.Get(ctx, tableId)
 
-		if table.Number_of_guests != nil {
-			updatedObj = append(updatedObj, bson.E{Key: "number_of_guests", Value: table.Number_of_guests})
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Table was not found!"})
+			return
 		}
 
-		if table.Table_number != nil {
-			updatedObj = append(updatedObj, bson.E{Key: "table_number", Value: table.Table_number})
+		if body.Number_of_guests != nil {
+			table.Number_of_guests = body.Number_of_guests
 		}
 
-		table.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-
-		upsert := true
-		opt := options.UpdateOptions{
-			Upsert: &upsert,
+		if body.Table_number != nil {
+			table.Table_number = body.Table_number
 		}
 
-		filter := bson.M{"table_id": tableId}
-
-		res, err := tableCollection.UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: updatedObj}}, &opt)
+		table.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 
-		if err != nil {
+		if err := repos.Tables.Update(ctx, table); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Table item update failed!"})
 			return
 		}
 
-		c.JSON(http.StatusOK, res)
+		publishTable(helpers.EventTableUpdated, table.Table_id, table)
+
+		c.JSON(http.StatusOK, table)
 	}
 }
 
-func DeleteTable() gin.HandlerFunc {
+func DeleteTable(repos *repository.Repositories) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
 		tableId := c.Param("table_id")
 
-		filter := bson.M{"table_id": tableId}
+		if !orderTableInScope(c, &tableId) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This device may only change its own table!"})
+			return
+		}
+
+		err := repos.Tables.Delete(ctx, tableId)
 
-		res, err := tableCollection.DeleteOne(ctx, filter)
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Table was not found!"})
+			return
+		}
 
 		if err != nil {
-			log.Fatal(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete the table!"})
 			return
 		}
 
-		c.JSON(http.StatusOK, res)
+		publishTable(helpers.EventTableDeleted, tableId, gin.H{"table_id": tableId})
 
+		c.JSON(http.StatusOK, gin.H{"message": "Table deleted!"})
 	}
 }
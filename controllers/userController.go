@@ -2,105 +2,167 @@ package controllers
 
 import (
 	"context"
-	"golang-restaurant-management/database"
 	"golang-restaurant-management/helpers"
 	"golang-restaurant-management/models"
+	"golang-restaurant-management/repository"
 	"log"
+	"math"
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/crypto/bcrypt"
 )
 
-var userCollection *mongo.Collection = database.OpenCollection(database.Client, "user")
-
-func GetUsers() gin.HandlerFunc {
+func GetUsers(repos *repository.Repositories) gin.HandlerFunc {
 
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		if err := helpers.CheckUserType(c, "ADMIN"); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		users, total, err := repos.Users.List(ctx, pageFromQuery(c))
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while listing user items!"})
 			return
 		}
 
-		recordPerPage, err := strconv.Atoi(c.Query("recordPerPage"))
-
-		if err != nil || recordPerPage < 1 {
-			recordPerPage = 10
+		for i := range users {
+			users[i] = publicUser(users[i])
 		}
 
-		page, err1 := strconv.Atoi(c.Query("page"))
+		c.JSON(http.StatusOK, gin.H{"total_count": total, "user_items": users})
 
-		if err1 != nil || page < 1 {
-			page = 1
-		}
+	}
+}
 
-		startIndex := (page - 1) * recordPerPage
+func GetUser(repos *repository.Repositories) gin.HandlerFunc {
 
-		startIndex, err = strconv.Atoi(c.Query("startIndex"))
+	return func(c *gin.Context) {
 
-		matchStage := bson.D{{Key: "$match", Value: bson.D{{}}}}
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
 
-		projectStage := bson.D{{Key: "$project", Value: bson.D{
-			{Key: "_id", Value: 0},
-			{Key: "total_count", Value: 1},
-			{Key: "user_items", Value: bson.D{{Key: "$slice", Value: []interface{}{"$data", startIndex, recordPerPage}}}},
-		}}}
+		userId := c.Param("user_id")
 
-		res, err := userCollection.Aggregate(ctx, mongo.Pipeline{
-			matchStage, projectStage,
-		})
+		user, err := repos.Users.Get(ctx, userId)
 
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while listing user items!"})
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User was not found!"})
+			return
 		}
 
-		var allUsers []bson.M
-
-		if err = res.All(ctx, &allUsers); err != nil {
-			log.Fatal(err)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while listing user item!"})
+			return
 		}
 
-		c.JSON(http.StatusOK, allUsers[0])
-
+		c.JSON(http.StatusOK, publicUser(user))
 	}
 }
 
-func GetUser() gin.HandlerFunc {
+func SignUp(repos *repository.Repositories) gin.HandlerFunc {
 
 	return func(c *gin.Context) {
 
-		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-		defer cancel()
+		if !helpers.PublicSignupEnabled() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Public signup is disabled, ask a manager for an invitation!"})
+			return
+		}
 
-		userId := c.Param("user_id")
+		var user models.User
 
-		if err := helpers.MatchUserTypeToUid(c, userId); err != nil {
+		if err := c.BindJSON(&user); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		var user models.User
+		// Self-registered accounts always get the least privileged role;
+		// staff roles are assigned by an admin.
+		role := helpers.RoleUser
+		user.User_type = &role
 
-		err := userCollection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user)
+		createUser(c, repos, user)
+	}
+}
 
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while listing user item!"})
+func CreateUser(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+
+		var user models.User
+
+		if err := c.BindJSON(&user); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		c.JSON(http.StatusOK, user)
+		createUser(c, repos, user)
+	}
+}
+
+func createUser(c *gin.Context, repos *repository.Repositories, user models.User) {
+	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	validationErr := validate.Struct(user)
+
+	if validationErr != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+		return
+	}
+
+	taken, err := repos.Users.EmailOrPhoneTaken(ctx, *user.Email, *user.Phone, "")
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while checking user existence!"})
+		return
+	}
+
+	if taken {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email or phone already exists!"})
+		return
+	}
+
+	password := HashPassword(*user.Password)
+	user.Password = &password
+
+	user.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	user.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+	user.ID = primitive.NewObjectID()
+	user.User_id = user.ID.Hex()
+	user.Email_verified = false
+	user.Mfa_enabled = false
+	user.Deactivated_at = nil
+
+	// New accounts get their tokens by logging in, so neither signup nor an
+	// admin creating the account can act as the user or skip MFA.
+	user.Token = nil
+	user.Refresh_Token = nil
+
+	if insertErr := repos.Users.Create(ctx, user); insertErr != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "User was not created!"})
+		return
 	}
+
+	sendVerificationEmailInBackground(repos, user)
+
+	c.JSON(http.StatusOK, publicUser(user))
+}
+
+// publicUser strips the password hash from a user before it is sent to a
+// client.
+func publicUser(user models.User) models.User {
+	user.Password = nil
+
+	return user
 }
 
-func SignUp() gin.HandlerFunc {
+func Login(repos *repository.Repositories) gin.HandlerFunc {
 
 	return func(c *gin.Context) {
 
@@ -114,98 +176,333 @@ func SignUp() gin.HandlerFunc {
 			return
 		}
 
-		validationErr := validate.Struct(user)
-
-		if validationErr != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+		if user.Email == nil || user.Password == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Email and password are required!"})
 			return
 		}
 
-		filter := bson.M{"$or": []bson.M{{"email": user.Email}, {"phone": user.Phone}}}
+		accountKey := helpers.AccountAttemptKey(*user.Email)
+		attemptKeys := []string{accountKey, helpers.IPAttemptKey(c.ClientIP())}
 
-		count, err := userCollection.CountDocuments(ctx, filter)
+		wait, err := helpers.LoginRetryAfter(repos, attemptKeys...)
 
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while checking user existence!"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check login attempts!"})
 			return
 		}
 
-		if count > 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Email or phone already exists!"})
+		if wait > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed login attempts. Please try again later!"})
 			return
 		}
 
-		password := HashPassword(*user.Password)
-		user.Password = &password
+		foundUser, err := repos.Users.GetByEmail(ctx, *user.Email)
 
-		user.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-		user.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		if err != nil || foundUser.Password == nil {
+			// Spend the same bcrypt time as for a real account so response
+			// timing does not reveal whether the email exists.
+			VerifyPassword(*user.Password, dummyPasswordHash())
+			failLogin(c, repos, attemptKeys)
+			return
+		}
+
+		if passwordIsValid, _ := VerifyPassword(*user.Password, *foundUser.Password); !passwordIsValid {
+			failLogin(c, repos, attemptKeys)
+			return
+		}
 
-		user.ID = primitive.NewObjectID()
-		user.User_id = user.ID.Hex()
+		if err := helpers.ResetLoginFailures(repos, accountKey); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset login attempts!"})
+			return
+		}
 
-		var token, refreshToken, _ = helpers.GenerateAllTokens(*user.Email, *user.First_name, *user.Last_name, user.User_id, *user.User_type)
+		if foundUser.Deactivated_at != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This account has been deactivated!"})
+			return
+		}
 
-		user.Token = &token
+		if foundUser.Mfa_enabled || helpers.MfaRequiredForRole(*foundUser.User_type) {
+			enroll := !foundUser.Mfa_enabled
 
-		user.Refresh_Token = &refreshToken
+			mfaToken, err := helpers.GenerateMfaPendingToken(foundUser.User_id, enroll)
 
-		resultInsertionNumber, insertErr := userCollection.InsertOne(ctx, user)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate the MFA token!"})
+				return
+			}
 
-		if insertErr != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "User was not created!"})
+			c.JSON(http.StatusOK, gin.H{"mfa_required": true, "mfa_enrollment_required": enroll, "mfa_token": mfaToken})
 			return
 		}
 
-		c.JSON(http.StatusOK, resultInsertionNumber)
+		completeLogin(c, repos, foundUser)
+	}
+}
 
+// completeLogin issues the token pair once every login factor has been
+// verified.
+func completeLogin(c *gin.Context, repos *repository.Repositories, foundUser models.User) {
+	if !issueLoginTokens(c, repos, &foundUser) {
+		return
 	}
+
+	c.JSON(http.StatusOK, publicUser(foundUser))
 }
 
-func Login() gin.HandlerFunc {
+// issueLoginTokens starts a new session for the device the request came
+// from and issues the session's first token pair.
+func issueLoginTokens(c *gin.Context, repos *repository.Repositories, foundUser *models.User) bool {
+	var session models.Session
+
+	session.ID = primitive.NewObjectID()
+	session.Session_id = session.ID.Hex()
+	session.User_id = foundUser.User_id
+	session.Ip = c.ClientIP()
+	session.User_agent = c.Request.UserAgent()
+	session.Device_name = c.GetHeader("X-Device-Name")
+
+	if session.Device_name == "" {
+		session.Device_name = session.User_agent
+	}
+
+	token, refreshToken, err := helpers.GenerateTokensForFamily(*foundUser.Email, *foundUser.First_name, *foundUser.Last_name, foundUser.User_id, *foundUser.User_type, session.Session_id)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens!"})
+		return false
+	}
+
+	session.Refresh_token_hash = helpers.HashOpaqueToken(refreshToken)
+	session.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	session.Last_seen_at = session.Created_at
+	session.Expires_at = session.Created_at.Add(helpers.RefreshTokenLifetime)
+
+	if err := helpers.CreateSession(repos, session); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create the session!"})
+		return false
+	}
+
+	setAuthCookies(c, token, refreshToken)
+
+	foundUser.Token = &token
+	foundUser.Refresh_Token = &refreshToken
+
+	return true
+}
+
+func failLogin(c *gin.Context, repos *repository.Repositories, attemptKeys []string) {
+	if err := helpers.RecordLoginFailure(repos, attemptKeys...); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record the login attempt!"})
+		return
+	}
+
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "Email or password is incorrect!"})
+}
+
+var dummyHashOnce sync.Once
+var dummyHash string
+
+func dummyPasswordHash() string {
+	dummyHashOnce.Do(func() {
+		dummyHash = HashPassword("not-a-real-password")
+	})
+
+	return dummyHash
+}
+
+func UnlockUser(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		foundUser, err := repos.Users.Get(ctx, c.Param("user_id"))
+
+		if err != nil || foundUser.Email == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User was not found!"})
+			return
+		}
+
+		if err := helpers.ResetLoginFailures(repos, helpers.AccountAttemptKey(*foundUser.Email)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock the user!"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "User account unlocked!"})
+	}
+}
+
+type refreshRequest struct {
+	Refresh_token string `json:"refresh_token"`
+}
+
+func Refresh(repos *repository.Repositories) gin.HandlerFunc {
 
 	return func(c *gin.Context) {
 
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		var user models.User
-		var foundUser models.User
+		var body refreshRequest
 
-		if err := c.BindJSON(&user); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		presentedToken, err := c.Cookie("refresh_token")
+
+		if err == nil && presentedToken != "" && !helpers.ValidCSRF(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "CSRF token is missing or invalid!"})
+			return
+		}
+
+		if err != nil || presentedToken == "" {
+			if err := c.ShouldBindJSON(&body); err != nil || body.Refresh_token == "" {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token is required!"})
+				return
+			}
+			presentedToken = body.Refresh_token
+		}
+
+		claims, msg := helpers.ValidateRefreshToken(presentedToken)
+
+		if msg != "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": msg})
 			return
 		}
 
-		err := userCollection.FindOne(ctx, bson.M{"email": user.Email}).Decode(&foundUser)
+		session, err := helpers.FindSession(repos, claims.Family)
+
+		if err != nil || session.User_id != claims.Uid || session.Revoked_at != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "The refresh token is invalid!"})
+			return
+		}
+
+		if session.Refresh_token_hash != helpers.HashOpaqueToken(presentedToken) {
+			rejectRefreshToken(c, repos, session)
+			return
+		}
+
+		foundUser, err := repos.Users.Get(ctx, claims.Uid)
+
+		if err != nil || foundUser.Deactivated_at != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "The refresh token is invalid!"})
+			return
+		}
+
+		token, refreshToken, err := helpers.GenerateTokensForFamily(*foundUser.Email, *foundUser.First_name, *foundUser.Last_name, foundUser.User_id, *foundUser.User_type, session.Session_id)
 
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "User was not found. Please Signup!"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens!"})
 			return
 		}
 
-		passwordIsValid, msg := VerifyPassword(*user.Password, *foundUser.Password)
+		err = helpers.RotateSessionRefreshToken(repos, session.Session_id, presentedToken, refreshToken)
 
-		if passwordIsValid != true {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		if err == helpers.ErrRefreshTokenReused {
+			rejectRefreshToken(c, repos, session)
 			return
 		}
 
-		if foundUser.Email == nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "User was not found!"})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate the refresh token!"})
 			return
 		}
 
-		token, refreshToken, _ := helpers.GenerateAllTokens(*foundUser.Email, *foundUser.First_name, *foundUser.Last_name, foundUser.User_id, *foundUser.User_type)
+		setAuthCookies(c, token, refreshToken)
 
-		c.SetCookie("token", token, 3600, "/", "", false, true)
+		c.JSON(http.StatusOK, gin.H{"token": token, "refresh_token": refreshToken})
+	}
+}
+
+// rejectRefreshToken answers a refresh attempt with a correctly signed token
+// of a live session that is no longer the session's current one. It was
+// stolen or replayed, so the whole session is revoked and the device has to
+// log in again.
+func rejectRefreshToken(c *gin.Context, repos *repository.Repositories, session models.Session) {
+	if err := helpers.RevokeSession(repos, session.Session_id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke the session!"})
+		return
+	}
+
+	clearAuthCookies(c)
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token reuse detected. Please login again!"})
+}
 
-		helpers.UpdateAllTokens(token, refreshToken, foundUser.User_id)
+func Logout(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+
+		uid := c.GetString("uid")
+
+		if c.GetString("auth_method") == "api_key" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "API keys cannot log out; ask an admin to revoke the key!"})
+			return
+		}
 
-		c.JSON(http.StatusOK, foundUser)
+		// PIN sessions on terminals are not tracked as sessions, so only
+		// their token is denylisted.
+		if sessionId := c.GetString("session_id"); sessionId != "" {
+			if err := helpers.RevokeSession(repos, sessionId); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke the session!"})
+				return
+			}
+		} else if err := helpers.RevokeToken(repos, c.GetString("jti"), uid, c.GetTime("token_expires_at")); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke the token!"})
+			return
+		}
+
+		clearAuthCookies(c)
+
+		c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully!"})
 	}
 }
 
+func ForceLogout(repos *repository.Repositories) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userId := c.Param("user_id")
+
+		_, err := repos.Users.Get(ctx, userId)
+
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User was not found!"})
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while checking user existence!"})
+			return
+		}
+
+		if err := helpers.RevokeAllUserTokens(repos, userId); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke the user's tokens!"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "User was logged out from all devices!"})
+	}
+}
+
+// setAuthCookies also issues the CSRF token that cookie-authenticated
+// clients must echo in the X-CSRF-Token header. It is deliberately readable
+// from JavaScript.
+func setAuthCookies(c *gin.Context, token string, refreshToken string) {
+	c.SetCookie("token", token, 3600, "/", "", false, true)
+	c.SetCookie("refresh_token", refreshToken, 3600*24*7, "/users/refresh", "", false, true)
+
+	if csrfToken, err := helpers.GenerateOpaqueToken(); err == nil {
+		c.SetCookie(helpers.CSRFCookieName, csrfToken, 3600*24*7, "/", "", false, false)
+	}
+}
+
+func clearAuthCookies(c *gin.Context) {
+	c.SetCookie("token", "", -1, "/", "", false, true)
+	c.SetCookie("refresh_token", "", -1, "/users/refresh", "", false, true)
+	c.SetCookie(helpers.CSRFCookieName, "", -1, "/", "", false, false)
+}
+
 func HashPassword(password string) string {
 	hashedBytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
 	if err != nil {
@@ -221,7 +518,7 @@ func VerifyPassword(userPassword string, providedPassword string) (bool, string)
 	msg := ""
 
 	if err != nil {
-		msg = "Email or passowrd is icorrect!"
+		msg = "Email or password is incorrect!"
 		check = false
 	}
 
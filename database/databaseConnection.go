@@ -3,22 +3,17 @@ package database
 import (
 	"context"
 	"fmt"
-	"log"
 	"os"
 	"time"
 
-	"github.com/joho/godotenv"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-func DBInstance() *mongo.Client {
-	err := godotenv.Load(".env")
-
-	if err != nil {
-		log.Fatal("Error loading .env file")
-	}
-
+// Connect opens a client for MONGODB_URL. Nothing connects at import time,
+// so packages that only need the repository interfaces can be used without
+// a database.
+func Connect() (*mongo.Client, error) {
 	MONGODB_URL := os.Getenv("MONGODB_URL")
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -26,18 +21,14 @@ func DBInstance() *mongo.Client {
 
 	client, err := mongo.Connect(ctx, options.Client().ApplyURI(MONGODB_URL))
 	if err != nil {
-		log.Fatal("Failed to connect to database!", err)
+		return nil, err
 	}
 
 	fmt.Println("Connected to MongoDB successfully!")
-	return client
+	return client, nil
 
 }
 
-var Client *mongo.Client = DBInstance()
-
-func OpenCollection(client *mongo.Client, collectionName string) *mongo.Collection {
-	var collection *mongo.Collection = client.Database("restaurant").Collection(collectionName)
-
-	return collection
+func OpenDatabase(client *mongo.Client) *mongo.Database {
+	return client.Database("restaurant")
 }
@@ -0,0 +1,13 @@
+package helpers
+
+import "golang-restaurant-management/models"
+
+func IsValidReasonCode(code string) bool {
+	switch code {
+	case models.ReasonOrderError, models.ReasonGuestChangedMind, models.ReasonQualityIssue,
+		models.ReasonLongWait, models.ReasonHospitality:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,59 @@
+package helpers
+
+import (
+	"context"
+	"golang-restaurant-management/models"
+	"golang-restaurant-management/repository"
+	"log"
+	"time"
+)
+
+const ApiKeyHeader = "X-API-Key"
+
+// apiKeyUseInterval limits how often last_used_at is written.
+const apiKeyUseInterval = time.Minute
+
+// GenerateApiKey returns a new plaintext key together with the prefix shown
+// in listings. Only HashApiKey(key) is ever stored.
+func GenerateApiKey() (key string, prefix string, err error) {
+	secret, err := GenerateOpaqueToken()
+
+	if err != nil {
+		return "", "", err
+	}
+
+	return "rk_" + secret, secret[:8], nil
+}
+
+func HashApiKey(key string) string {
+	return HashOpaqueToken(key)
+}
+
+// FindActiveApiKey looks the key up by its hash and records the use, at most
+// once per apiKeyUseInterval. It returns repository.ErrNotFound for unknown
+// or revoked keys. Failing to record the use does not fail the lookup.
+func FindActiveApiKey(repos *repository.Repositories, key string) (apiKey models.ApiKey, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	apiKey, err = repos.ApiKeys.GetActiveByHash(ctx, HashApiKey(key))
+
+	if err != nil {
+		return apiKey, err
+	}
+
+	Last_used_at, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	staleBefore := Last_used_at.Add(-apiKeyUseInterval)
+
+	if apiKey.Last_used_at != nil && !apiKey.Last_used_at.Before(staleBefore) {
+		return apiKey, nil
+	}
+
+	if err := repos.ApiKeys.MarkUsed(ctx, apiKey.Api_key_id, Last_used_at, staleBefore); err != nil {
+		log.Println("Failed to record the use of API key", apiKey.Api_key_id+":", err)
+	}
+
+	apiKey.Last_used_at = &Last_used_at
+
+	return apiKey, nil
+}
@@ -1,35 +0,0 @@
-package helpers
-
-import (
-	"errors"
-
-	"github.com/gin-gonic/gin"
-)
-
-func CheckUserType(c *gin.Context, role string) (err error) {
-	userType := c.GetString("user_type")
-	err = nil
-
-	if userType != role {
-		err = errors.New("unauthorized access")
-		return err
-	}
-
-	return err
-}
-
-func MatchUserTypeToUid(c *gin.Context, userId string) (err error) {
-	userType := c.GetString("user_type")
-
-	uid := c.GetString("user_id")
-
-	err = nil
-
-	if userType == "USER" && uid != userId {
-		err = errors.New("unauthorized access")
-		return err
-	}
-
-	err = CheckUserType(c, userType)
-	return err
-}
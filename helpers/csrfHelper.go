@@ -0,0 +1,32 @@
+package helpers
+
+import (
+	"crypto/subtle"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	CSRFCookieName = "csrf_token"
+	CSRFHeaderName = "X-CSRF-Token"
+)
+
+// IsSafeMethod reports whether the request method cannot change state and
+// therefore needs no CSRF protection.
+func IsSafeMethod(method string) bool {
+	return method == "GET" || method == "HEAD" || method == "OPTIONS"
+}
+
+// ValidCSRF implements the double-submit check: the csrf_token cookie must
+// be echoed back in the X-CSRF-Token header.
+func ValidCSRF(c *gin.Context) bool {
+	cookie, err := c.Cookie(CSRFCookieName)
+
+	if err != nil || cookie == "" {
+		return false
+	}
+
+	header := c.GetHeader(CSRFHeaderName)
+
+	return subtle.ConstantTimeCompare([]byte(cookie), []byte(header)) == 1
+}
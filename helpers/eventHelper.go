@@ -0,0 +1,195 @@
+package helpers
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event types published on the event bus.
+const (
+	EventOrderCreated     = "order.created"
+	EventOrderUpdated     = "order.updated"
+	EventOrderItemCreated = "order_item.created"
+	EventOrderItemUpdated = "order_item.updated"
+	EventOrderItemDeleted = "order_item.deleted"
+	EventTicketCreated    = "ticket.created"
+	EventTicketUpdated    = "ticket.updated"
+	EventTableCreated     = "table.created"
+	EventTableUpdated     = "table.updated"
+	EventTableDeleted     = "table.deleted"
+	EventInvoiceCreated   = "invoice.created"
+	EventInvoiceUpdated   = "invoice.updated"
+	EventInvoiceDeleted   = "invoice.deleted"
+)
+
+// Topics group events for subscribers. Besides the fixed ones below, every
+// order, table and kitchen station has a topic of its own.
+const (
+	TopicOrders   = "orders"
+	TopicTables   = "tables"
+	TopicInvoices = "invoices"
+)
+
+const (
+	orderTopicPrefix   = "order:"
+	tableTopicPrefix   = "table:"
+	stationTopicPrefix = "station:"
+)
+
+// subscriptionBuffer is how many events a subscriber may fall behind before
+// further events are dropped for it.
+const subscriptionBuffer = 64
+
+func OrderTopic(orderId string) string {
+	return orderTopicPrefix + orderId
+}
+
+func TableTopic(tableId string) string {
+	return tableTopicPrefix + tableId
+}
+
+func StationTopic(station string) string {
+	return stationTopicPrefix + station
+}
+
+// TopicPermission returns the permission needed to subscribe to a topic, or
+// false for topics that do not exist.
+func TopicPermission(topic string) (string, bool) {
+	switch {
+	case topic == TopicOrders:
+		return PermOrderRead, true
+	case topic == TopicTables:
+		return PermTableRead, true
+	case topic == TopicInvoices:
+		return PermInvoiceRead, true
+	case topicId(topic, orderTopicPrefix) != "", topicId(topic, tableTopicPrefix) != "", topicId(topic, stationTopicPrefix) != "":
+		return PermOrderRead, true
+	}
+
+	return "", false
+}
+
+// EventPermission returns the permission needed to receive an event. Topics
+// mix events about different things, e.g. an order's topic also carries its
+// invoices, so every event is checked against the permission of its own
+// data as well.
+func EventPermission(eventType string) string {
+	switch {
+	case strings.HasPrefix(eventType, "table."):
+		return PermTableRead
+	case strings.HasPrefix(eventType, "invoice."):
+		return PermInvoiceRead
+	}
+
+	return PermOrderRead
+}
+
+// TopicInScope reports whether a device scoped to a table and/or a station
+// may subscribe to the topic. Scoped devices only get their own table's or
+// station's topic; unscoped callers may subscribe to anything.
+func TopicInScope(topic string, tableId string, station string) bool {
+	if tableId == "" && station == "" {
+		return true
+	}
+
+	return (tableId != "" && topic == TableTopic(tableId)) || (station != "" && topic == StationTopic(station))
+}
+
+func topicId(topic string, prefix string) string {
+	if !strings.HasPrefix(topic, prefix) {
+		return ""
+	}
+
+	return topic[len(prefix):]
+}
+
+type Event struct {
+	Type   string      `json:"type"`
+	Topics []string    `json:"topics"`
+	Data   interface{} `json:"data"`
+	At     time.Time   `json:"at"`
+}
+
+// Subscription receives the events of its topics on Events until it is
+// unsubscribed, which closes the channel.
+type Subscription struct {
+	Events <-chan Event
+
+	events chan Event
+	topics map[string]bool
+}
+
+// EventBus fans events out to the subscribers of their topics. Publishing
+// never blocks: subscribers that fall too far behind miss events and are
+// expected to reload what they show.
+type EventBus struct {
+	mu            sync.RWMutex
+	subscriptions map[*Subscription]struct{}
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{subscriptions: map[*Subscription]struct{}{}}
+}
+
+// DefaultEventBus carries the change events of this server instance.
+var DefaultEventBus = NewEventBus()
+
+func (b *EventBus) Subscribe(topics []string) *Subscription {
+	events := make(chan Event, subscriptionBuffer)
+	sub := &Subscription{Events: events, events: events, topics: map[string]bool{}}
+
+	for _, topic := range topics {
+		sub.topics[topic] = true
+	}
+
+	b.mu.Lock()
+	b.subscriptions[sub] = struct{}{}
+	b.mu.Unlock()
+
+	return sub
+}
+
+func (b *EventBus) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscriptions[sub]; !ok {
+		return
+	}
+
+	delete(b.subscriptions, sub)
+	close(sub.events)
+}
+
+// Publish sends an event to everyone subscribed to at least one of the
+// topics. Each subscriber gets the event once.
+func (b *EventBus) Publish(eventType string, data interface{}, topics ...string) {
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+	event := Event{Type: eventType, Topics: topics, Data: data, At: now}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subscriptions {
+		if !sub.wants(topics) {
+			continue
+		}
+
+		select {
+		case sub.events <- event:
+		default:
+		}
+	}
+}
+
+func (s *Subscription) wants(topics []string) bool {
+	for _, topic := range topics {
+		if s.topics[topic] {
+			return true
+		}
+	}
+
+	return false
+}
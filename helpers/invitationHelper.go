@@ -0,0 +1,40 @@
+package helpers
+
+import (
+	"context"
+	"golang-restaurant-management/models"
+	"golang-restaurant-management/repository"
+	"os"
+	"time"
+)
+
+// PublicSignupEnabled reports whether POST /users/signup is open. Setting
+// PUBLIC_SIGNUP_ENABLED=false restricts new accounts to invited staff.
+func PublicSignupEnabled() bool {
+	return os.Getenv("PUBLIC_SIGNUP_ENABLED") != "false"
+}
+
+// FindPendingInvitation looks an invitation up by its plaintext token.
+// Unknown, expired, revoked and accepted invitations all yield
+// repository.ErrNotFound.
+func FindPendingInvitation(repos *repository.Repositories, plaintext string) (models.Invitation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+	return repos.Invitations.GetPendingByHash(ctx, HashOpaqueToken(plaintext), now)
+}
+
+// AcceptInvitation marks a pending invitation as accepted by userId. It
+// returns repository.ErrNotFound when the invitation was accepted, revoked
+// or expired in the meantime, so the same invitation cannot create two
+// users.
+func AcceptInvitation(repos *repository.Repositories, invitationId string, userId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+	return repos.Invitations.Accept(ctx, invitationId, userId, now)
+}
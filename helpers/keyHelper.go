@@ -0,0 +1,446 @@
+package helpers
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Tokens are signed with HS256 and SECRET_KEY unless JWT_SIGNING_ALG selects
+// RS256 or EdDSA. Asymmetric keys are identified by the kid header, so
+// several of them can be valid for verification at once while only the
+// newest one signs. Their public halves are published as a JWKS.
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// keyCreatedAtHeader is the PEM header of a key file holding the key's
+// creation time.
+const keyCreatedAtHeader = "Created-At"
+
+// keyCheckInterval is how often StartKeyRotation looks for a due rotation.
+const keyCheckInterval = time.Minute
+
+type signingKey struct {
+	kid       string
+	alg       string
+	private   crypto.Signer
+	createdAt time.Time
+	retiredAt *time.Time
+}
+
+type keyring struct {
+	mu     sync.RWMutex
+	alg    string
+	dir    string
+	active *signingKey
+	keys   map[string]*signingKey
+}
+
+var signingKeys = &keyring{alg: AlgHS256, keys: map[string]*signingKey{}}
+
+// InitSigningKeys reads the signing configuration. Keys are loaded from the
+// PKCS#8 PEM files in JWT_KEYS_DIR, named <kid>.pem; the newest one becomes
+// the active signing key. Without any key file a new key is generated and,
+// if JWT_KEYS_DIR is set, saved there.
+func InitSigningKeys() error {
+	alg := os.Getenv("JWT_SIGNING_ALG")
+
+	if alg == "" {
+		alg = AlgHS256
+	}
+
+	if alg != AlgHS256 && alg != AlgRS256 && alg != AlgEdDSA {
+		return fmt.Errorf("unsupported JWT_SIGNING_ALG %q", alg)
+	}
+
+	signingKeys.mu.Lock()
+	defer signingKeys.mu.Unlock()
+
+	signingKeys.alg = alg
+	signingKeys.dir = os.Getenv("JWT_KEYS_DIR")
+	signingKeys.keys = map[string]*signingKey{}
+	signingKeys.active = nil
+
+	if alg == AlgHS256 {
+		return nil
+	}
+
+	if signingKeys.dir != "" {
+		if err := signingKeys.loadDir(); err != nil {
+			return err
+		}
+	}
+
+	if signingKeys.active == nil {
+		log.Println("No JWT signing key found, generating a new one")
+		return signingKeys.rotate()
+	}
+
+	return nil
+}
+
+// loadDir reads the keys in k.dir. A key file records when its key was
+// created, and the previous key is retired at that moment, so each key's
+// retirement time is the creation time of the key that followed it. Keys
+// retired for longer than any token lives are pruned.
+func (k *keyring) loadDir() error {
+	entries, err := os.ReadDir(k.dir)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	var loaded []*signingKey
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".pem") {
+			continue
+		}
+
+		key, err := readSigningKey(filepath.Join(k.dir, entry.Name()))
+
+		if err != nil {
+			return err
+		}
+
+		if key.alg != k.alg {
+			continue
+		}
+
+		loaded = append(loaded, key)
+	}
+
+	sort.Slice(loaded, func(i, j int) bool { return loaded[i].createdAt.Before(loaded[j].createdAt) })
+
+	for i, key := range loaded {
+		if i+1 < len(loaded) {
+			retiredAt := loaded[i+1].createdAt
+			key.retiredAt = &retiredAt
+		}
+
+		k.keys[key.kid] = key
+		k.active = key
+	}
+
+	k.prune(time.Now())
+
+	return nil
+}
+
+func readSigningKey(path string) (*signingKey, error) {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+
+	if block == nil {
+		return nil, fmt.Errorf("%s does not contain a PEM block", path)
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	createdAt, err := keyCreatedAt(path, block)
+
+	if err != nil {
+		return nil, err
+	}
+
+	key := &signingKey{
+		kid:       strings.TrimSuffix(filepath.Base(path), ".pem"),
+		createdAt: createdAt,
+	}
+
+	switch private := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.alg, key.private = AlgRS256, private
+	case ed25519.PrivateKey:
+		key.alg, key.private = AlgEdDSA, private
+	default:
+		return nil, fmt.Errorf("%s: unsupported key type", path)
+	}
+
+	return key, nil
+}
+
+// keyCreatedAt reads the creation time from the key file's header, so it
+// survives copying or restoring the directory. Files written before the
+// header existed fall back to their modification time.
+func keyCreatedAt(path string, block *pem.Block) (time.Time, error) {
+	if header, ok := block.Headers[keyCreatedAtHeader]; ok {
+		createdAt, err := time.Parse(time.RFC3339Nano, header)
+
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%s: invalid %s header: %w", path, keyCreatedAtHeader, err)
+		}
+
+		return createdAt, nil
+	}
+
+	info, err := os.Stat(path)
+
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return info.ModTime(), nil
+}
+
+// rotate generates a new active key. The previous key is retired: it no
+// longer signs but keeps verifying until every token it signed has expired.
+// The caller must hold k.mu.
+func (k *keyring) rotate() error {
+	key := &signingKey{
+		kid:       primitive.NewObjectID().Hex(),
+		alg:       k.alg,
+		createdAt: time.Now(),
+	}
+
+	switch k.alg {
+	case AlgRS256:
+		private, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return err
+		}
+		key.private = private
+	case AlgEdDSA:
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return err
+		}
+		key.private = private
+	default:
+		return fmt.Errorf("keys cannot be rotated for %s", k.alg)
+	}
+
+	if k.dir != "" {
+		if err := writeSigningKey(k.dir, key); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+
+	if k.active != nil {
+		k.active.retiredAt = &now
+	}
+
+	k.keys[key.kid] = key
+	k.active = key
+
+	k.prune(now)
+
+	return nil
+}
+
+// prune forgets keys that were retired long enough ago that every token they
+// signed has expired, and deletes their files. The caller must hold k.mu.
+func (k *keyring) prune(now time.Time) {
+	for kid, old := range k.keys {
+		if old.retiredAt == nil || now.Sub(*old.retiredAt) <= RefreshTokenLifetime {
+			continue
+		}
+
+		delete(k.keys, kid)
+
+		if k.dir == "" {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(k.dir, kid+".pem")); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Println("Failed to delete retired JWT signing key:", err)
+		}
+	}
+}
+
+func writeSigningKey(dir string, key *signingKey) error {
+	der, err := x509.MarshalPKCS8PrivateKey(key.private)
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	data := pem.EncodeToMemory(&pem.Block{
+		Type:    "PRIVATE KEY",
+		Headers: map[string]string{keyCreatedAtHeader: key.createdAt.UTC().Format(time.RFC3339Nano)},
+		Bytes:   der,
+	})
+
+	return os.WriteFile(filepath.Join(dir, key.kid+".pem"), data, 0o600)
+}
+
+// RotateSigningKey switches to a freshly generated signing key.
+func RotateSigningKey() error {
+	signingKeys.mu.Lock()
+	defer signingKeys.mu.Unlock()
+
+	return signingKeys.rotate()
+}
+
+// rotateIfDue first reloads the key directory, which instances sharing it
+// use to agree on their keys: a key another instance rotated in is picked
+// up, and only when the newest key is older than interval is a new one
+// generated.
+func (k *keyring) rotateIfDue(interval time.Duration) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.dir != "" {
+		keys, active := k.keys, k.active
+		k.keys, k.active = map[string]*signingKey{}, nil
+
+		if err := k.loadDir(); err != nil {
+			k.keys, k.active = keys, active
+			return err
+		}
+	}
+
+	if k.active != nil && time.Since(k.active.createdAt) < interval {
+		return nil
+	}
+
+	return k.rotate()
+}
+
+// StartKeyRotation rotates the signing key every JWT_KEY_ROTATION_INTERVAL
+// (e.g. "720h"). It checks every minute, or every interval if that is
+// shorter, so keys rotated by other instances sharing JWT_KEYS_DIR are
+// picked up soon. It does nothing for HS256 or when no interval is set.
+func StartKeyRotation() {
+	interval, err := time.ParseDuration(os.Getenv("JWT_KEY_ROTATION_INTERVAL"))
+
+	if err != nil || interval <= 0 {
+		return
+	}
+
+	signingKeys.mu.RLock()
+	alg := signingKeys.alg
+	signingKeys.mu.RUnlock()
+
+	if alg == AlgHS256 {
+		return
+	}
+
+	check := min(interval, keyCheckInterval)
+
+	go func() {
+		for range time.Tick(check) {
+			if err := signingKeys.rotateIfDue(interval); err != nil {
+				log.Println("JWT signing key rotation failed:", err)
+			}
+		}
+	}()
+}
+
+// SignClaims signs the claims with the active key.
+func SignClaims(claims jwt.Claims) (string, error) {
+	signingKeys.mu.RLock()
+	active := signingKeys.active
+	signingKeys.mu.RUnlock()
+
+	if active == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(SECRET_KEY))
+	}
+
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(active.alg), claims)
+	token.Header["kid"] = active.kid
+
+	return token.SignedString(active.private)
+}
+
+// verificationKey is the jwt.Keyfunc for our tokens. Tokens with a kid are
+// checked against the matching public key; tokens without one are legacy
+// HS256 tokens and are only accepted while HS256 is the configured
+// algorithm or JWT_ACCEPT_HS256=true during a migration.
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	signingKeys.mu.RLock()
+	defer signingKeys.mu.RUnlock()
+
+	kid, _ := token.Header["kid"].(string)
+
+	if kid == "" {
+		if token.Method.Alg() != AlgHS256 {
+			return nil, errors.New("token has no key id")
+		}
+
+		if signingKeys.alg != AlgHS256 && os.Getenv("JWT_ACCEPT_HS256") != "true" {
+			return nil, errors.New("HS256 tokens are no longer accepted")
+		}
+
+		return []byte(SECRET_KEY), nil
+	}
+
+	key, ok := signingKeys.keys[kid]
+
+	if !ok {
+		return nil, errors.New("unknown signing key")
+	}
+
+	if token.Method.Alg() != key.alg {
+		return nil, errors.New("token algorithm does not match its key")
+	}
+
+	return key.private.Public(), nil
+}
+
+// JWKS returns the public verification keys as a JSON Web Key Set
+// (RFC 7517).
+func JWKS() map[string]interface{} {
+	signingKeys.mu.RLock()
+	defer signingKeys.mu.RUnlock()
+
+	keys := []map[string]string{}
+
+	for _, key := range signingKeys.keys {
+		jwk := map[string]string{"kid": key.kid, "alg": key.alg, "use": "sig"}
+
+		switch public := key.private.Public().(type) {
+		case *rsa.PublicKey:
+			jwk["kty"] = "RSA"
+			jwk["n"] = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk["e"] = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk["kty"] = "OKP"
+			jwk["crv"] = "Ed25519"
+			jwk["x"] = base64.RawURLEncoding.EncodeToString(public)
+		}
+
+		keys = append(keys, jwk)
+	}
+
+	return map[string]interface{}{"keys": keys}
+}
@@ -0,0 +1,126 @@
+package helpers
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadedKeysAreRetiredByTheirSuccessor(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	// Cleanups run last to first, so the keyring is reset after the
+	// environment is restored.
+	t.Cleanup(func() { InitSigningKeys() })
+	t.Setenv("JWT_SIGNING_ALG", AlgEdDSA)
+	t.Setenv("JWT_KEYS_DIR", dir)
+
+	// expired was replaced long before any live token was signed, previous
+	// was replaced yesterday and current signs.
+	created := map[string]time.Time{
+		"expired":  now.Add(-3 * RefreshTokenLifetime),
+		"previous": now.Add(-2 * RefreshTokenLifetime),
+		"current":  now.Add(-24 * time.Hour),
+	}
+
+	for kid, createdAt := range created {
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := writeSigningKey(dir, &signingKey{kid: kid, alg: AlgEdDSA, private: private, createdAt: createdAt}); err != nil {
+			t.Fatal(err)
+		}
+
+		// A restored backup has fresh modification times; the creation time
+		// in the file has to win.
+		if err := os.Chtimes(filepath.Join(dir, kid+".pem"), now, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := InitSigningKeys(); err != nil {
+		t.Fatal(err)
+	}
+
+	if signingKeys.active == nil || signingKeys.active.kid != "current" {
+		t.Fatalf("active key = %v, want current", signingKeys.active)
+	}
+
+	previous, ok := signingKeys.keys["previous"]
+
+	if !ok {
+		t.Fatal("the previous key no longer verifies")
+	}
+
+	if previous.retiredAt == nil || !previous.retiredAt.Equal(created["current"]) {
+		t.Errorf("previous key retired at %v, want %v", previous.retiredAt, created["current"])
+	}
+
+	if _, ok := signingKeys.keys["expired"]; ok {
+		t.Error("the expired key still verifies")
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "expired.pem")); !os.IsNotExist(err) {
+		t.Errorf("the expired key file was not deleted: %v", err)
+	}
+}
+
+func TestRotationPicksUpKeysOfOtherInstances(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Cleanup(func() { InitSigningKeys() })
+	t.Setenv("JWT_SIGNING_ALG", AlgEdDSA)
+	t.Setenv("JWT_KEYS_DIR", dir)
+
+	if err := InitSigningKeys(); err != nil {
+		t.Fatal(err)
+	}
+
+	first := signingKeys.active.kid
+
+	if err := signingKeys.rotateIfDue(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if signingKeys.active.kid != first {
+		t.Fatal("a key younger than the interval was rotated")
+	}
+
+	// Another instance sharing the directory rotates.
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeSigningKey(dir, &signingKey{kid: "other", alg: AlgEdDSA, private: private, createdAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := signingKeys.rotateIfDue(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if signingKeys.active.kid != "other" {
+		t.Errorf("active key = %s, want the one the other instance rotated in", signingKeys.active.kid)
+	}
+
+	if _, ok := signingKeys.keys[first]; !ok {
+		t.Error("the previous key no longer verifies")
+	}
+
+	if err := signingKeys.rotateIfDue(0); err != nil {
+		t.Fatal(err)
+	}
+
+	if signingKeys.active.kid == "other" {
+		t.Error("a due key was not rotated")
+	}
+}
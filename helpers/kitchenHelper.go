@@ -0,0 +1,30 @@
+package helpers
+
+import "golang-restaurant-management/models"
+
+// DefaultStation prepares the foods that neither they nor their menu assign
+// to a station.
+const DefaultStation = "kitchen"
+
+func IsValidTicketStatus(status string) bool {
+	switch status {
+	case models.PrepStatusQueued, models.PrepStatusCooking, models.PrepStatusReady:
+		return true
+	}
+
+	return false
+}
+
+// FoodStation returns the station that prepares the food. A station set on
+// the food wins over the one of its menu.
+func FoodStation(food models.Food, menu *models.Menu) string {
+	if food.Station != nil {
+		return *food.Station
+	}
+
+	if menu != nil && menu.Station != nil {
+		return *menu.Station
+	}
+
+	return DefaultStation
+}
@@ -0,0 +1,123 @@
+package helpers
+
+import (
+	"context"
+	"golang-restaurant-management/repository"
+	"math"
+	"strings"
+	"time"
+)
+
+// Failed logins are counted per account and per client IP. From the
+// backoffAfter-th failure on, each further attempt has to wait twice as long
+// as the previous one; at lockoutAfter failures the key is locked outright.
+type attemptPolicy struct {
+	backoffAfter int
+	lockoutAfter int
+	lockoutFor   time.Duration
+}
+
+var (
+	accountAttemptPolicy = attemptPolicy{backoffAfter: 3, lockoutAfter: 10, lockoutFor: 15 * time.Minute}
+	ipAttemptPolicy      = attemptPolicy{backoffAfter: 10, lockoutAfter: 50, lockoutFor: 15 * time.Minute}
+)
+
+const (
+	maxLoginBackoff      = time.Minute
+	loginAttemptLifetime = 24 * time.Hour
+)
+
+func AccountAttemptKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func IPAttemptKey(ip string) string {
+	return "ip:" + ip
+}
+
+func policyForKey(key string) attemptPolicy {
+	if strings.HasPrefix(key, "ip:") {
+		return ipAttemptPolicy
+	}
+
+	return accountAttemptPolicy
+}
+
+// LoginRetryAfter returns how long the caller has to wait before another
+// attempt is accepted for any of the keys, or zero if it may go ahead.
+func LoginRetryAfter(repos *repository.Repositories, keys ...string) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	attempts, err := repos.LoginAttempts.List(ctx, keys)
+
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	var wait time.Duration
+
+	for _, attempt := range attempts {
+		policy := policyForKey(attempt.Key)
+		allowedAt := now
+
+		if attempt.Locked_until != nil && attempt.Locked_until.After(allowedAt) {
+			allowedAt = *attempt.Locked_until
+		}
+
+		if attempt.Failures >= policy.backoffAfter {
+			backoff := time.Duration(math.Pow(2, float64(attempt.Failures-policy.backoffAfter))) * time.Second
+
+			if backoff > maxLoginBackoff {
+				backoff = maxLoginBackoff
+			}
+
+			if next := attempt.Last_failed_at.Add(backoff); next.After(allowedAt) {
+				allowedAt = next
+			}
+		}
+
+		if d := allowedAt.Sub(now); d > wait {
+			wait = d
+		}
+	}
+
+	return wait, nil
+}
+
+// RecordLoginFailure counts a failed attempt against every key and locks
+// the keys that reached their lockout threshold.
+func RecordLoginFailure(repos *repository.Repositories, keys ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	now := time.Now()
+
+	for _, key := range keys {
+		attempt, err := repos.LoginAttempts.RecordFailure(ctx, key, now, now.Add(loginAttemptLifetime))
+
+		if err != nil {
+			return err
+		}
+
+		policy := policyForKey(key)
+
+		if attempt.Failures%policy.lockoutAfter == 0 {
+			if err := repos.LoginAttempts.Lock(ctx, key, now.Add(policy.lockoutFor)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// ResetLoginFailures clears the counter and any lock for the key, after a
+// successful login or when an admin unlocks an account.
+func ResetLoginFailures(repos *repository.Repositories, key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	return repos.LoginAttempts.Delete(ctx, key)
+}
@@ -0,0 +1,64 @@
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type MailMessage struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers outgoing mail. Replace DefaultMailer to plug in a real
+// provider.
+type Mailer interface {
+	Send(msg MailMessage) error
+}
+
+// OutboxMailer writes every message as a file into Dir instead of sending
+// it, which is enough for development and tests.
+type OutboxMailer struct {
+	Dir string
+}
+
+func NewOutboxMailer(dir string) *OutboxMailer {
+	if dir == "" {
+		dir = "outbox"
+	}
+
+	return &OutboxMailer{Dir: dir}
+}
+
+func (m *OutboxMailer) Send(msg MailMessage) error {
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+
+	suffix, err := GenerateOpaqueToken()
+
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102T150405"), suffix[:8])
+	content := fmt.Sprintf("To: %s\nSubject: %s\n\n%s\n", msg.To, msg.Subject, msg.Body)
+
+	return os.WriteFile(filepath.Join(m.Dir, name), []byte(content), 0o600)
+}
+
+var DefaultMailer Mailer = NewOutboxMailer(os.Getenv("MAIL_OUTBOX_DIR"))
+
+// AppLink builds a link to the front end for use in emails.
+func AppLink(path string, token string) string {
+	base := os.Getenv("APP_BASE_URL")
+
+	if base == "" {
+		base = "http://localhost:3000"
+	}
+
+	return base + path + "?token=" + token
+}
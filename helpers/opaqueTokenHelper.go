@@ -0,0 +1,27 @@
+package helpers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// GenerateOpaqueToken returns a random hex string suitable for values that
+// only have to be unguessable, such as CSRF, API key and reset tokens.
+func GenerateOpaqueToken() (string, error) {
+	buf := make([]byte, 32)
+
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(buf), nil
+}
+
+// HashOpaqueToken is what gets stored for opaque tokens. A plain SHA-256 is
+// enough because the tokens are long random values, and a slow password hash
+// would only add latency to every lookup.
+func HashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,49 @@
+package helpers
+
+import (
+	"golang-restaurant-management/models"
+	"time"
+)
+
+// orderTransitions lists the statuses an order may move to from each
+// status. CLOSED and CANCELLED are final.
+var orderTransitions = map[string][]string{
+	models.OrderStatusOpen:          {models.OrderStatusSentToKitchen, models.OrderStatusCancelled},
+	models.OrderStatusSentToKitchen: {models.OrderStatusServed, models.OrderStatusCancelled},
+	models.OrderStatusServed:        {models.OrderStatusBilled},
+	models.OrderStatusBilled:        {models.OrderStatusClosed},
+}
+
+func IsValidOrderStatus(status string) bool {
+	switch status {
+	case models.OrderStatusOpen, models.OrderStatusSentToKitchen, models.OrderStatusServed,
+		models.OrderStatusBilled, models.OrderStatusClosed, models.OrderStatusCancelled:
+		return true
+	}
+
+	return false
+}
+
+func CanTransitionOrder(from string, to string) bool {
+	for _, next := range orderTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetOrderStatus moves the order into status and appends the change to its
+// history. It does not check whether the transition is allowed.
+func SetOrderStatus(order *models.Order, status string, changedBy string) {
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+	order.Status = status
+	order.Status_history = append(order.Status_history, models.OrderStatusChange{
+		Status:     status,
+		Changed_by: changedBy,
+		Changed_at: now,
+	})
+	order.Updated_at = now
+}
@@ -0,0 +1,94 @@
+package helpers
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	RoleAdmin   = "ADMIN"
+	RoleManager = "MANAGER"
+	RoleWaiter  = "WAITER"
+	RoleChef    = "CHEF"
+	RoleCashier = "CASHIER"
+	RoleHost    = "HOST"
+	RoleUser    = "USER"
+)
+
+const (
+	PermUserRead      = "user:read"
+	PermUserManage    = "user:manage"
+	PermUserInvite    = "user:invite"
+	PermMenuWrite     = "menu:write"
+	PermFoodWrite     = "food:write"
+	PermTableRead     = "table:read"
+	PermTableWrite    = "table:write"
+	PermOrderRead     = "order:read"
+	PermOrderWrite    = "order:write"
+	PermPriceOverride = "price:override"
+	PermItemVoid      = "item:void"
+	PermInvoiceRead   = "invoice:read"
+	PermInvoiceWrite  = "invoice:write"
+	PermInvoicePay    = "invoice:pay"
+	PermInvoiceDelete = "invoice:delete"
+	PermKitchenBump   = "kitchen:bump"
+	PermApiKeyManage  = "apikey:manage"
+)
+
+// rolePermissions is the permission matrix. ADMIN is not listed because it
+// is granted every permission.
+var rolePermissions = map[string][]string{
+	RoleManager: {
+		PermUserRead, PermUserInvite, PermMenuWrite, PermFoodWrite, PermTableRead, PermTableWrite,
+		PermOrderRead, PermOrderWrite, PermPriceOverride, PermItemVoid, PermInvoiceRead, PermInvoiceWrite, PermInvoicePay, PermInvoiceDelete,
+	},
+	RoleWaiter: {
+		PermTableRead, PermOrderRead, PermOrderWrite, PermInvoiceRead, PermInvoiceWrite,
+	},
+	RoleChef: {
+		PermOrderRead, PermKitchenBump,
+	},
+	RoleCashier: {
+		PermTableRead, PermOrderRead, PermInvoiceRead, PermInvoiceWrite, PermInvoicePay,
+	},
+	RoleHost: {
+		PermTableRead, PermTableWrite, PermOrderRead,
+	},
+	RoleUser: {
+		PermOrderRead, PermOrderWrite, PermInvoiceRead, PermInvoiceWrite,
+	},
+}
+
+func IsValidRole(role string) bool {
+	if role == RoleAdmin {
+		return true
+	}
+
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+func HasPermission(role string, permission string) bool {
+	if role == RoleAdmin {
+		return true
+	}
+
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CheckPermission is for checks that depend on the request body and so
+// cannot be declared on the route, e.g. marking an invoice as PAID.
+func CheckPermission(c *gin.Context, permission string) (err error) {
+	if !HasPermission(c.GetString("user_type"), permission) {
+		err = errors.New("unauthorized access")
+	}
+
+	return err
+}
@@ -0,0 +1,99 @@
+package helpers
+
+import (
+	"context"
+	"golang-restaurant-management/models"
+	"golang-restaurant-management/repository"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RefreshTokenLifetime bounds how long a revocation entry has to be kept:
+// no token issued before the revocation can outlive it.
+const RefreshTokenLifetime = time.Hour * 168
+
+// RevokeToken denylists a single token by its jti until it expires.
+func RevokeToken(repos *repository.Repositories, jti string, userId string, expiresAt time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var revoked models.RevokedToken
+
+	revoked.ID = primitive.NewObjectID()
+	revoked.Kind = repository.RevokedKindToken
+	revoked.Jti = jti
+	revoked.User_id = userId
+	revoked.Revoked_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	revoked.Expires_at = expiresAt
+
+	return repos.Revocations.Add(ctx, revoked)
+}
+
+// RevokeAllUserTokens invalidates every token issued to the user up to now,
+// on all devices, and ends all of the user's sessions.
+func RevokeAllUserTokens(repos *repository.Repositories, userId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var revoked models.RevokedToken
+
+	revoked.ID = primitive.NewObjectID()
+	revoked.Kind = repository.RevokedKindUser
+	revoked.User_id = userId
+	revoked.Revoked_at = time.Now().Truncate(time.Millisecond)
+	revoked.Expires_at = revoked.Revoked_at.Add(RefreshTokenLifetime)
+
+	if err := repos.Revocations.Add(ctx, revoked); err != nil {
+		return err
+	}
+
+	return RevokeUserSessions(repos, userId, "")
+}
+
+// RevokeTerminalTokens invalidates the user's tokens issued up to now that
+// belong to no session, such as PIN logins on terminals. Sessions are left
+// alone.
+func RevokeTerminalTokens(repos *repository.Repositories, userId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var revoked models.RevokedToken
+
+	revoked.ID = primitive.NewObjectID()
+	revoked.Kind = repository.RevokedKindTerminal
+	revoked.User_id = userId
+	revoked.Revoked_at = time.Now().Truncate(time.Millisecond)
+	revoked.Expires_at = revoked.Revoked_at.Add(RefreshTokenLifetime)
+
+	return repos.Revocations.Add(ctx, revoked)
+}
+
+// denylistSession rejects every access token of the session until the
+// longest-lived of them has expired.
+func denylistSession(ctx context.Context, repos *repository.Repositories, sessionId string, userId string) error {
+	var revoked models.RevokedToken
+
+	revoked.ID = primitive.NewObjectID()
+	revoked.Kind = repository.RevokedKindSession
+	revoked.Session_id = sessionId
+	revoked.User_id = userId
+	revoked.Revoked_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	revoked.Expires_at = revoked.Revoked_at.Add(RefreshTokenLifetime)
+
+	return repos.Revocations.Add(ctx, revoked)
+}
+
+// IsTokenRevoked reports whether the token was denylisted by its jti,
+// belongs to a revoked session or was issued before a user-wide revocation.
+func IsTokenRevoked(repos *repository.Repositories, claims *SignedDetails) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	issuedAt := time.Time{}
+	if claims.IssuedAt != nil {
+		issuedAt = claims.IssuedAt.Time
+	}
+
+	return repos.Revocations.IsRevoked(ctx, claims.ID, claims.Family, claims.Uid, issuedAt)
+}
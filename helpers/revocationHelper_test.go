@@ -0,0 +1,31 @@
+package helpers
+
+import (
+	"golang-restaurant-management/repository"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestUserRevocationCatchesTokensOfTheSameSecond(t *testing.T) {
+	repos := repository.NewMemoryRepositories()
+
+	// A terminal token has no session, so only the user-wide entry can
+	// revoke it.
+	issued := &SignedDetails{Uid: "user", RegisteredClaims: jwt.RegisteredClaims{ID: "issued", IssuedAt: jwt.NewNumericDate(time.Now())}}
+
+	if err := RevokeAllUserTokens(repos, "user"); err != nil {
+		t.Fatal(err)
+	}
+
+	if revoked, err := IsTokenRevoked(repos, issued); err != nil || !revoked {
+		t.Errorf("token issued before the revocation: revoked = %v, %v", revoked, err)
+	}
+
+	later := &SignedDetails{Uid: "user", RegisteredClaims: jwt.RegisteredClaims{ID: "later", IssuedAt: jwt.NewNumericDate(time.Now().Add(time.Millisecond))}}
+
+	if revoked, err := IsTokenRevoked(repos, later); err != nil || revoked {
+		t.Errorf("token issued after the revocation: revoked = %v, %v", revoked, err)
+	}
+}
@@ -0,0 +1,124 @@
+package helpers
+
+import (
+	"context"
+	"golang-restaurant-management/models"
+	"golang-restaurant-management/repository"
+	"time"
+)
+
+// sessionTouchInterval limits how often last_seen_at is written.
+const sessionTouchInterval = time.Minute
+
+// CreateSession stores a new session. A session is one login on one device;
+// its id is the family claim of every token issued for it, so refreshing
+// and revoking are scoped to the device.
+func CreateSession(repos *repository.Repositories, session models.Session) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	return repos.Sessions.Create(ctx, session)
+}
+
+func FindSession(repos *repository.Repositories, sessionId string) (models.Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	return repos.Sessions.Get(ctx, sessionId)
+}
+
+// ListUserSessions returns the sessions of the user that are still active.
+func ListUserSessions(repos *repository.Repositories, userId string) ([]models.Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	return repos.Sessions.ListActive(ctx, userId)
+}
+
+// RotateSessionRefreshToken stores the hash of the new refresh token only if
+// the presented one is still the current token of the active session. A
+// miss is reported as ErrRefreshTokenReused.
+func RotateSessionRefreshToken(repos *repository.Repositories, sessionId, presentedRefreshToken, signedRefreshToken string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+	err := repos.Sessions.RotateRefreshToken(ctx, sessionId,
+		HashOpaqueToken(presentedRefreshToken), HashOpaqueToken(signedRefreshToken),
+		now, now.Add(RefreshTokenLifetime),
+	)
+
+	if err == repository.ErrNotFound {
+		return ErrRefreshTokenReused
+	}
+
+	return err
+}
+
+// TouchSession records activity on the session, at most once per
+// sessionTouchInterval.
+func TouchSession(repos *repository.Repositories, sessionId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+	return repos.Sessions.Touch(ctx, sessionId, now, now.Add(-sessionTouchInterval))
+}
+
+// RevokeSession ends the session: its refresh token stops working and its
+// access tokens are denylisted.
+func RevokeSession(repos *repository.Repositories, sessionId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	session, err := repos.Sessions.Get(ctx, sessionId)
+
+	if err == repository.ErrNotFound || (err == nil && session.Revoked_at != nil) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return revokeSession(ctx, repos, session)
+}
+
+// RevokeUserSessions ends every session of the user except exceptSessionId,
+// which may be empty.
+func RevokeUserSessions(repos *repository.Repositories, userId string, exceptSessionId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	sessions, err := repos.Sessions.ListActive(ctx, userId)
+
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if session.Session_id == exceptSessionId {
+			continue
+		}
+
+		if err := revokeSession(ctx, repos, session); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func revokeSession(ctx context.Context, repos *repository.Repositories, session models.Session) error {
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+	err := repos.Sessions.Revoke(ctx, session.Session_id, now)
+
+	if err != nil && err != repository.ErrNotFound {
+		return err
+	}
+
+	return denylistSession(ctx, repos, session.Session_id, session.User_id)
+}
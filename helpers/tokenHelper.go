@@ -1,103 +1,157 @@
 package helpers
 
 import (
-	"context"
-	"golang-restaurant-management/database"
-	"log"
+	"errors"
 	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+const (
+	AccessTokenType     = "access"
+	RefreshTokenType    = "refresh"
+	MfaPendingTokenType = "mfa_pending"
+)
+
+const MfaPendingTokenLifetime = 5 * time.Minute
+
 type SignedDetails struct {
-	Email      string
-	First_name string
-	Last_name  string
-	Uid        string
-	User_type  string
+	Email       string
+	First_name  string
+	Last_name   string
+	Uid         string
+	User_type   string
+	Token_type  string
+	Family      string
+	Mfa_enroll  bool
+	Terminal_id string
 	jwt.RegisteredClaims
 }
 
-var userCollection *mongo.Collection = database.OpenCollection(database.Client, "user")
 var SECRET_KEY string = os.Getenv("SECRET_KEY")
 
+var ErrRefreshTokenReused = errors.New("refresh token has already been used")
+
+// Token times keep their milliseconds so that a user-wide revocation can be
+// told apart from a token issued in the same second.
+func init() {
+	jwt.TimePrecision = time.Millisecond
+}
+
+// GenerateAllTokens issues an access/refresh pair that starts a new refresh
+// token family.
 func GenerateAllTokens(email, firstName, lastName, uid, userType string) (signedToken, signedRefreshToken string, err error) {
+	return GenerateTokensForFamily(email, firstName, lastName, uid, userType, primitive.NewObjectID().Hex())
+}
+
+// GenerateTokensForFamily issues an access/refresh pair whose refresh token
+// belongs to the given family, so rotated tokens can be traced back to the
+// login that created them.
+func GenerateTokensForFamily(email, firstName, lastName, uid, userType, family string) (signedToken, signedRefreshToken string, err error) {
+	now := time.Now().Local()
+
 	claims := &SignedDetails{
 		Email:      email,
 		First_name: firstName,
 		Last_name:  lastName,
 		User_type:  userType,
 		Uid:        uid,
+		Token_type: AccessTokenType,
+		Family:     family,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Local().Add(time.Hour * 24)),
+			ID:        primitive.NewObjectID().Hex(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour * 24)),
 		},
 	}
 
 	refreshClaims := &SignedDetails{
+		Uid:        uid,
+		Token_type: RefreshTokenType,
+		Family:     family,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Local().Add(time.Hour * 168)),
+			ID:        primitive.NewObjectID().Hex(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(RefreshTokenLifetime)),
 		},
 	}
 
-	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(SECRET_KEY))
+	token, err := SignClaims(claims)
 
 	if err != nil {
-		log.Fatal(err)
+		return "", "", err
 	}
 
-	refreshToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims).SignedString([]byte(SECRET_KEY))
+	refreshToken, err := SignClaims(refreshClaims)
 
 	if err != nil {
-		log.Fatal(err)
+		return "", "", err
 	}
 
 	return token, refreshToken, err
 
 }
 
-func UpdateAllTokens(signedToken string, signedRefreshToken string, userId string) {
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
-
-	var updatedObj primitive.D
-
-	updatedObj = append(updatedObj, bson.E{Key: "token", Value: signedToken})
+// GenerateMfaPendingToken issues the short-lived token returned by a login
+// that still needs a second factor. It is only accepted by the MFA login
+// endpoints. enroll marks accounts that must first set up MFA.
+func GenerateMfaPendingToken(uid string, enroll bool) (string, error) {
+	now := time.Now().Local()
 
-	updatedObj = append(updatedObj, bson.E{Key: "refresh_token", Value: signedRefreshToken})
-
-	Updated_at, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-
-	updatedObj = append(updatedObj, bson.E{Key: "updated_at", Value: Updated_at})
-
-	upsert := true
+	claims := &SignedDetails{
+		Uid:        uid,
+		Token_type: MfaPendingTokenType,
+		Mfa_enroll: enroll,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        primitive.NewObjectID().Hex(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(MfaPendingTokenLifetime)),
+		},
+	}
 
-	filter := bson.M{"user_id": userId}
+	return SignClaims(claims)
+}
 
-	opt := options.UpdateOptions{
-		Upsert: &upsert,
+// TerminalIdleTimeout is how long a PIN session on a shared terminal stays
+// valid without activity. Every authenticated request renews it.
+func TerminalIdleTimeout() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("PIN_IDLE_TIMEOUT")); err == nil && d > 0 {
+		return d
 	}
 
-	_, err := userCollection.UpdateOne(ctx, filter, bson.D{
-		{Key: "$set", Value: updatedObj},
-	}, &opt)
+	return 5 * time.Minute
+}
+
+// GenerateTerminalToken issues an access token bound to the terminal's API
+// key. It has no refresh token and expires after TerminalIdleTimeout.
+func GenerateTerminalToken(email, firstName, lastName, uid, userType, terminalId string) (string, error) {
+	now := time.Now().Local()
 
-	if err != nil {
-		log.Panic(err)
+	claims := &SignedDetails{
+		Email:       email,
+		First_name:  firstName,
+		Last_name:   lastName,
+		User_type:   userType,
+		Uid:         uid,
+		Token_type:  AccessTokenType,
+		Terminal_id: terminalId,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        primitive.NewObjectID().Hex(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(TerminalIdleTimeout())),
+		},
 	}
 
+	return SignClaims(claims)
 }
 
-func ValidateToken(signedToken string) (claims *SignedDetails, msg string) {
+func parseToken(signedToken string) (claims *SignedDetails, msg string) {
 
 	token, err := jwt.ParseWithClaims(
-		signedToken, &SignedDetails{}, func(token *jwt.Token) (interface{}, error) {
-			return []byte(SECRET_KEY), nil
-		},
+		signedToken, &SignedDetails{}, verificationKey,
+		jwt.WithValidMethods([]string{AlgHS256, AlgRS256, AlgEdDSA}),
 	)
 
 	if err != nil {
@@ -109,7 +163,6 @@ func ValidateToken(signedToken string) (claims *SignedDetails, msg string) {
 
 	if !ok {
 		msg = "The token is invalid!"
-		msg = err.Error()
 		return
 	}
 
@@ -119,5 +172,53 @@ func ValidateToken(signedToken string) (claims *SignedDetails, msg string) {
 	}
 
 	return claims, msg
+}
+
+func ValidateToken(signedToken string) (claims *SignedDetails, msg string) {
+
+	claims, msg = parseToken(signedToken)
+
+	if msg != "" {
+		return
+	}
+
+	if claims.Token_type != AccessTokenType {
+		msg = "Only access tokens can be used for authorization!"
+		return
+	}
+
+	return claims, msg
+
+}
+
+func ValidateRefreshToken(signedRefreshToken string) (claims *SignedDetails, msg string) {
+
+	claims, msg = parseToken(signedRefreshToken)
+
+	if msg != "" {
+		return
+	}
+
+	if claims.Token_type != RefreshTokenType {
+		msg = "The refresh token is invalid!"
+		return
+	}
+
+	return claims, msg
+}
+
+func ValidateMfaPendingToken(signedToken string) (claims *SignedDetails, msg string) {
 
+	claims, msg = parseToken(signedToken)
+
+	if msg != "" {
+		return
+	}
+
+	if claims.Token_type != MfaPendingTokenType {
+		msg = "The MFA token is invalid!"
+		return
+	}
+
+	return claims, msg
 }
@@ -0,0 +1,128 @@
+package helpers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// TOTP parameters as recommended by RFC 6238 and understood by every
+// authenticator app.
+const (
+	totpDigits = 6
+	totpPeriod = 30
+	totpSkew   = 1
+)
+
+const recoveryCodeCount = 10
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func GenerateTotpSecret() (string, error) {
+	buf := make([]byte, 20)
+
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return totpEncoding.EncodeToString(buf), nil
+}
+
+func totpCodeForStep(key []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// TotpCode returns the code for the given secret at time t.
+func TotpCode(secret string, t time.Time) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+
+	if err != nil {
+		return "", err
+	}
+
+	return totpCodeForStep(key, t.Unix()/totpPeriod), nil
+}
+
+// ValidateTotp checks the code against the current time step and one step
+// either side to allow for clock drift. It returns the matching step so the
+// caller can refuse to accept the same code twice.
+func ValidateTotp(secret string, code string, t time.Time) (step int64, ok bool) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+
+	current := t.Unix() / totpPeriod
+
+	for s := current - totpSkew; s <= current+totpSkew; s++ {
+		if subtle.ConstantTimeCompare([]byte(totpCodeForStep(key, s)), []byte(code)) == 1 {
+			return s, true
+		}
+	}
+
+	return 0, false
+}
+
+// TotpProvisioningURI returns the otpauth:// URI that authenticator apps
+// read from a QR code.
+func TotpProvisioningURI(secret string, account string) string {
+	issuer := os.Getenv("MFA_ISSUER")
+
+	if issuer == "" {
+		issuer = "Restaurant"
+	}
+
+	values := url.Values{}
+	values.Set("secret", secret)
+	values.Set("issuer", issuer)
+	values.Set("algorithm", "SHA1")
+	values.Set("digits", fmt.Sprint(totpDigits))
+	values.Set("period", fmt.Sprint(totpPeriod))
+
+	label := url.PathEscape(issuer + ":" + account)
+
+	return "otpauth://totp/" + label + "?" + values.Encode()
+}
+
+// GenerateRecoveryCodes returns the plaintext codes to show once and the
+// hashes to store.
+func GenerateRecoveryCodes() (codes []string, hashes []string, err error) {
+	for i := 0; i < recoveryCodeCount; i++ {
+		token, err := GenerateOpaqueToken()
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		code := token[:5] + "-" + token[5:10]
+		codes = append(codes, code)
+		hashes = append(hashes, HashOpaqueToken(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// MfaRequiredForRole reports whether accounts with the role must use MFA.
+// Setting MFA_REQUIRED_FOR_ADMIN=true enforces it for ADMIN accounts.
+func MfaRequiredForRole(role string) bool {
+	return role == RoleAdmin && os.Getenv("MFA_REQUIRED_FOR_ADMIN") == "true"
+}
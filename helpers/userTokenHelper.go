@@ -0,0 +1,69 @@
+package helpers
+
+import (
+	"context"
+	"golang-restaurant-management/models"
+	"golang-restaurant-management/repository"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	PurposePasswordReset     = "PASSWORD_RESET"
+	PurposeEmailVerification = "EMAIL_VERIFICATION"
+)
+
+// IssueUserToken creates a single-use token for the given purpose and
+// returns its plaintext. Earlier unused tokens of the same purpose are
+// invalidated so only the latest email works.
+func IssueUserToken(repos *repository.Repositories, userId string, purpose string, ttl time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	plaintext, err := GenerateOpaqueToken()
+
+	if err != nil {
+		return "", err
+	}
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+	if err := repos.UserTokens.InvalidateUnused(ctx, userId, purpose, now); err != nil {
+		return "", err
+	}
+
+	var userToken models.UserToken
+
+	userToken.ID = primitive.NewObjectID()
+	userToken.User_token_id = userToken.ID.Hex()
+	userToken.User_id = userId
+	userToken.Purpose = purpose
+	userToken.Token_hash = HashOpaqueToken(plaintext)
+	userToken.Created_at = now
+	userToken.Expires_at = now.Add(ttl)
+
+	if err := repos.UserTokens.Create(ctx, userToken); err != nil {
+		return "", err
+	}
+
+	return plaintext, nil
+}
+
+// ConsumeUserToken marks a valid token as used and returns the user it was
+// issued to. Unknown, expired and already used tokens all yield
+// repository.ErrNotFound.
+func ConsumeUserToken(repos *repository.Repositories, plaintext string, purpose string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+	userToken, err := repos.UserTokens.Consume(ctx, HashOpaqueToken(plaintext), purpose, now)
+
+	if err != nil {
+		return "", err
+	}
+
+	return userToken.User_id, nil
+}
@@ -5,16 +5,14 @@ import (
 	"os"
 
 	database "golang-restaurant-management/database"
-	middleware "golang-restaurant-management/middleware"
+	helpers "golang-restaurant-management/helpers"
+	repository "golang-restaurant-management/repository"
 	routes "golang-restaurant-management/routes"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
-var foodCollection *mongo.Collection = database.OpenCollection(database.Client, "food")
-
 func main() {
 
 	err := godotenv.Load(".env")
@@ -28,17 +26,28 @@ func main() {
 		port = "5000"
 	}
 
+	if err := helpers.InitSigningKeys(); err != nil {
+		log.Fatal("Failed to load JWT signing keys!", err)
+	}
+
+	helpers.StartKeyRotation()
+
+	client, err := database.Connect()
+
+	if err != nil {
+		log.Fatal("Failed to connect to database!", err)
+	}
+
+	repos := repository.NewMongoRepositories(database.OpenDatabase(client))
+
+	if err := repos.EnsureIndexes(); err != nil {
+		log.Fatal("Failed to create database indexes!", err)
+	}
+
 	router := gin.New()
 	router.Use(gin.Logger())
 
-	routes.UserRoutes(router)
-	router.Use(middleware.Authentication())
-
-	routes.FoodRoutes(router)
-	routes.MenuRoutes(router)
-	routes.TableRoutes(router)
-	routes.OrderItemsRoutes(router)
-	routes.InvoiceRoutes(router)
+	routes.Register(router, repos)
 
 	router.Run(":" + port)
 }
@@ -2,35 +2,186 @@ package middleware
 
 import (
 	"golang-restaurant-management/helpers"
+	"golang-restaurant-management/models"
+	"golang-restaurant-management/repository"
+	"log"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
-func Authentication() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		clientToken, err := c.Cookie("token")
+// Authenticate validates the caller's token and stores its claims in the
+// context. The token is taken from an "Authorization: Bearer" header or,
+// failing that, from the token cookie; cookie-authenticated requests that
+// change state must also pass the CSRF check. On failure it writes the error
+// response, aborts the chain and returns false.
+func Authenticate(c *gin.Context, repos *repository.Repositories) bool {
+	apiKey := c.GetHeader(helpers.ApiKeyHeader)
+	clientToken, fromCookie := bearerToken(c), false
 
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Authorization Required!"})
-			c.Abort()
-			return
+	if apiKey != "" && clientToken == "" {
+		return authenticateApiKey(c, repos, apiKey)
+	}
+
+	if clientToken == "" {
+		cookie, err := c.Cookie("token")
+
+		if err != nil || cookie == "" {
+			unauthorized(c, "", "Authorization Required!")
+			return false
 		}
 
-		claims, msg := helpers.ValidateToken(clientToken)
+		clientToken, fromCookie = cookie, true
+	}
+
+	claims, msg := helpers.ValidateToken(clientToken)
+
+	if msg != "" {
+		unauthorized(c, "invalid_token", msg)
+		return false
+	}
+
+	revoked, err := helpers.IsTokenRevoked(repos, claims)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check the token status!"})
+		c.Abort()
+		return false
+	}
+
+	if revoked {
+		unauthorized(c, "invalid_token", "Token has been revoked!")
+		return false
+	}
+
+	if claims.Terminal_id != "" && !renewTerminalSession(c, repos, claims, apiKey) {
+		return false
+	}
+
+	if fromCookie && !helpers.IsSafeMethod(c.Request.Method) && !helpers.ValidCSRF(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "CSRF token is missing or invalid!"})
+		c.Abort()
+		return false
+	}
+
+	c.Set("email", claims.Email)
+	c.Set("First_name", claims.First_name)
+	c.Set("last_name", claims.Last_name)
+	c.Set("user_type", claims.User_type)
+	c.Set("uid", claims.Uid)
+	c.Set("jti", claims.ID)
+	c.Set("token_expires_at", claims.ExpiresAt.Time)
+	c.Set("token_claims", claims)
+	c.Set("auth_method", "token")
 
-		if msg != "" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
-			c.Abort()
-			return
+	if claims.Terminal_id != "" {
+		c.Set("terminal_id", claims.Terminal_id)
+	}
+
+	if claims.Family != "" {
+		c.Set("session_id", claims.Family)
+
+		if err := helpers.TouchSession(repos, claims.Family); err != nil {
+			log.Println("Failed to update session activity:", err)
 		}
+	}
+
+	return true
+}
+
+// renewTerminalSession checks that a PIN session token is presented by the
+// terminal it was issued to, applies the terminal's table and station scope
+// and slides its inactivity window forward by returning a fresh token in the
+// X-Renewed-Token header.
+func renewTerminalSession(c *gin.Context, repos *repository.Repositories, claims *helpers.SignedDetails, apiKey string) bool {
+	if apiKey == "" {
+		unauthorized(c, "invalid_token", "This token can only be used from its terminal!")
+		return false
+	}
+
+	terminal, err := helpers.FindActiveApiKey(repos, apiKey)
+
+	if err == repository.ErrNotFound || (err == nil && terminal.Api_key_id != claims.Terminal_id) {
+		unauthorized(c, "invalid_token", "This token can only be used from its terminal!")
+		return false
+	}
 
-		c.Set("email", claims.Email)
-		c.Set("First_name", claims.First_name)
-		c.Set("last_name", claims.Last_name)
-		c.Set("user_type", claims.User_type)
-		c.Set("uid", claims.Uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check the API key!"})
+		c.Abort()
+		return false
+	}
+
+	setDeviceScope(c, terminal)
+
+	renewed, err := helpers.GenerateTerminalToken(claims.Email, claims.First_name, claims.Last_name, claims.Uid, claims.User_type, claims.Terminal_id)
+
+	if err == nil {
+		c.Header("X-Renewed-Token", renewed)
+	}
+
+	return true
+}
+
+// authenticateApiKey maps a device key onto the same context keys a user
+// token provides, so controllers do not need to tell the two apart. The
+// key's optional table and station scope are exposed as well.
+func authenticateApiKey(c *gin.Context, repos *repository.Repositories, key string) bool {
+	apiKey, err := helpers.FindActiveApiKey(repos, key)
+
+	if err == repository.ErrNotFound {
+		unauthorized(c, "invalid_token", "The API key is invalid or revoked!")
+		return false
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check the API key!"})
+		c.Abort()
+		return false
+	}
+
+	c.Set("user_type", *apiKey.Role)
+	c.Set("uid", apiKey.Api_key_id)
+	c.Set("api_key_id", apiKey.Api_key_id)
+	c.Set("auth_method", "api_key")
+
+	setDeviceScope(c, apiKey)
 
-		c.Next()
+	return true
+}
+
+// setDeviceScope exposes a device key's optional table and station scope.
+func setDeviceScope(c *gin.Context, apiKey models.ApiKey) {
+	if apiKey.Table_id != nil {
+		c.Set("table_id", *apiKey.Table_id)
+	}
+
+	if apiKey.Station != nil {
+		c.Set("station", *apiKey.Station)
+	}
+}
+
+func bearerToken(c *gin.Context) string {
+	header := c.GetHeader("Authorization")
+
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
 	}
+
+	return ""
+}
+
+// unauthorized answers with 401 and a WWW-Authenticate challenge as described
+// in RFC 6750.
+func unauthorized(c *gin.Context, code string, msg string) {
+	challenge := `Bearer realm="restaurant"`
+
+	if code != "" {
+		challenge += `, error="` + code + `"`
+	}
+
+	c.Header("WWW-Authenticate", challenge)
+	c.JSON(http.StatusUnauthorized, gin.H{"error": msg})
+	c.Abort()
 }
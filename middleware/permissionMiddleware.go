@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"golang-restaurant-management/helpers"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequirePermission must run after Authenticate, which puts the caller's
+// role into the context. The route policy applies it to every route that
+// names a permission.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !helpers.HasPermission(c.GetString("user_type"), permission) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You do not have the " + permission + " permission!"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
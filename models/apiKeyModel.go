@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ApiKey struct {
+	ID           primitive.ObjectID `bson:"_id"`
+	Name         *string            `json:"name" validate:"required,min=2,max=100"`
+	Role         *string            `json:"role" validate:"required"`
+	Table_id     *string            `json:"table_id"`
+	Station      *string            `json:"station"`
+	Prefix       string             `json:"prefix"`
+	Key_hash     string             `json:"-"`
+	Created_by   string             `json:"created_by"`
+	Last_used_at *time.Time         `json:"last_used_at"`
+	Revoked_at   *time.Time         `json:"revoked_at"`
+	Created_at   time.Time          `json:"created_at"`
+	Updated_at   time.Time          `json:"updated_at"`
+	Api_key_id   string             `json:"api_key_id"`
+}
@@ -7,12 +7,75 @@ import (
 )
 
 type Food struct {
-	ID         primitive.ObjectID `bson:"_id"`
-	Name       *string            `json:"name" validate:"required,min=2,max=100"`
-	Price      *float64           `json:"price" validate:"required"`
-	Food_image *string            `json:"food_image" validate:"required"`
-	Created_at time.Time          `json:"created_at"`
-	Updated_at time.Time          `json:"updated_at"`
-	Food_id    string             `json:"food_id"`
-	Menu_id    *string            `json:"menu_id" validate:"required"`
+	ID              primitive.ObjectID `bson:"_id"`
+	Name            *string            `json:"name" validate:"required,min=2,max=100"`
+	Price           *float64           `json:"price" validate:"required"`
+	Variants        []FoodVariant      `json:"variants" validate:"omitempty,unique=Name,dive"`
+	Modifier_groups []ModifierGroup    `json:"modifier_groups" validate:"omitempty,unique=Name,dive"`
+	Station         *string            `json:"station" validate:"omitempty,min=1,max=30"`
+	Food_image      *string            `json:"food_image" validate:"required"`
+	Created_at      time.Time          `json:"created_at"`
+	Updated_at      time.Time          `json:"updated_at"`
+	Food_id         string             `json:"food_id"`
+	Menu_id         *string            `json:"menu_id" validate:"required"`
+}
+
+// FoodVariant is a size or version of a food with its own price, e.g. a
+// small and a large pizza.
+type FoodVariant struct {
+	Name  string   `json:"name" validate:"required,max=50"`
+	Price *float64 `json:"price" validate:"required,min=0"`
+}
+
+// ModifierGroup is a set of options guests pick from when ordering a food,
+// e.g. "Toppings" or "Remove". Max_selections of zero means no limit.
+type ModifierGroup struct {
+	Name           string           `json:"name" validate:"required,max=50"`
+	Required       bool             `json:"required"`
+	Min_selections int              `json:"min_selections" validate:"min=0"`
+	Max_selections int              `json:"max_selections" validate:"omitempty,gtefield=Min_selections"`
+	Options        []ModifierOption `json:"options" validate:"required,min=1,unique=Name,dive"`
+}
+
+// ModifierOption is one choice of a modifier group. Price_delta is added to
+// the unit price of the item and may be negative.
+type ModifierOption struct {
+	Name        string  `json:"name" validate:"required,max=50"`
+	Price_delta float64 `json:"price_delta"`
+}
+
+// Selections returns how many options of the group an item has to pick at
+// least and may pick at most. Required groups need at least one pick.
+func (group ModifierGroup) Selections() (min int, max int) {
+	min, max = group.Min_selections, group.Max_selections
+
+	if group.Required && min < 1 {
+		min = 1
+	}
+
+	if max == 0 || max > len(group.Options) {
+		max = len(group.Options)
+	}
+
+	return min, max
+}
+
+// VariantPrice returns the price of the named variant. Foods without
+// variants are ordered without one, at their price.
+func (food Food) VariantPrice(variant string) (float64, bool) {
+	if len(food.Variants) == 0 {
+		if variant != "" || food.Price == nil {
+			return 0, false
+		}
+
+		return *food.Price, true
+	}
+
+	for _, v := range food.Variants {
+		if v.Name == variant && v.Price != nil {
+			return *v.Price, true
+		}
+	}
+
+	return 0, false
 }
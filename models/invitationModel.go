@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Invitation struct {
+	ID            primitive.ObjectID `bson:"_id"`
+	Email         *string            `json:"email" validate:"required,email"`
+	User_type     *string            `json:"user_type" validate:"required,eq=ADMIN|eq=MANAGER|eq=WAITER|eq=CHEF|eq=CASHIER|eq=HOST|eq=USER"`
+	Token_hash    string             `json:"-"`
+	Invited_by    string             `json:"invited_by"`
+	Expires_at    time.Time          `json:"expires_at"`
+	Accepted_at   *time.Time         `json:"accepted_at"`
+	Revoked_at    *time.Time         `json:"revoked_at"`
+	User_id       *string            `json:"user_id"`
+	Created_at    time.Time          `json:"created_at"`
+	Updated_at    time.Time          `json:"updated_at"`
+	Invitation_id string             `json:"invitation_id"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type LoginAttempt struct {
+	ID             primitive.ObjectID `bson:"_id"`
+	Key            string             `json:"key"`
+	Failures       int                `json:"failures"`
+	Last_failed_at time.Time          `json:"last_failed_at"`
+	Locked_until   *time.Time         `json:"locked_until"`
+	Expires_at     time.Time          `json:"expires_at"`
+}
@@ -10,6 +10,7 @@ type Menu struct {
 	ID         primitive.ObjectID `bson:"_id"`
 	Name       string             `json:"name" validate:"required"`
 	Category   string             `json:"category" validate:"required"`
+	Station    *string            `json:"station" validate:"omitempty,min=1,max=30"`
 	Start_Date time.Time          `json:"start_date"`
 	End_Date   time.Time          `json:"end_date"`
 	Created_at time.Time          `json:"created_at"`
@@ -3,16 +3,137 @@ package models
 import (
 	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type OrderItem struct {
-	ID            primitive.ObjectID `bson:"_id"`
-	Quantity      *string            `json:"quantity" validate:"required,eq=S|eq=M|eq=L"`
-	Unit_price    *float64           `json:"unit_price" validate:"required"`
-	Created_at    time.Time          `json:"created_at"`
-	Updated_at    time.Time          `json:"updated_at"`
-	Food_id       *string            `json:"food_id" validate:"required"`
-	Order_item_id string             `json:"order_item_id"`
-	Order_id      string             `json:"order_id" validate:"required"`
+	ID primitive.ObjectID `bson:"_id"`
+	// Quantity is stored as count: items stored before quantities were
+	// numbers hold their size (S, M or L) in the quantity field, which
+	// UnmarshalBSON reads as the variant.
+	Quantity       *int               `json:"quantity" bson:"count" validate:"required,min=1,max=99"`
+	Variant        *string            `json:"variant"`
+	Modifiers      []SelectedModifier `json:"modifiers" validate:"omitempty,dive"`
+	Notes          *string            `json:"notes" validate:"omitempty,max=200"`
+	Unit_price     *float64           `json:"unit_price"`
+	Price_override *PriceOverride     `json:"price_override"`
+	Adjustment     *ItemAdjustment    `json:"adjustment"`
+	Course         *int               `json:"course" validate:"omitempty,min=1,max=9"`
+	// Hold is only read from requests: held items stay behind when the
+	// order is sent to the kitchen and carry the HELD preparation status
+	// until their course is fired.
+	Hold          *bool     `json:"hold,omitempty" bson:"-"`
+	Prep_status   string    `json:"prep_status"`
+	Created_at    time.Time `json:"created_at"`
+	Updated_at    time.Time `json:"updated_at"`
+	Food_id       *string   `json:"food_id" validate:"required"`
+	Order_item_id string    `json:"order_item_id"`
+	Order_id      string    `json:"order_id" validate:"required"`
+}
+
+// legacySizes maps the sizes items were ordered in before foods had
+// variants to the variant names used since.
+var legacySizes = map[string]string{"S": "Small", "M": "Medium", "L": "Large"}
+
+// UnmarshalBSON reads items stored before quantities were numbers. Their
+// size (S, M or L) was kept in the quantity field and is read as the
+// variant, so the item is priced like one ordered today.
+func (item *OrderItem) UnmarshalBSON(data []byte) error {
+	type storedItem OrderItem
+
+	var stored struct {
+		Item storedItem    `bson:",inline"`
+		Size bson.RawValue `bson:"quantity"`
+	}
+
+	if err := bson.Unmarshal(data, &stored); err != nil {
+		return err
+	}
+
+	*item = OrderItem(stored.Item)
+
+	if size, ok := stored.Size.StringValueOK(); ok && item.Variant == nil {
+		if variant, known := legacySizes[size]; known {
+			item.Variant = &variant
+		}
+	}
+
+	return nil
+}
+
+// Count returns how many portions the item stands for. Items stored
+// before quantities were numbers count as one.
+func (item OrderItem) Count() int {
+	if item.Quantity == nil || *item.Quantity < 1 {
+		return 1
+	}
+
+	return *item.Quantity
+}
+
+// CourseNumber returns the course the item is served in. Items without a
+// course belong to the first one.
+func (item OrderItem) CourseNumber() int {
+	if item.Course == nil {
+		return 1
+	}
+
+	return *item.Course
+}
+
+// VariantName returns the ordered variant, or "" for foods without
+// variants.
+func (item OrderItem) VariantName() string {
+	if item.Variant == nil {
+		return ""
+	}
+
+	return *item.Variant
+}
+
+// SelectedModifier is an option picked for an item. Price_delta is copied
+// from the food when the item is priced.
+type SelectedModifier struct {
+	Group       string  `json:"group" validate:"required"`
+	Option      string  `json:"option" validate:"required"`
+	Price_delta float64 `json:"price_delta"`
+}
+
+// PriceOverride records why an item was sold at a price other than the menu
+// price, who decided it and when.
+type PriceOverride struct {
+	Reason        string    `json:"reason" validate:"required,min=3,max=200"`
+	Menu_price    float64   `json:"menu_price"`
+	Overridden_by string    `json:"overridden_by"`
+	Overridden_at time.Time `json:"overridden_at"`
+}
+
+// Kinds of item adjustments. Items are voided before they reach the kitchen
+// and comped after.
+const (
+	AdjustmentVoid = "VOID"
+	AdjustmentComp = "COMP"
+)
+
+// Reason codes of item adjustments.
+const (
+	ReasonOrderError       = "ORDER_ERROR"
+	ReasonGuestChangedMind = "GUEST_CHANGED_MIND"
+	ReasonQualityIssue     = "QUALITY_ISSUE"
+	ReasonLongWait         = "LONG_WAIT"
+	ReasonHospitality      = "HOSPITALITY"
+)
+
+// ItemAdjustment records that an item was voided or comped. The item keeps
+// its line on the order; Unit_price holds what it cost before it was set to
+// zero.
+type ItemAdjustment struct {
+	Type         string    `json:"type"`
+	Reason_code  string    `json:"reason_code"`
+	Note         *string   `json:"note"`
+	Unit_price   *float64  `json:"unit_price"`
+	Requested_by string    `json:"requested_by"`
+	Approved_by  string    `json:"approved_by"`
+	Adjusted_at  time.Time `json:"adjusted_at"`
 }
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestLegacyItemSizesAreReadAsVariants(t *testing.T) {
+	cases := []struct {
+		name    string
+		doc     bson.M
+		variant string
+		count   int
+	}{
+		{name: "legacy size", doc: bson.M{"order_item_id": "1", "quantity": "L"}, variant: "Large", count: 1},
+		{name: "unknown legacy size", doc: bson.M{"order_item_id": "2", "quantity": "XL"}, variant: "", count: 1},
+		{name: "current item", doc: bson.M{"order_item_id": "3", "count": 2, "variant": "Small"}, variant: "Small", count: 2},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := bson.Marshal(tc.doc)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var item OrderItem
+
+			if err := bson.Unmarshal(data, &item); err != nil {
+				t.Fatal(err)
+			}
+
+			if item.Order_item_id != tc.doc["order_item_id"] || item.VariantName() != tc.variant || item.Count() != tc.count {
+				t.Errorf("read %+v, want variant %q and count %d", item, tc.variant, tc.count)
+			}
+		})
+	}
+}
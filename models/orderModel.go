@@ -6,11 +6,39 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+const (
+	OrderStatusOpen          = "OPEN"
+	OrderStatusSentToKitchen = "SENT_TO_KITCHEN"
+	OrderStatusServed        = "SERVED"
+	OrderStatusBilled        = "BILLED"
+	OrderStatusClosed        = "CLOSED"
+	OrderStatusCancelled     = "CANCELLED"
+)
+
 type Order struct {
-	ID         primitive.ObjectID `bson:"_id"`
-	Order_Date time.Time          `json:"order_date" validate:"required"`
-	Created_at time.Time          `json:"created_at"`
-	Updated_at time.Time          `json:"updated_at"`
-	Order_id   string             `json:"order_id"`
-	Table_id   *string            `json:"table_id" validate:"required"`
+	ID             primitive.ObjectID  `bson:"_id"`
+	Order_Date     time.Time           `json:"order_date" validate:"required"`
+	Created_at     time.Time           `json:"created_at"`
+	Updated_at     time.Time           `json:"updated_at"`
+	Order_id       string              `json:"order_id"`
+	Table_id       *string             `json:"table_id" validate:"required"`
+	Status         string              `json:"status"`
+	Status_history []OrderStatusChange `json:"status_history"`
+}
+
+// CurrentStatus returns the status of the order. Orders stored before
+// orders had a status count as OPEN.
+func (order Order) CurrentStatus() string {
+	if order.Status == "" {
+		return OrderStatusOpen
+	}
+
+	return order.Status
+}
+
+// OrderStatusChange records who moved an order into a status and when.
+type OrderStatusChange struct {
+	Status     string    `json:"status"`
+	Changed_by string    `json:"changed_by"`
+	Changed_at time.Time `json:"changed_at"`
 }
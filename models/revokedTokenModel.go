@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type RevokedToken struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	Kind       string             `json:"kind" validate:"required,eq=TOKEN|eq=USER|eq=SESSION|eq=TERMINAL"`
+	Jti        string             `json:"jti,omitempty" bson:"jti,omitempty"`
+	Session_id string             `json:"session_id,omitempty" bson:"session_id,omitempty"`
+	User_id    string             `json:"user_id"`
+	Revoked_at time.Time          `json:"revoked_at"`
+	Expires_at time.Time          `json:"expires_at"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Session struct {
+	ID                 primitive.ObjectID `bson:"_id"`
+	User_id            string             `json:"user_id"`
+	Device_name        string             `json:"device_name"`
+	Ip                 string             `json:"ip"`
+	User_agent         string             `json:"user_agent"`
+	Refresh_token_hash string             `json:"-"`
+	Created_at         time.Time          `json:"created_at"`
+	Last_seen_at       time.Time          `json:"last_seen_at"`
+	Revoked_at         *time.Time         `json:"revoked_at"`
+	Expires_at         time.Time          `json:"expires_at"`
+	Session_id         string             `json:"session_id"`
+}
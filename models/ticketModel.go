@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Preparation statuses of order items. Tickets use QUEUED, COOKING and
+// READY; HELD items have not been sent to the kitchen yet and VOIDED items
+// never will be.
+const (
+	PrepStatusHeld    = "HELD"
+	PrepStatusVoided  = "VOIDED"
+	PrepStatusQueued  = "QUEUED"
+	PrepStatusCooking = "COOKING"
+	PrepStatusReady   = "READY"
+	PrepStatusServed  = "SERVED"
+)
+
+// Ticket is the part of an order one kitchen station has to prepare. It is
+// created when the order is sent to the kitchen.
+type Ticket struct {
+	ID           primitive.ObjectID `bson:"_id"`
+	Ticket_id    string             `json:"ticket_id"`
+	Order_id     string             `json:"order_id"`
+	Table_id     *string            `json:"table_id"`
+	Table_number *int               `json:"table_number"`
+	Station      string             `json:"station"`
+	Status       string             `json:"status"`
+	Items        []TicketItem       `json:"items"`
+	Started_at   *time.Time         `json:"started_at"`
+	Ready_at     *time.Time         `json:"ready_at"`
+	Created_at   time.Time          `json:"created_at"`
+	Updated_at   time.Time          `json:"updated_at"`
+}
+
+// TicketItem is what the station sees of an order item.
+type TicketItem struct {
+	Order_item_id string             `json:"order_item_id"`
+	Food_name     *string            `json:"food_name"`
+	Course        int                `json:"course"`
+	Variant       *string            `json:"variant"`
+	Quantity      int                `json:"quantity"`
+	Modifiers     []SelectedModifier `json:"modifiers"`
+	Notes         *string            `json:"notes"`
+}
+
+// ItemIds returns the ids of the order items on the ticket.
+func (ticket Ticket) ItemIds() []string {
+	ids := make([]string, 0, len(ticket.Items))
+
+	for _, item := range ticket.Items {
+		ids = append(ids, item.Order_item_id)
+	}
+
+	return ids
+}
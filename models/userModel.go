@@ -7,17 +7,25 @@ import (
 )
 
 type User struct {
-	ID            primitive.ObjectID `bson:"_id"`
-	First_name    *string            `json:"first_name" validate:"required,min=2,max=100"`
-	Last_name     *string            `json:"last_name" validate:"required,min=2,max=100"`
-	Password      *string            `json:"Password" validate:"required,min=6"`
-	Email         *string            `json:"email" validate:"required"`
-	Avatar        *string            `json:"avatar"`
-	Phone         *string            `json:"phone" validate:"required"`
-	User_type     *string            `json:"user_type" validate:"required,eq=ADMIN|eq=USER"`
-	Token         *string            `json:"token"`
-	Refresh_Token *string            `json:"refresh_token"`
-	Created_at    time.Time          `json:"created_at"`
-	Updated_at    time.Time          `json:"updated_at"`
-	User_id       string             `json:"user_id"`
+	ID                 primitive.ObjectID `bson:"_id"`
+	First_name         *string            `json:"first_name" validate:"required,min=2,max=100"`
+	Last_name          *string            `json:"last_name" validate:"required,min=2,max=100"`
+	Password           *string            `json:"Password" validate:"required,min=6"`
+	Email              *string            `json:"email" validate:"required"`
+	Email_verified     bool               `json:"email_verified"`
+	Avatar             *string            `json:"avatar"`
+	Phone              *string            `json:"phone" validate:"required"`
+	User_type          *string            `json:"user_type" validate:"required,eq=ADMIN|eq=MANAGER|eq=WAITER|eq=CHEF|eq=CASHIER|eq=HOST|eq=USER"`
+	Token              *string            `json:"token"`
+	Refresh_Token      *string            `json:"refresh_token"`
+	Mfa_enabled        bool               `json:"mfa_enabled"`
+	Mfa_secret         *string            `json:"-"`
+	Mfa_last_step      int64              `json:"-"`
+	Mfa_recovery_codes []string           `json:"-"`
+	Mfa_enrollment_jti *string            `json:"-"`
+	Pin_hash           *string            `json:"-"`
+	Deactivated_at     *time.Time         `json:"deactivated_at"`
+	Created_at         time.Time          `json:"created_at"`
+	Updated_at         time.Time          `json:"updated_at"`
+	User_id            string             `json:"user_id"`
 }
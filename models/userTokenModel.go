@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type UserToken struct {
+	ID            primitive.ObjectID `bson:"_id"`
+	User_id       string             `json:"user_id" validate:"required"`
+	Purpose       string             `json:"purpose" validate:"required,eq=PASSWORD_RESET|eq=EMAIL_VERIFICATION"`
+	Token_hash    string             `json:"-"`
+	Expires_at    time.Time          `json:"expires_at"`
+	Used_at       *time.Time         `json:"used_at"`
+	Created_at    time.Time          `json:"created_at"`
+	User_token_id string             `json:"user_token_id"`
+}
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"context"
+	"golang-restaurant-management/models"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ApiKeyRepository interface {
+	List(ctx context.Context) ([]models.ApiKey, error)
+	Create(ctx context.Context, apiKey models.ApiKey) error
+	// GetActiveByHash returns the key with the given hash unless it has been
+	// revoked.
+	GetActiveByHash(ctx context.Context, keyHash string) (models.ApiKey, error)
+	// MarkUsed moves last_used_at to at if it is unset or older than
+	// staleBefore.
+	MarkUsed(ctx context.Context, apiKeyId string, at time.Time, staleBefore time.Time) error
+	// Revoke returns ErrNotFound if there is no active key with the id.
+	Revoke(ctx context.Context, apiKeyId string, at time.Time) error
+}
+
+type mongoApiKeyRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoApiKeyRepository) ensureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "key_hash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+
+	return err
+}
+
+func (r *mongoApiKeyRepository) List(ctx context.Context) ([]models.ApiKey, error) {
+	res, err := r.collection.Find(ctx, bson.M{})
+
+	if err != nil {
+		return nil, err
+	}
+
+	apiKeys := []models.ApiKey{}
+	err = res.All(ctx, &apiKeys)
+
+	return apiKeys, err
+}
+
+func (r *mongoApiKeyRepository) Create(ctx context.Context, apiKey models.ApiKey) error {
+	_, err := r.collection.InsertOne(ctx, apiKey)
+
+	return err
+}
+
+func (r *mongoApiKeyRepository) GetActiveByHash(ctx context.Context, keyHash string) (apiKey models.ApiKey, err error) {
+	err = r.collection.FindOne(ctx, bson.M{"key_hash": keyHash, "revoked_at": nil}).Decode(&apiKey)
+
+	return apiKey, notFound(err)
+}
+
+func (r *mongoApiKeyRepository) MarkUsed(ctx context.Context, apiKeyId string, at time.Time, staleBefore time.Time) error {
+	filter := bson.M{
+		"api_key_id": apiKeyId,
+		"$or": bson.A{
+			bson.M{"last_used_at": nil},
+			bson.M{"last_used_at": bson.M{"$lt": staleBefore}},
+		},
+	}
+
+	_, err := r.collection.UpdateOne(ctx, filter, bson.D{
+		{Key: "$set", Value: bson.D{{Key: "last_used_at", Value: at}}},
+	})
+
+	return err
+}
+
+func (r *mongoApiKeyRepository) Revoke(ctx context.Context, apiKeyId string, at time.Time) error {
+	return matched(r.collection.UpdateOne(ctx, bson.M{"api_key_id": apiKeyId, "revoked_at": nil}, bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "revoked_at", Value: at},
+			{Key: "updated_at", Value: at},
+		}},
+	}))
+}
+
+type memoryApiKeyRepository struct {
+	mu      sync.RWMutex
+	apiKeys []models.ApiKey
+}
+
+func (r *memoryApiKeyRepository) List(ctx context.Context) ([]models.ApiKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]models.ApiKey{}, r.apiKeys...), nil
+}
+
+func (r *memoryApiKeyRepository) Create(ctx context.Context, apiKey models.ApiKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.apiKeys = append(r.apiKeys, apiKey)
+
+	return nil
+}
+
+func (r *memoryApiKeyRepository) GetActiveByHash(ctx context.Context, keyHash string) (models.ApiKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, apiKey := range r.apiKeys {
+		if apiKey.Key_hash == keyHash && apiKey.Revoked_at == nil {
+			return apiKey, nil
+		}
+	}
+
+	return models.ApiKey{}, ErrNotFound
+}
+
+func (r *memoryApiKeyRepository) MarkUsed(ctx context.Context, apiKeyId string, at time.Time, staleBefore time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.apiKeys {
+		lastUsedAt := r.apiKeys[i].Last_used_at
+
+		if r.apiKeys[i].Api_key_id == apiKeyId && (lastUsedAt == nil || lastUsedAt.Before(staleBefore)) {
+			r.apiKeys[i].Last_used_at = &at
+		}
+	}
+
+	return nil
+}
+
+func (r *memoryApiKeyRepository) Revoke(ctx context.Context, apiKeyId string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.apiKeys {
+		if r.apiKeys[i].Api_key_id == apiKeyId && r.apiKeys[i].Revoked_at == nil {
+			r.apiKeys[i].Revoked_at = &at
+			r.apiKeys[i].Updated_at = at
+			return nil
+		}
+	}
+
+	return ErrNotFound
+}
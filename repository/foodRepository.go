@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"golang-restaurant-management/models"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type FoodRepository interface {
+	// List returns one page of foods together with the total count.
+	List(ctx context.Context, page Page) ([]models.Food, int64, error)
+	Get(ctx context.Context, foodId string) (models.Food, error)
+	Create(ctx context.Context, food models.Food) error
+	// Update replaces the stored food with the same food_id.
+	Update(ctx context.Context, food models.Food) error
+	Delete(ctx context.Context, foodId string) error
+}
+
+type mongoFoodRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoFoodRepository) List(ctx context.Context, page Page) ([]models.Food, int64, error) {
+	total, err := r.collection.CountDocuments(ctx, bson.M{})
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetSkip(int64(page.Offset))
+
+	if page.Limit > 0 {
+		opts.SetLimit(int64(page.Limit))
+	}
+
+	res, err := r.collection.Find(ctx, bson.M{}, opts)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	foods := []models.Food{}
+	err = res.All(ctx, &foods)
+
+	return foods, total, err
+}
+
+func (r *mongoFoodRepository) Get(ctx context.Context, foodId string) (food models.Food, err error) {
+	err = r.collection.FindOne(ctx, bson.M{"food_id": foodId}).Decode(&food)
+
+	return food, notFound(err)
+}
+
+func (r *mongoFoodRepository) Create(ctx context.Context, food models.Food) error {
+	_, err := r.collection.InsertOne(ctx, food)
+
+	return err
+}
+
+func (r *mongoFoodRepository) Update(ctx context.Context, food models.Food) error {
+	return matched(r.collection.ReplaceOne(ctx, bson.M{"food_id": food.Food_id}, food))
+}
+
+func (r *mongoFoodRepository) Delete(ctx context.Context, foodId string) error {
+	return deleted(r.collection.DeleteOne(ctx, bson.M{"food_id": foodId}))
+}
+
+type memoryFoodRepository struct {
+	mu    sync.RWMutex
+	foods []models.Food
+}
+
+func (r *memoryFoodRepository) List(ctx context.Context, page Page) ([]models.Food, int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	foods := append([]models.Food{}, paginate(r.foods, page)...)
+
+	return foods, int64(len(r.foods)), nil
+}
+
+func (r *memoryFoodRepository) Get(ctx context.Context, foodId string) (models.Food, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, food := range r.foods {
+		if food.Food_id == foodId {
+			return food, nil
+		}
+	}
+
+	return models.Food{}, ErrNotFound
+}
+
+func (r *memoryFoodRepository) Create(ctx context.Context, food models.Food) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.foods = append(r.foods, food)
+
+	return nil
+}
+
+func (r *memoryFoodRepository) Update(ctx context.Context, food models.Food) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.foods {
+		if r.foods[i].Food_id == food.Food_id {
+			r.foods[i] = food
+			return nil
+		}
+	}
+
+	return ErrNotFound
+}
+
+func (r *memoryFoodRepository) Delete(ctx context.Context, foodId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.foods {
+		if r.foods[i].Food_id == foodId {
+			r.foods = append(r.foods[:i], r.foods[i+1:]...)
+			return nil
+		}
+	}
+
+	return ErrNotFound
+}
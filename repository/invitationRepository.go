@@ -0,0 +1,221 @@
+package repository
+
+import (
+	"context"
+	"golang-restaurant-management/models"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// InvitationRepository stores staff invitations. An invitation is pending
+// while it is neither accepted, revoked nor expired.
+type InvitationRepository interface {
+	// ListPending returns the invitations pending at now, newest first.
+	ListPending(ctx context.Context, now time.Time) ([]models.Invitation, error)
+	GetPendingByHash(ctx context.Context, tokenHash string, now time.Time) (models.Invitation, error)
+	Create(ctx context.Context, invitation models.Invitation) error
+	// RevokePendingForEmail revokes every pending invitation of the email.
+	RevokePendingForEmail(ctx context.Context, email string, at time.Time) error
+	// Revoke returns ErrNotFound if the invitation is not pending.
+	Revoke(ctx context.Context, invitationId string, at time.Time) error
+	// Accept records the user created from the invitation. It returns
+	// ErrNotFound if the invitation is no longer pending, so the same
+	// invitation cannot create two users.
+	Accept(ctx context.Context, invitationId string, userId string, at time.Time) error
+}
+
+type mongoInvitationRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoInvitationRepository) ensureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "token_hash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "email", Value: 1}},
+		},
+	})
+
+	return err
+}
+
+func pendingInvitationFilter(now time.Time) bson.M {
+	return bson.M{
+		"accepted_at": nil,
+		"revoked_at":  nil,
+		"expires_at":  bson.M{"$gt": now},
+	}
+}
+
+func (r *mongoInvitationRepository) ListPending(ctx context.Context, now time.Time) ([]models.Invitation, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+	res, err := r.collection.Find(ctx, pendingInvitationFilter(now), opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	invitations := []models.Invitation{}
+	err = res.All(ctx, &invitations)
+
+	return invitations, err
+}
+
+func (r *mongoInvitationRepository) GetPendingByHash(ctx context.Context, tokenHash string, now time.Time) (invitation models.Invitation, err error) {
+	filter := pendingInvitationFilter(now)
+	filter["token_hash"] = tokenHash
+
+	err = r.collection.FindOne(ctx, filter).Decode(&invitation)
+
+	return invitation, notFound(err)
+}
+
+func (r *mongoInvitationRepository) Create(ctx context.Context, invitation models.Invitation) error {
+	_, err := r.collection.InsertOne(ctx, invitation)
+
+	return err
+}
+
+func (r *mongoInvitationRepository) RevokePendingForEmail(ctx context.Context, email string, at time.Time) error {
+	filter := pendingInvitationFilter(at)
+	filter["email"] = email
+
+	_, err := r.collection.UpdateMany(ctx, filter, bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "revoked_at", Value: at},
+			{Key: "updated_at", Value: at},
+		}},
+	})
+
+	return err
+}
+
+func (r *mongoInvitationRepository) Revoke(ctx context.Context, invitationId string, at time.Time) error {
+	filter := pendingInvitationFilter(at)
+	filter["invitation_id"] = invitationId
+
+	return matched(r.collection.UpdateOne(ctx, filter, bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "revoked_at", Value: at},
+			{Key: "updated_at", Value: at},
+		}},
+	}))
+}
+
+func (r *mongoInvitationRepository) Accept(ctx context.Context, invitationId string, userId string, at time.Time) error {
+	filter := pendingInvitationFilter(at)
+	filter["invitation_id"] = invitationId
+
+	return matched(r.collection.UpdateOne(ctx, filter, bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "accepted_at", Value: at},
+			{Key: "user_id", Value: userId},
+			{Key: "updated_at", Value: at},
+		}},
+	}))
+}
+
+type memoryInvitationRepository struct {
+	mu          sync.Mutex
+	invitations []models.Invitation
+}
+
+func invitationPending(invitation models.Invitation, now time.Time) bool {
+	return invitation.Accepted_at == nil && invitation.Revoked_at == nil && invitation.Expires_at.After(now)
+}
+
+func (r *memoryInvitationRepository) ListPending(ctx context.Context, now time.Time) ([]models.Invitation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	invitations := []models.Invitation{}
+
+	for _, invitation := range r.invitations {
+		if invitationPending(invitation, now) {
+			invitations = append(invitations, invitation)
+		}
+	}
+
+	sort.SliceStable(invitations, func(i, j int) bool {
+		return invitations[i].Created_at.After(invitations[j].Created_at)
+	})
+
+	return invitations, nil
+}
+
+func (r *memoryInvitationRepository) GetPendingByHash(ctx context.Context, tokenHash string, now time.Time) (models.Invitation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, invitation := range r.invitations {
+		if invitation.Token_hash == tokenHash && invitationPending(invitation, now) {
+			return invitation, nil
+		}
+	}
+
+	return models.Invitation{}, ErrNotFound
+}
+
+func (r *memoryInvitationRepository) Create(ctx context.Context, invitation models.Invitation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.invitations = append(r.invitations, invitation)
+
+	return nil
+}
+
+func (r *memoryInvitationRepository) RevokePendingForEmail(ctx context.Context, email string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.invitations {
+		invitation := &r.invitations[i]
+
+		if invitation.Email != nil && *invitation.Email == email && invitationPending(*invitation, at) {
+			invitation.Revoked_at = &at
+			invitation.Updated_at = at
+		}
+	}
+
+	return nil
+}
+
+func (r *memoryInvitationRepository) Revoke(ctx context.Context, invitationId string, at time.Time) error {
+	return r.modifyPending(invitationId, at, func(invitation *models.Invitation) {
+		invitation.Revoked_at = &at
+	})
+}
+
+func (r *memoryInvitationRepository) Accept(ctx context.Context, invitationId string, userId string, at time.Time) error {
+	return r.modifyPending(invitationId, at, func(invitation *models.Invitation) {
+		invitation.Accepted_at = &at
+		invitation.User_id = &userId
+	})
+}
+
+func (r *memoryInvitationRepository) modifyPending(invitationId string, at time.Time, change func(*models.Invitation)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.invitations {
+		invitation := &r.invitations[i]
+
+		if invitation.Invitation_id == invitationId && invitationPending(*invitation, at) {
+			change(invitation)
+			invitation.Updated_at = at
+			return nil
+		}
+	}
+
+	return ErrNotFound
+}
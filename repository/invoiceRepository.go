@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"golang-restaurant-management/models"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type InvoiceRepository interface {
+	List(ctx context.Context) ([]models.Invoice, error)
+	Get(ctx context.Context, invoiceId string) (models.Invoice, error)
+	Create(ctx context.Context, invoice models.Invoice) error
+	// Update replaces the stored invoice with the same invoice_id.
+	Update(ctx context.Context, invoice models.Invoice) error
+	Delete(ctx context.Context, invoiceId string) error
+}
+
+type mongoInvoiceRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoInvoiceRepository) List(ctx context.Context) ([]models.Invoice, error) {
+	res, err := r.collection.Find(ctx, bson.M{})
+
+	if err != nil {
+		return nil, err
+	}
+
+	invoices := []models.Invoice{}
+	err = res.All(ctx, &invoices)
+
+	return invoices, err
+}
+
+func (r *mongoInvoiceRepository) Get(ctx context.Context, invoiceId string) (invoice models.Invoice, err error) {
+	err = r.collection.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&invoice)
+
+	return invoice, notFound(err)
+}
+
+func (r *mongoInvoiceRepository) Create(ctx context.Context, invoice models.Invoice) error {
+	_, err := r.collection.InsertOne(ctx, invoice)
+
+	return err
+}
+
+func (r *mongoInvoiceRepository) Update(ctx context.Context, invoice models.Invoice) error {
+	return matched(r.collection.ReplaceOne(ctx, bson.M{"invoice_id": invoice.Invoice_id}, invoice))
+}
+
+func (r *mongoInvoiceRepository) Delete(ctx context.Context, invoiceId string) error {
+	return deleted(r.collection.DeleteOne(ctx, bson.M{"invoice_id": invoiceId}))
+}
+
+type memoryInvoiceRepository struct {
+	mu       sync.RWMutex
+	invoices []models.Invoice
+}
+
+func (r *memoryInvoiceRepository) List(ctx context.Context) ([]models.Invoice, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]models.Invoice{}, r.invoices...), nil
+}
+
+func (r *memoryInvoiceRepository) Get(ctx context.Context, invoiceId string) (models.Invoice, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, invoice := range r.invoices {
+		if invoice.Invoice_id == invoiceId {
+			return invoice, nil
+		}
+	}
+
+	return models.Invoice{}, ErrNotFound
+}
+
+func (r *memoryInvoiceRepository) Create(ctx context.Context, invoice models.Invoice) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.invoices = append(r.invoices, invoice)
+
+	return nil
+}
+
+func (r *memoryInvoiceRepository) Update(ctx context.Context, invoice models.Invoice) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.invoices {
+		if r.invoices[i].Invoice_id == invoice.Invoice_id {
+			r.invoices[i] = invoice
+			return nil
+		}
+	}
+
+	return ErrNotFound
+}
+
+func (r *memoryInvoiceRepository) Delete(ctx context.Context, invoiceId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.invoices {
+		if r.invoices[i].Invoice_id == invoiceId {
+			r.invoices = append(r.invoices[:i], r.invoices[i+1:]...)
+			return nil
+		}
+	}
+
+	return ErrNotFound
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"golang-restaurant-management/models"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type LoginAttemptRepository interface {
+	List(ctx context.Context, keys []string) ([]models.LoginAttempt, error)
+	// RecordFailure increments the failure counter of the key, creating it
+	// if needed, and returns the updated attempt.
+	RecordFailure(ctx context.Context, key string, at time.Time, expiresAt time.Time) (models.LoginAttempt, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	Delete(ctx context.Context, key string) error
+}
+
+type mongoLoginAttemptRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoLoginAttemptRepository) ensureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+		{
+			Keys:    bson.D{{Key: "key", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	})
+
+	return err
+}
+
+func (r *mongoLoginAttemptRepository) List(ctx context.Context, keys []string) ([]models.LoginAttempt, error) {
+	res, err := r.collection.Find(ctx, bson.M{"key": bson.M{"$in": keys}})
+
+	if err != nil {
+		return nil, err
+	}
+
+	attempts := []models.LoginAttempt{}
+	err = res.All(ctx, &attempts)
+
+	return attempts, err
+}
+
+func (r *mongoLoginAttemptRepository) RecordFailure(ctx context.Context, key string, at time.Time, expiresAt time.Time) (attempt models.LoginAttempt, err error) {
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	err = r.collection.FindOneAndUpdate(ctx, bson.M{"key": key}, bson.D{
+		{Key: "$inc", Value: bson.D{{Key: "failures", Value: 1}}},
+		{Key: "$set", Value: bson.D{
+			{Key: "last_failed_at", Value: at},
+			{Key: "expires_at", Value: expiresAt},
+		}},
+	}, opts).Decode(&attempt)
+
+	return attempt, err
+}
+
+func (r *mongoLoginAttemptRepository) Lock(ctx context.Context, key string, until time.Time) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"key": key}, bson.D{
+		{Key: "$set", Value: bson.D{{Key: "locked_until", Value: until}}},
+	})
+
+	return err
+}
+
+func (r *mongoLoginAttemptRepository) Delete(ctx context.Context, key string) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"key": key})
+
+	return err
+}
+
+type memoryLoginAttemptRepository struct {
+	mu       sync.Mutex
+	attempts map[string]models.LoginAttempt
+}
+
+func (r *memoryLoginAttemptRepository) List(ctx context.Context, keys []string) ([]models.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempts := []models.LoginAttempt{}
+
+	for _, key := range keys {
+		if attempt, ok := r.attempts[key]; ok {
+			attempts = append(attempts, attempt)
+		}
+	}
+
+	return attempts, nil
+}
+
+func (r *memoryLoginAttemptRepository) RecordFailure(ctx context.Context, key string, at time.Time, expiresAt time.Time) (models.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.attempts == nil {
+		r.attempts = map[string]models.LoginAttempt{}
+	}
+
+	attempt, ok := r.attempts[key]
+
+	if !ok {
+		attempt = models.LoginAttempt{ID: primitive.NewObjectID(), Key: key}
+	}
+
+	attempt.Failures++
+	attempt.Last_failed_at = at
+	attempt.Expires_at = expiresAt
+	r.attempts[key] = attempt
+
+	return attempt, nil
+}
+
+func (r *memoryLoginAttemptRepository) Lock(ctx context.Context, key string, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if attempt, ok := r.attempts[key]; ok {
+		attempt.Locked_until = &until
+		r.attempts[key] = attempt
+	}
+
+	return nil
+}
+
+func (r *memoryLoginAttemptRepository) Delete(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.attempts, key)
+
+	return nil
+}
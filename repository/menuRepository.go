@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"golang-restaurant-management/models"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MenuRepository interface {
+	List(ctx context.Context) ([]models.Menu, error)
+	Get(ctx context.Context, menuId string) (models.Menu, error)
+	Create(ctx context.Context, menu models.Menu) error
+	// Update replaces the stored menu with the same menu_id.
+	Update(ctx context.Context, menu models.Menu) error
+	Delete(ctx context.Context, menuId string) error
+}
+
+type mongoMenuRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoMenuRepository) List(ctx context.Context) ([]models.Menu, error) {
+	res, err := r.collection.Find(ctx, bson.M{})
+
+	if err != nil {
+		return nil, err
+	}
+
+	menus := []models.Menu{}
+	err = res.All(ctx, &menus)
+
+	return menus, err
+}
+
+func (r *mongoMenuRepository) Get(ctx context.Context, menuId string) (menu models.Menu, err error) {
+	err = r.collection.FindOne(ctx, bson.M{"menu_id": menuId}).Decode(&menu)
+
+	return menu, notFound(err)
+}
+
+func (r *mongoMenuRepository) Create(ctx context.Context, menu models.Menu) error {
+	_, err := r.collection.InsertOne(ctx, menu)
+
+	return err
+}
+
+func (r *mongoMenuRepository) Update(ctx context.Context, menu models.Menu) error {
+	return matched(r.collection.ReplaceOne(ctx, bson.M{"menu_id": menu.Menu_id}, menu))
+}
+
+func (r *mongoMenuRepository) Delete(ctx context.Context, menuId string) error {
+	return deleted(r.collection.DeleteOne(ctx, bson.M{"menu_id": menuId}))
+}
+
+type memoryMenuRepository struct {
+	mu    sync.RWMutex
+	menus []models.Menu
+}
+
+func (r *memoryMenuRepository) List(ctx context.Context) ([]models.Menu, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]models.Menu{}, r.menus...), nil
+}
+
+func (r *memoryMenuRepository) Get(ctx context.Context, menuId string) (models.Menu, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, menu := range r.menus {
+		if menu.Menu_id == menuId {
+			return menu, nil
+		}
+	}
+
+	return models.Menu{}, ErrNotFound
+}
+
+func (r *memoryMenuRepository) Create(ctx context.Context, menu models.Menu) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.menus = append(r.menus, menu)
+
+	return nil
+}
+
+func (r *memoryMenuRepository) Update(ctx context.Context, menu models.Menu) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.menus {
+		if r.menus[i].Menu_id == menu.Menu_id {
+			r.menus[i] = menu
+			return nil
+		}
+	}
+
+	return ErrNotFound
+}
+
+func (r *memoryMenuRepository) Delete(ctx context.Context, menuId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.menus {
+		if r.menus[i].Menu_id == menuId {
+			r.menus = append(r.menus[:i], r.menus[i+1:]...)
+			return nil
+		}
+	}
+
+	return ErrNotFound
+}
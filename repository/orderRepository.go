@@ -0,0 +1,467 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"golang-restaurant-management/models"
+	"slices"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// OrderRepository stores orders together with their order items.
+type OrderRepository interface {
+	// List returns the orders in the given status, or every order when
+	// status is empty.
+	List(ctx context.Context, status string) ([]models.Order, error)
+	Get(ctx context.Context, orderId string) (models.Order, error)
+	Create(ctx context.Context, order models.Order) error
+	// Update replaces the stored order as long as it is still in status, so
+	// two concurrent status changes cannot both succeed. Otherwise it
+	// returns ErrNotFound.
+	Update(ctx context.Context, order models.Order, status string) error
+	Delete(ctx context.Context, orderId string) error
+
+	ListItems(ctx context.Context) ([]models.OrderItem, error)
+	ListItemsByOrder(ctx context.Context, orderId string) ([]models.OrderItem, error)
+	GetItem(ctx context.Context, orderItemId string) (models.OrderItem, error)
+	CreateItems(ctx context.Context, items []models.OrderItem) error
+	// CreateWithItems stores an order together with its items. Either all
+	// of them are written or none is.
+	CreateWithItems(ctx context.Context, order models.Order, items []models.OrderItem) error
+	// UpdateItem replaces the stored item with the same order_item_id.
+	UpdateItem(ctx context.Context, item models.OrderItem) error
+	// AdjustItem replaces the stored item as long as it has no adjustment
+	// yet and is still in prepStatus, so two concurrent voids or comps
+	// cannot both succeed. Otherwise it returns ErrNotFound.
+	AdjustItem(ctx context.Context, item models.OrderItem, prepStatus string) error
+	DeleteItem(ctx context.Context, orderItemId string) error
+	// DeleteItems removes the given items. Ids that are not stored are
+	// skipped.
+	DeleteItems(ctx context.Context, orderItemIds []string) error
+	// SetItemsPrepStatus sets the preparation status of the given items.
+	SetItemsPrepStatus(ctx context.Context, orderItemIds []string, status string) error
+	// MoveItemsPrepStatus sets the preparation status of those of the given
+	// items that are still in status from and returns their ids. On error
+	// the ids moved so far are returned with it.
+	MoveItemsPrepStatus(ctx context.Context, orderItemIds []string, from string, to string) ([]string, error)
+}
+
+type mongoOrderRepository struct {
+	orders *mongo.Collection
+	items  *mongo.Collection
+}
+
+func (r *mongoOrderRepository) ensureIndexes(ctx context.Context) error {
+	_, err := r.items.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "order_id", Value: 1}},
+	})
+
+	return err
+}
+
+func (r *mongoOrderRepository) List(ctx context.Context, status string) ([]models.Order, error) {
+	filter := bson.M{}
+
+	if status != "" {
+		filter["status"] = statusFilter(status)
+	}
+
+	res, err := r.orders.Find(ctx, filter)
+
+	if err != nil {
+		return nil, err
+	}
+
+	orders := []models.Order{}
+	err = res.All(ctx, &orders)
+
+	return orders, err
+}
+
+// statusFilter matches orders in status, including orders stored without a
+// status when status is OPEN.
+func statusFilter(status string) interface{} {
+	if status == models.OrderStatusOpen {
+		return bson.M{"$in": bson.A{status, nil}}
+	}
+
+	return status
+}
+
+func (r *mongoOrderRepository) Get(ctx context.Context, orderId string) (order models.Order, err error) {
+	err = r.orders.FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order)
+
+	return order, notFound(err)
+}
+
+func (r *mongoOrderRepository) Create(ctx context.Context, order models.Order) error {
+	_, err := r.orders.InsertOne(ctx, order)
+
+	return err
+}
+
+func (r *mongoOrderRepository) Update(ctx context.Context, order models.Order, status string) error {
+	return matched(r.orders.ReplaceOne(ctx, bson.M{"order_id": order.Order_id, "status": statusFilter(status)}, order))
+}
+
+func (r *mongoOrderRepository) Delete(ctx context.Context, orderId string) error {
+	return deleted(r.orders.DeleteOne(ctx, bson.M{"order_id": orderId}))
+}
+
+func (r *mongoOrderRepository) ListItems(ctx context.Context) ([]models.OrderItem, error) {
+	return r.findItems(ctx, bson.M{})
+}
+
+func (r *mongoOrderRepository) ListItemsByOrder(ctx context.Context, orderId string) ([]models.OrderItem, error) {
+	return r.findItems(ctx, bson.M{"order_id": orderId})
+}
+
+func (r *mongoOrderRepository) findItems(ctx context.Context, filter bson.M) ([]models.OrderItem, error) {
+	res, err := r.items.Find(ctx, filter)
+
+	if err != nil {
+		return nil, err
+	}
+
+	items := []models.OrderItem{}
+	err = res.All(ctx, &items)
+
+	return items, err
+}
+
+func (r *mongoOrderRepository) GetItem(ctx context.Context, orderItemId string) (item models.OrderItem, err error) {
+	err = r.items.FindOne(ctx, bson.M{"order_item_id": orderItemId}).Decode(&item)
+
+	return item, notFound(err)
+}
+
+func (r *mongoOrderRepository) CreateItems(ctx context.Context, items []models.OrderItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	docs := make([]interface{}, 0, len(items))
+
+	for _, item := range items {
+		docs = append(docs, item)
+	}
+
+	_, err := r.items.InsertMany(ctx, docs)
+
+	return err
+}
+
+// CreateWithItems runs both inserts in a transaction. Standalone servers
+// cannot run transactions, so there the inserts run on their own and the
+// order is deleted again if its items fail. Errors of that cleanup are
+// returned together with the insert error.
+func (r *mongoOrderRepository) CreateWithItems(ctx context.Context, order models.Order, items []models.OrderItem) error {
+	session, err := r.orders.Database().Client().StartSession()
+
+	if err != nil {
+		return err
+	}
+
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, r.insertWithItems(sc, order, items)
+	})
+
+	if !transactionsUnsupported(err) {
+		return err
+	}
+
+	if err := r.insertWithItems(ctx, order, items); err != nil {
+		_, itemsErr := r.items.DeleteMany(ctx, bson.M{"order_id": order.Order_id})
+		_, orderErr := r.orders.DeleteOne(ctx, bson.M{"order_id": order.Order_id})
+
+		return errors.Join(err, itemsErr, orderErr)
+	}
+
+	return nil
+}
+
+func (r *mongoOrderRepository) insertWithItems(ctx context.Context, order models.Order, items []models.OrderItem) error {
+	if err := r.Create(ctx, order); err != nil {
+		return err
+	}
+
+	return r.CreateItems(ctx, items)
+}
+
+func (r *mongoOrderRepository) UpdateItem(ctx context.Context, item models.OrderItem) error {
+	return matched(r.items.ReplaceOne(ctx, bson.M{"order_item_id": item.Order_item_id}, item))
+}
+
+func (r *mongoOrderRepository) AdjustItem(ctx context.Context, item models.OrderItem, prepStatus string) error {
+	filter := bson.M{"order_item_id": item.Order_item_id, "adjustment": nil, "prep_status": prepStatusFilter(prepStatus)}
+
+	return matched(r.items.ReplaceOne(ctx, filter, item))
+}
+
+func (r *mongoOrderRepository) DeleteItem(ctx context.Context, orderItemId string) error {
+	return deleted(r.items.DeleteOne(ctx, bson.M{"order_item_id": orderItemId}))
+}
+
+func (r *mongoOrderRepository) DeleteItems(ctx context.Context, orderItemIds []string) error {
+	if len(orderItemIds) == 0 {
+		return nil
+	}
+
+	_, err := r.items.DeleteMany(ctx, bson.M{"order_item_id": bson.M{"$in": orderItemIds}})
+
+	return err
+}
+
+func (r *mongoOrderRepository) SetItemsPrepStatus(ctx context.Context, orderItemIds []string, status string) error {
+	_, err := r.items.UpdateMany(ctx, bson.M{"order_item_id": bson.M{"$in": orderItemIds}}, bson.M{"$set": bson.M{"prep_status": status}})
+
+	return err
+}
+
+func (r *mongoOrderRepository) MoveItemsPrepStatus(ctx context.Context, orderItemIds []string, from string, to string) ([]string, error) {
+	moved := []string{}
+
+	for _, id := range orderItemIds {
+		res, err := r.items.UpdateOne(ctx, bson.M{"order_item_id": id, "prep_status": prepStatusFilter(from)}, bson.M{"$set": bson.M{"prep_status": to}})
+
+		if err != nil {
+			return moved, err
+		}
+
+		if res.MatchedCount > 0 {
+			moved = append(moved, id)
+		}
+	}
+
+	return moved, nil
+}
+
+// prepStatusFilter matches items in the preparation status. Items stored
+// before preparation was tracked have no prep_status and count as unsent.
+func prepStatusFilter(status string) interface{} {
+	if status == "" {
+		return bson.M{"$in": bson.A{"", nil}}
+	}
+
+	return status
+}
+
+type memoryOrderRepository struct {
+	mu     sync.RWMutex
+	orders []models.Order
+	items  []models.OrderItem
+}
+
+func (r *memoryOrderRepository) List(ctx context.Context, status string) ([]models.Order, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	orders := []models.Order{}
+
+	for _, order := range r.orders {
+		if status == "" || order.CurrentStatus() == status {
+			orders = append(orders, order)
+		}
+	}
+
+	return orders, nil
+}
+
+func (r *memoryOrderRepository) Get(ctx context.Context, orderId string) (models.Order, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, order := range r.orders {
+		if order.Order_id == orderId {
+			return order, nil
+		}
+	}
+
+	return models.Order{}, ErrNotFound
+}
+
+func (r *memoryOrderRepository) Create(ctx context.Context, order models.Order) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.orders = append(r.orders, order)
+
+	return nil
+}
+
+func (r *memoryOrderRepository) Update(ctx context.Context, order models.Order, status string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.orders {
+		if r.orders[i].Order_id == order.Order_id && r.orders[i].CurrentStatus() == status {
+			r.orders[i] = order
+			return nil
+		}
+	}
+
+	return ErrNotFound
+}
+
+func (r *memoryOrderRepository) Delete(ctx context.Context, orderId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.orders {
+		if r.orders[i].Order_id == orderId {
+			r.orders = append(r.orders[:i], r.orders[i+1:]...)
+			return nil
+		}
+	}
+
+	return ErrNotFound
+}
+
+func (r *memoryOrderRepository) ListItems(ctx context.Context) ([]models.OrderItem, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]models.OrderItem{}, r.items...), nil
+}
+
+func (r *memoryOrderRepository) ListItemsByOrder(ctx context.Context, orderId string) ([]models.OrderItem, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	items := []models.OrderItem{}
+
+	for _, item := range r.items {
+		if item.Order_id == orderId {
+			items = append(items, item)
+		}
+	}
+
+	return items, nil
+}
+
+func (r *memoryOrderRepository) GetItem(ctx context.Context, orderItemId string) (models.OrderItem, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, item := range r.items {
+		if item.Order_item_id == orderItemId {
+			return item, nil
+		}
+	}
+
+	return models.OrderItem{}, ErrNotFound
+}
+
+func (r *memoryOrderRepository) CreateItems(ctx context.Context, items []models.OrderItem) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.items = append(r.items, items...)
+
+	return nil
+}
+
+func (r *memoryOrderRepository) CreateWithItems(ctx context.Context, order models.Order, items []models.OrderItem) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.orders = append(r.orders, order)
+	r.items = append(r.items, items...)
+
+	return nil
+}
+
+func (r *memoryOrderRepository) UpdateItem(ctx context.Context, item models.OrderItem) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.items {
+		if r.items[i].Order_item_id == item.Order_item_id {
+			r.items[i] = item
+			return nil
+		}
+	}
+
+	return ErrNotFound
+}
+
+func (r *memoryOrderRepository) AdjustItem(ctx context.Context, item models.OrderItem, prepStatus string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.items {
+		if r.items[i].Order_item_id == item.Order_item_id && r.items[i].Adjustment == nil && r.items[i].Prep_status == prepStatus {
+			r.items[i] = item
+			return nil
+		}
+	}
+
+	return ErrNotFound
+}
+
+func (r *memoryOrderRepository) DeleteItem(ctx context.Context, orderItemId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.items {
+		if r.items[i].Order_item_id == orderItemId {
+			r.items = append(r.items[:i], r.items[i+1:]...)
+			return nil
+		}
+	}
+
+	return ErrNotFound
+}
+
+func (r *memoryOrderRepository) DeleteItems(ctx context.Context, orderItemIds []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	kept := r.items[:0]
+
+	for _, item := range r.items {
+		if !slices.Contains(orderItemIds, item.Order_item_id) {
+			kept = append(kept, item)
+		}
+	}
+
+	r.items = kept
+
+	return nil
+}
+
+func (r *memoryOrderRepository) SetItemsPrepStatus(ctx context.Context, orderItemIds []string, status string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.items {
+		for _, id := range orderItemIds {
+			if r.items[i].Order_item_id == id {
+				r.items[i].Prep_status = status
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *memoryOrderRepository) MoveItemsPrepStatus(ctx context.Context, orderItemIds []string, from string, to string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	moved := []string{}
+
+	for i := range r.items {
+		if r.items[i].Prep_status == from && slices.Contains(orderItemIds, r.items[i].Order_item_id) {
+			r.items[i].Prep_status = to
+			moved = append(moved, r.items[i].Order_item_id)
+		}
+	}
+
+	return moved, nil
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrNotFound is returned when no record matches, including conditional
+// updates whose condition no longer holds (e.g. an already used token).
+var ErrNotFound = errors.New("record not found")
+
+// Page selects a slice of a listing.
+type Page struct {
+	Offset int
+	Limit  int
+}
+
+// Repositories bundles every store the handlers use. It is built once at
+// startup, either on top of Mongo or fully in memory for tests, and passed
+// down to the routes.
+type Repositories struct {
+	Foods         FoodRepository
+	Menus         MenuRepository
+	Tables        TableRepository
+	Orders        OrderRepository
+	Invoices      InvoiceRepository
+	Users         UserRepository
+	Sessions      SessionRepository
+	Revocations   RevocationRepository
+	ApiKeys       ApiKeyRepository
+	UserTokens    UserTokenRepository
+	LoginAttempts LoginAttemptRepository
+	Invitations   InvitationRepository
+	Tickets       TicketRepository
+}
+
+func NewMongoRepositories(db *mongo.Database) *Repositories {
+	return &Repositories{
+		Foods:         &mongoFoodRepository{collection: db.Collection("food")},
+		Menus:         &mongoMenuRepository{collection: db.Collection("menu")},
+		Tables:        &mongoTableRepository{collection: db.Collection("table")},
+		Orders:        &mongoOrderRepository{orders: db.Collection("orders"), items: db.Collection("order_items")},
+		Invoices:      &mongoInvoiceRepository{collection: db.Collection("invoice")},
+		Users:         &mongoUserRepository{collection: db.Collection("user")},
+		Sessions:      &mongoSessionRepository{collection: db.Collection("sessions")},
+		Revocations:   &mongoRevocationRepository{collection: db.Collection("revoked_tokens")},
+		ApiKeys:       &mongoApiKeyRepository{collection: db.Collection("api_keys")},
+		UserTokens:    &mongoUserTokenRepository{collection: db.Collection("user_tokens")},
+		LoginAttempts: &mongoLoginAttemptRepository{collection: db.Collection("login_attempts")},
+		Invitations:   &mongoInvitationRepository{collection: db.Collection("invitations")},
+		Tickets:       &mongoTicketRepository{collection: db.Collection("tickets")},
+	}
+}
+
+func NewMemoryRepositories() *Repositories {
+	return &Repositories{
+		Foods:         &memoryFoodRepository{},
+		Menus:         &memoryMenuRepository{},
+		Tables:        &memoryTableRepository{},
+		Orders:        &memoryOrderRepository{},
+		Invoices:      &memoryInvoiceRepository{},
+		Users:         &memoryUserRepository{},
+		Sessions:      &memorySessionRepository{},
+		Revocations:   &memoryRevocationRepository{},
+		ApiKeys:       &memoryApiKeyRepository{},
+		UserTokens:    &memoryUserTokenRepository{},
+		LoginAttempts: &memoryLoginAttemptRepository{},
+		Invitations:   &memoryInvitationRepository{},
+		Tickets:       &memoryTicketRepository{},
+	}
+}
+
+// indexedRepository is implemented by the Mongo repositories that need
+// indexes, e.g. the TTL indexes that expire tokens and sessions.
+type indexedRepository interface {
+	ensureIndexes(ctx context.Context) error
+}
+
+// EnsureIndexes creates the indexes of every repository that needs them.
+// It is a no-op for the in-memory repositories.
+func (r *Repositories) EnsureIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	for _, repo := range []interface{}{
+		r.Foods, r.Menus, r.Tables, r.Orders, r.Invoices, r.Users,
+		r.Sessions, r.Revocations, r.ApiKeys, r.UserTokens, r.LoginAttempts, r.Invitations, r.Tickets,
+	} {
+		if indexed, ok := repo.(indexedRepository); ok {
+			if err := indexed.ensureIndexes(ctx); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// notFound maps the driver's "no documents" error onto ErrNotFound.
+func notFound(err error) error {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrNotFound
+	}
+
+	return err
+}
+
+// transactionsUnsupported reports whether err is the server refusing a
+// transaction because it is neither a replica set member nor a mongos.
+func transactionsUnsupported(err error) bool {
+	var cmdErr mongo.CommandError
+
+	return errors.As(err, &cmdErr) && cmdErr.Code == 20
+}
+
+// matched turns the match count of a conditional update into ErrNotFound.
+func matched(res *mongo.UpdateResult, err error) error {
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// deleted turns a delete that removed nothing into ErrNotFound.
+func deleted(res *mongo.DeleteResult, err error) error {
+	if err != nil {
+		return err
+	}
+
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// paginate applies the page to an in-memory listing.
+func paginate[T any](rows []T, page Page) []T {
+	if page.Offset < 0 || page.Offset >= len(rows) {
+		return []T{}
+	}
+
+	end := len(rows)
+
+	if page.Limit > 0 && page.Offset+page.Limit < end {
+		end = page.Offset + page.Limit
+	}
+
+	return rows[page.Offset:end]
+}
@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"golang-restaurant-management/models"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Kinds of denylist entries. A TOKEN entry matches a single jti, a SESSION
+// entry every token of a session, a USER entry every token of the user
+// issued before the revocation and a TERMINAL entry only those of them that
+// belong to no session, such as PIN logins.
+const (
+	RevokedKindToken    = "TOKEN"
+	RevokedKindUser     = "USER"
+	RevokedKindSession  = "SESSION"
+	RevokedKindTerminal = "TERMINAL"
+)
+
+type RevocationRepository interface {
+	Add(ctx context.Context, revoked models.RevokedToken) error
+	// IsRevoked reports whether an entry matches the jti, the session or a
+	// user-wide revocation made at or after issuedAt. Empty ids are not
+	// checked; an empty sessionId makes terminal revocations apply.
+	IsRevoked(ctx context.Context, jti string, sessionId string, userId string, issuedAt time.Time) (bool, error)
+}
+
+type mongoRevocationRepository struct {
+	collection *mongo.Collection
+}
+
+// ensureIndexes also creates the TTL index that lets Mongo drop denylist
+// entries once the tokens they refer to have expired anyway.
+func (r *mongoRevocationRepository) ensureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+		{
+			Keys: bson.D{{Key: "jti", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "kind", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "session_id", Value: 1}},
+		},
+	})
+
+	return err
+}
+
+func (r *mongoRevocationRepository) Add(ctx context.Context, revoked models.RevokedToken) error {
+	_, err := r.collection.InsertOne(ctx, revoked)
+
+	return err
+}
+
+func (r *mongoRevocationRepository) IsRevoked(ctx context.Context, jti string, sessionId string, userId string, issuedAt time.Time) (bool, error) {
+	conditions := []bson.M{}
+
+	if jti != "" {
+		conditions = append(conditions, bson.M{"kind": RevokedKindToken, "jti": jti})
+	}
+
+	if sessionId != "" {
+		conditions = append(conditions, bson.M{"kind": RevokedKindSession, "session_id": sessionId})
+	}
+
+	if userId != "" {
+		conditions = append(conditions, bson.M{
+			"kind":       RevokedKindUser,
+			"user_id":    userId,
+			"revoked_at": bson.M{"$gte": issuedAt},
+		})
+	}
+
+	if userId != "" && sessionId == "" {
+		conditions = append(conditions, bson.M{
+			"kind":       RevokedKindTerminal,
+			"user_id":    userId,
+			"revoked_at": bson.M{"$gte": issuedAt},
+		})
+	}
+
+	if len(conditions) == 0 {
+		return false, nil
+	}
+
+	count, err := r.collection.CountDocuments(ctx, bson.M{"$or": conditions}, options.Count().SetLimit(1))
+
+	return count > 0, err
+}
+
+type memoryRevocationRepository struct {
+	mu      sync.RWMutex
+	entries []models.RevokedToken
+}
+
+func (r *memoryRevocationRepository) Add(ctx context.Context, revoked models.RevokedToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries = append(r.entries, revoked)
+
+	return nil
+}
+
+func (r *memoryRevocationRepository) IsRevoked(ctx context.Context, jti string, sessionId string, userId string, issuedAt time.Time) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, entry := range r.entries {
+		switch entry.Kind {
+		case RevokedKindToken:
+			if jti != "" && entry.Jti == jti {
+				return true, nil
+			}
+		case RevokedKindSession:
+			if sessionId != "" && entry.Session_id == sessionId {
+				return true, nil
+			}
+		case RevokedKindUser:
+			if userId != "" && entry.User_id == userId && !entry.Revoked_at.Before(issuedAt) {
+				return true, nil
+			}
+		case RevokedKindTerminal:
+			if userId != "" && sessionId == "" && entry.User_id == userId && !entry.Revoked_at.Before(issuedAt) {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
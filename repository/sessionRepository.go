@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"context"
+	"golang-restaurant-management/models"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type SessionRepository interface {
+	Create(ctx context.Context, session models.Session) error
+	Get(ctx context.Context, sessionId string) (models.Session, error)
+	// ListActive returns the sessions of the user that have not been
+	// revoked, most recently used first.
+	ListActive(ctx context.Context, userId string) ([]models.Session, error)
+	// RotateRefreshToken stores newHash only if currentHash is still the
+	// refresh token of the active session; otherwise it returns ErrNotFound.
+	RotateRefreshToken(ctx context.Context, sessionId string, currentHash string, newHash string, seenAt time.Time, expiresAt time.Time) error
+	// Touch moves last_seen_at to seenAt if it is older than staleBefore.
+	Touch(ctx context.Context, sessionId string, seenAt time.Time, staleBefore time.Time) error
+	// Revoke ends an active session; it returns ErrNotFound if the session
+	// does not exist or was already revoked.
+	Revoke(ctx context.Context, sessionId string, at time.Time) error
+}
+
+type mongoSessionRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoSessionRepository) ensureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+		{
+			Keys:    bson.D{{Key: "session_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}},
+		},
+	})
+
+	return err
+}
+
+func (r *mongoSessionRepository) Create(ctx context.Context, session models.Session) error {
+	_, err := r.collection.InsertOne(ctx, session)
+
+	return err
+}
+
+func (r *mongoSessionRepository) Get(ctx context.Context, sessionId string) (session models.Session, err error) {
+	err = r.collection.FindOne(ctx, bson.M{"session_id": sessionId}).Decode(&session)
+
+	return session, notFound(err)
+}
+
+func (r *mongoSessionRepository) ListActive(ctx context.Context, userId string) ([]models.Session, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "last_seen_at", Value: -1}})
+
+	res, err := r.collection.Find(ctx, bson.M{"user_id": userId, "revoked_at": nil}, opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := []models.Session{}
+	err = res.All(ctx, &sessions)
+
+	return sessions, err
+}
+
+func (r *mongoSessionRepository) RotateRefreshToken(ctx context.Context, sessionId string, currentHash string, newHash string, seenAt time.Time, expiresAt time.Time) error {
+	filter := bson.M{
+		"session_id":         sessionId,
+		"refresh_token_hash": currentHash,
+		"revoked_at":         nil,
+	}
+
+	return matched(r.collection.UpdateOne(ctx, filter, bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "refresh_token_hash", Value: newHash},
+			{Key: "last_seen_at", Value: seenAt},
+			{Key: "expires_at", Value: expiresAt},
+		}},
+	}))
+}
+
+func (r *mongoSessionRepository) Touch(ctx context.Context, sessionId string, seenAt time.Time, staleBefore time.Time) error {
+	filter := bson.M{
+		"session_id":   sessionId,
+		"last_seen_at": bson.M{"$lt": staleBefore},
+	}
+
+	_, err := r.collection.UpdateOne(ctx, filter, bson.D{
+		{Key: "$set", Value: bson.D{{Key: "last_seen_at", Value: seenAt}}},
+	})
+
+	return err
+}
+
+func (r *mongoSessionRepository) Revoke(ctx context.Context, sessionId string, at time.Time) error {
+	return matched(r.collection.UpdateOne(ctx, bson.M{"session_id": sessionId, "revoked_at": nil}, bson.D{
+		{Key: "$set", Value: bson.D{{Key: "revoked_at", Value: at}}},
+	}))
+}
+
+type memorySessionRepository struct {
+	mu       sync.RWMutex
+	sessions []models.Session
+}
+
+func (r *memorySessionRepository) Create(ctx context.Context, session models.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sessions = append(r.sessions, session)
+
+	return nil
+}
+
+func (r *memorySessionRepository) Get(ctx context.Context, sessionId string) (models.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, session := range r.sessions {
+		if session.Session_id == sessionId {
+			return session, nil
+		}
+	}
+
+	return models.Session{}, ErrNotFound
+}
+
+func (r *memorySessionRepository) ListActive(ctx context.Context, userId string) ([]models.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sessions := []models.Session{}
+
+	for _, session := range r.sessions {
+		if session.User_id == userId && session.Revoked_at == nil {
+			sessions = append(sessions, session)
+		}
+	}
+
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].Last_seen_at.After(sessions[j].Last_seen_at)
+	})
+
+	return sessions, nil
+}
+
+func (r *memorySessionRepository) RotateRefreshToken(ctx context.Context, sessionId string, currentHash string, newHash string, seenAt time.Time, expiresAt time.Time) error {
+	return r.modify(sessionId, func(session *models.Session) bool {
+		if session.Revoked_at != nil || session.Refresh_token_hash != currentHash {
+			return false
+		}
+
+		session.Refresh_token_hash = newHash
+		session.Last_seen_at = seenAt
+		session.Expires_at = expiresAt
+		return true
+	})
+}
+
+func (r *memorySessionRepository) Touch(ctx context.Context, sessionId string, seenAt time.Time, staleBefore time.Time) error {
+	err := r.modify(sessionId, func(session *models.Session) bool {
+		if session.Last_seen_at.Before(staleBefore) {
+			session.Last_seen_at = seenAt
+		}
+
+		return true
+	})
+
+	// Like the Mongo update, touching an unknown session is not an error.
+	if err == ErrNotFound {
+		return nil
+	}
+
+	return err
+}
+
+func (r *memorySessionRepository) Revoke(ctx context.Context, sessionId string, at time.Time) error {
+	return r.modify(sessionId, func(session *models.Session) bool {
+		if session.Revoked_at != nil {
+			return false
+		}
+
+		session.Revoked_at = &at
+		return true
+	})
+}
+
+// modify applies change to the stored session under the write lock. A
+// change that returns false counts as a miss.
+func (r *memorySessionRepository) modify(sessionId string, change func(*models.Session) bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.sessions {
+		if r.sessions[i].Session_id == sessionId {
+			if change(&r.sessions[i]) {
+				return nil
+			}
+
+			return ErrNotFound
+		}
+	}
+
+	return ErrNotFound
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"golang-restaurant-management/models"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type TableRepository interface {
+	List(ctx context.Context) ([]models.Table, error)
+	Get(ctx context.Context, tableId string) (models.Table, error)
+	Create(ctx context.Context, table models.Table) error
+	// Update replaces the stored table with the same table_id.
+	Update(ctx context.Context, table models.Table) error
+	Delete(ctx context.Context, tableId string) error
+}
+
+type mongoTableRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoTableRepository) List(ctx context.Context) ([]models.Table, error) {
+	res, err := r.collection.Find(ctx, bson.M{})
+
+	if err != nil {
+		return nil, err
+	}
+
+	tables := []models.Table{}
+	err = res.All(ctx, &tables)
+
+	return tables, err
+}
+
+func (r *mongoTableRepository) Get(ctx context.Context, tableId string) (table models.Table, err error) {
+	err = r.collection.FindOne(ctx, bson.M{"table_id": tableId}).Decode(&table)
+
+	return table, notFound(err)
+}
+
+func (r *mongoTableRepository) Create(ctx context.Context, table models.Table) error {
+	_, err := r.collection.InsertOne(ctx, table)
+
+	return err
+}
+
+func (r *mongoTableRepository) Update(ctx context.Context, table models.Table) error {
+	return matched(r.collection.ReplaceOne(ctx, bson.M{"table_id": table.Table_id}, table))
+}
+
+func (r *mongoTableRepository) Delete(ctx context.Context, tableId string) error {
+	return deleted(r.collection.DeleteOne(ctx, bson.M{"table_id": tableId}))
+}
+
+type memoryTableRepository struct {
+	mu     sync.RWMutex
+	tables []models.Table
+}
+
+func (r *memoryTableRepository) List(ctx context.Context) ([]models.Table, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]models.Table{}, r.tables...), nil
+}
+
+func (r *memoryTableRepository) Get(ctx context.Context, tableId string) (models.Table, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, table := range r.tables {
+		if table.Table_id == tableId {
+			return table, nil
+		}
+	}
+
+	return models.Table{}, ErrNotFound
+}
+
+func (r *memoryTableRepository) Create(ctx context.Context, table models.Table) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.tables = append(r.tables, table)
+
+	return nil
+}
+
+func (r *memoryTableRepository) Update(ctx context.Context, table models.Table) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.tables {
+		if r.tables[i].Table_id == table.Table_id {
+			r.tables[i] = table
+			return nil
+		}
+	}
+
+	return ErrNotFound
+}
+
+func (r *memoryTableRepository) Delete(ctx context.Context, tableId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.tables {
+		if r.tables[i].Table_id == tableId {
+			r.tables = append(r.tables[:i], r.tables[i+1:]...)
+			return nil
+		}
+	}
+
+	return ErrNotFound
+}
@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"context"
+	"golang-restaurant-management/models"
+	"slices"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TicketRepository stores the kitchen tickets of the stations.
+type TicketRepository interface {
+	// List returns the tickets of the station, or of every station when
+	// station is empty, whose status is one of statuses. The oldest ticket
+	// comes first.
+	List(ctx context.Context, station string, statuses []string) ([]models.Ticket, error)
+	Get(ctx context.Context, ticketId string) (models.Ticket, error)
+	CreateMany(ctx context.Context, tickets []models.Ticket) error
+	// Update replaces the stored ticket as long as it is still in status.
+	// Otherwise it returns ErrNotFound.
+	Update(ctx context.Context, ticket models.Ticket, status string) error
+	DeleteByOrder(ctx context.Context, orderId string) error
+	DeleteMany(ctx context.Context, ticketIds []string) error
+}
+
+type mongoTicketRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoTicketRepository) ensureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "station", Value: 1}, {Key: "status", Value: 1}, {Key: "created_at", Value: 1}}},
+		{Keys: bson.D{{Key: "order_id", Value: 1}}},
+	})
+
+	return err
+}
+
+func (r *mongoTicketRepository) List(ctx context.Context, station string, statuses []string) ([]models.Ticket, error) {
+	filter := bson.M{"status": bson.M{"$in": statuses}}
+
+	if station != "" {
+		filter["station"] = station
+	}
+
+	res, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+
+	if err != nil {
+		return nil, err
+	}
+
+	tickets := []models.Ticket{}
+	err = res.All(ctx, &tickets)
+
+	return tickets, err
+}
+
+func (r *mongoTicketRepository) Get(ctx context.Context, ticketId string) (ticket models.Ticket, err error) {
+	err = r.collection.FindOne(ctx, bson.M{"ticket_id": ticketId}).Decode(&ticket)
+
+	return ticket, notFound(err)
+}
+
+func (r *mongoTicketRepository) CreateMany(ctx context.Context, tickets []models.Ticket) error {
+	if len(tickets) == 0 {
+		return nil
+	}
+
+	docs := make([]interface{}, 0, len(tickets))
+
+	for _, ticket := range tickets {
+		docs = append(docs, ticket)
+	}
+
+	_, err := r.collection.InsertMany(ctx, docs)
+
+	return err
+}
+
+func (r *mongoTicketRepository) Update(ctx context.Context, ticket models.Ticket, status string) error {
+	return matched(r.collection.ReplaceOne(ctx, bson.M{"ticket_id": ticket.Ticket_id, "status": status}, ticket))
+}
+
+func (r *mongoTicketRepository) DeleteByOrder(ctx context.Context, orderId string) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"order_id": orderId})
+
+	return err
+}
+
+func (r *mongoTicketRepository) DeleteMany(ctx context.Context, ticketIds []string) error {
+	if len(ticketIds) == 0 {
+		return nil
+	}
+
+	_, err := r.collection.DeleteMany(ctx, bson.M{"ticket_id": bson.M{"$in": ticketIds}})
+
+	return err
+}
+
+type memoryTicketRepository struct {
+	mu      sync.RWMutex
+	tickets []models.Ticket
+}
+
+func (r *memoryTicketRepository) List(ctx context.Context, station string, statuses []string) ([]models.Ticket, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tickets := []models.Ticket{}
+
+	for _, ticket := range r.tickets {
+		if station != "" && ticket.Station != station {
+			continue
+		}
+
+		for _, status := range statuses {
+			if ticket.Status == status {
+				tickets = append(tickets, ticket)
+				break
+			}
+		}
+	}
+
+	return tickets, nil
+}
+
+func (r *memoryTicketRepository) Get(ctx context.Context, ticketId string) (models.Ticket, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, ticket := range r.tickets {
+		if ticket.Ticket_id == ticketId {
+			return ticket, nil
+		}
+	}
+
+	return models.Ticket{}, ErrNotFound
+}
+
+func (r *memoryTicketRepository) CreateMany(ctx context.Context, tickets []models.Ticket) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.tickets = append(r.tickets, tickets...)
+
+	return nil
+}
+
+func (r *memoryTicketRepository) Update(ctx context.Context, ticket models.Ticket, status string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.tickets {
+		if r.tickets[i].Ticket_id == ticket.Ticket_id && r.tickets[i].Status == status {
+			r.tickets[i] = ticket
+			return nil
+		}
+	}
+
+	return ErrNotFound
+}
+
+func (r *memoryTicketRepository) DeleteByOrder(ctx context.Context, orderId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	kept := r.tickets[:0]
+
+	for _, ticket := range r.tickets {
+		if ticket.Order_id != orderId {
+			kept = append(kept, ticket)
+		}
+	}
+
+	r.tickets = kept
+
+	return nil
+}
+
+func (r *memoryTicketRepository) DeleteMany(ctx context.Context, ticketIds []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	kept := r.tickets[:0]
+
+	for _, ticket := range r.tickets {
+		if !slices.Contains(ticketIds, ticket.Ticket_id) {
+			kept = append(kept, ticket)
+		}
+	}
+
+	r.tickets = kept
+
+	return nil
+}
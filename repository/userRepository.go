@@ -0,0 +1,320 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"golang-restaurant-management/models"
+	"reflect"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type UserRepository interface {
+	// List returns one page of users together with the total count.
+	List(ctx context.Context, page Page) ([]models.User, int64, error)
+	Get(ctx context.Context, userId string) (models.User, error)
+	GetByEmail(ctx context.Context, email string) (models.User, error)
+	// EmailOrPhoneTaken reports whether a user other than exceptUserId
+	// already uses the email or the phone. Empty values are not checked.
+	EmailOrPhoneTaken(ctx context.Context, email string, phone string, exceptUserId string) (bool, error)
+	Create(ctx context.Context, user models.User) error
+	// Update copies the named fields, given by their bson names, and
+	// updated_at from user to the stored user with the same user_id. Other
+	// fields are left alone, so concurrent changes to them are kept.
+	Update(ctx context.Context, user models.User, fields ...string) error
+	// UseMfaStep records the TOTP time step of a code that was just
+	// accepted. It returns ErrNotFound if the step is not newer than the last
+	// recorded one, i.e. the code is being replayed.
+	UseMfaStep(ctx context.Context, userId string, step int64) error
+	// UseRecoveryCode removes the hashed recovery code from the user. It
+	// returns ErrNotFound if the user does not have that code.
+	UseRecoveryCode(ctx context.Context, userId string, codeHash string) error
+}
+
+type mongoUserRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoUserRepository) ensureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+		{Keys: bson.D{{Key: "email", Value: 1}}},
+	})
+
+	return err
+}
+
+func (r *mongoUserRepository) List(ctx context.Context, page Page) ([]models.User, int64, error) {
+	total, err := r.collection.CountDocuments(ctx, bson.M{})
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetSkip(int64(page.Offset))
+
+	if page.Limit > 0 {
+		opts.SetLimit(int64(page.Limit))
+	}
+
+	res, err := r.collection.Find(ctx, bson.M{}, opts)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	users := []models.User{}
+	err = res.All(ctx, &users)
+
+	return users, total, err
+}
+
+func (r *mongoUserRepository) Get(ctx context.Context, userId string) (user models.User, err error) {
+	err = r.collection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user)
+
+	return user, notFound(err)
+}
+
+func (r *mongoUserRepository) GetByEmail(ctx context.Context, email string) (user models.User, err error) {
+	err = r.collection.FindOne(ctx, bson.M{"email": email}).Decode(&user)
+
+	return user, notFound(err)
+}
+
+func (r *mongoUserRepository) EmailOrPhoneTaken(ctx context.Context, email string, phone string, exceptUserId string) (bool, error) {
+	conditions := []bson.M{}
+
+	if email != "" {
+		conditions = append(conditions, bson.M{"email": email})
+	}
+
+	if phone != "" {
+		conditions = append(conditions, bson.M{"phone": phone})
+	}
+
+	if len(conditions) == 0 {
+		return false, nil
+	}
+
+	filter := bson.M{"$or": conditions}
+
+	if exceptUserId != "" {
+		filter["user_id"] = bson.M{"$ne": exceptUserId}
+	}
+
+	count, err := r.collection.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+
+	return count > 0, err
+}
+
+func (r *mongoUserRepository) Create(ctx context.Context, user models.User) error {
+	_, err := r.collection.InsertOne(ctx, user)
+
+	return err
+}
+
+func (r *mongoUserRepository) Update(ctx context.Context, user models.User, fields ...string) error {
+	indexes, err := userFieldIndexes(fields)
+
+	if err != nil {
+		return err
+	}
+
+	set := bson.D{}
+	value := reflect.ValueOf(user)
+
+	for _, index := range indexes {
+		set = append(set, bson.E{Key: bsonFieldName(value.Type().Field(index)), Value: value.Field(index).Interface()})
+	}
+
+	return matched(r.collection.UpdateOne(ctx, bson.M{"user_id": user.User_id}, bson.D{{Key: "$set", Value: set}}))
+}
+
+func (r *mongoUserRepository) UseMfaStep(ctx context.Context, userId string, step int64) error {
+	filter := bson.M{
+		"user_id": userId,
+		"$or": []bson.M{
+			{"mfa_last_step": bson.M{"$lt": step}},
+			{"mfa_last_step": bson.M{"$exists": false}},
+		},
+	}
+
+	return matched(r.collection.UpdateOne(ctx, filter, bson.D{
+		{Key: "$set", Value: bson.D{{Key: "mfa_last_step", Value: step}}},
+	}))
+}
+
+func (r *mongoUserRepository) UseRecoveryCode(ctx context.Context, userId string, codeHash string) error {
+	return matched(r.collection.UpdateOne(ctx,
+		bson.M{"user_id": userId, "mfa_recovery_codes": codeHash},
+		bson.D{{Key: "$pull", Value: bson.D{{Key: "mfa_recovery_codes", Value: codeHash}}}},
+	))
+}
+
+type memoryUserRepository struct {
+	mu    sync.RWMutex
+	users []models.User
+}
+
+func (r *memoryUserRepository) List(ctx context.Context, page Page) ([]models.User, int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users := append([]models.User{}, paginate(r.users, page)...)
+
+	return users, int64(len(r.users)), nil
+}
+
+func (r *memoryUserRepository) Get(ctx context.Context, userId string) (models.User, error) {
+	return r.find(func(user models.User) bool { return user.User_id == userId })
+}
+
+func (r *memoryUserRepository) GetByEmail(ctx context.Context, email string) (models.User, error) {
+	return r.find(func(user models.User) bool { return user.Email != nil && *user.Email == email })
+}
+
+func (r *memoryUserRepository) find(match func(models.User) bool) (models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if match(user) {
+			return user, nil
+		}
+	}
+
+	return models.User{}, ErrNotFound
+}
+
+func (r *memoryUserRepository) EmailOrPhoneTaken(ctx context.Context, email string, phone string, exceptUserId string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if exceptUserId != "" && user.User_id == exceptUserId {
+			continue
+		}
+
+		if email != "" && user.Email != nil && *user.Email == email {
+			return true, nil
+		}
+
+		if phone != "" && user.Phone != nil && *user.Phone == phone {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r *memoryUserRepository) Create(ctx context.Context, user models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.users = append(r.users, user)
+
+	return nil
+}
+
+func (r *memoryUserRepository) Update(ctx context.Context, user models.User, fields ...string) error {
+	indexes, err := userFieldIndexes(fields)
+
+	if err != nil {
+		return err
+	}
+
+	return r.modify(user.User_id, func(stored *models.User) bool {
+		target, source := reflect.ValueOf(stored).Elem(), reflect.ValueOf(user)
+
+		for _, index := range indexes {
+			target.Field(index).Set(source.Field(index))
+		}
+
+		return true
+	})
+}
+
+func (r *memoryUserRepository) UseMfaStep(ctx context.Context, userId string, step int64) error {
+	return r.modify(userId, func(stored *models.User) bool {
+		if stored.Mfa_last_step >= step {
+			return false
+		}
+
+		stored.Mfa_last_step = step
+		return true
+	})
+}
+
+func (r *memoryUserRepository) UseRecoveryCode(ctx context.Context, userId string, codeHash string) error {
+	return r.modify(userId, func(stored *models.User) bool {
+		for i, hash := range stored.Mfa_recovery_codes {
+			if hash == codeHash {
+				codes := append([]string{}, stored.Mfa_recovery_codes[:i]...)
+				stored.Mfa_recovery_codes = append(codes, stored.Mfa_recovery_codes[i+1:]...)
+				return true
+			}
+		}
+
+		return false
+	})
+}
+
+// modify applies change to the stored user under the write lock. A change
+// that returns false counts as a miss.
+func (r *memoryUserRepository) modify(userId string, change func(*models.User) bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.users {
+		if r.users[i].User_id == userId {
+			if change(&r.users[i]) {
+				return nil
+			}
+
+			return ErrNotFound
+		}
+	}
+
+	return ErrNotFound
+}
+
+// userFieldIndexes resolves bson field names of models.User to the indexes
+// of their struct fields. updated_at is always included.
+func userFieldIndexes(fields []string) ([]int, error) {
+	userType := reflect.TypeOf(models.User{})
+	names := append([]string{"updated_at"}, fields...)
+	indexes := make([]int, 0, len(names))
+
+	for _, name := range names {
+		index := -1
+
+		for i := 0; i < userType.NumField(); i++ {
+			if bsonFieldName(userType.Field(i)) == name {
+				index = i
+				break
+			}
+		}
+
+		if index < 0 {
+			return nil, fmt.Errorf("user has no field %q", name)
+		}
+
+		indexes = append(indexes, index)
+	}
+
+	return indexes, nil
+}
+
+// bsonFieldName is the name the driver stores a struct field under: its
+// bson tag or, without one, its lowercased name.
+func bsonFieldName(field reflect.StructField) string {
+	if name, _, _ := strings.Cut(field.Tag.Get("bson"), ","); name != "" {
+		return name
+	}
+
+	return strings.ToLower(field.Name)
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"golang-restaurant-management/models"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// UserTokenRepository stores the single-use tokens sent by email, e.g. for
+// password resets.
+type UserTokenRepository interface {
+	Create(ctx context.Context, userToken models.UserToken) error
+	// InvalidateUnused marks the user's unused tokens of the purpose as used.
+	InvalidateUnused(ctx context.Context, userId string, purpose string, at time.Time) error
+	// Consume marks an unused, unexpired token as used and returns it. Any
+	// other token yields ErrNotFound.
+	Consume(ctx context.Context, tokenHash string, purpose string, at time.Time) (models.UserToken, error)
+}
+
+type mongoUserTokenRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoUserTokenRepository) ensureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+		{
+			Keys:    bson.D{{Key: "token_hash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	})
+
+	return err
+}
+
+func (r *mongoUserTokenRepository) Create(ctx context.Context, userToken models.UserToken) error {
+	_, err := r.collection.InsertOne(ctx, userToken)
+
+	return err
+}
+
+func (r *mongoUserTokenRepository) InvalidateUnused(ctx context.Context, userId string, purpose string, at time.Time) error {
+	_, err := r.collection.UpdateMany(ctx,
+		bson.M{"user_id": userId, "purpose": purpose, "used_at": nil},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "used_at", Value: at}}}},
+	)
+
+	return err
+}
+
+func (r *mongoUserTokenRepository) Consume(ctx context.Context, tokenHash string, purpose string, at time.Time) (userToken models.UserToken, err error) {
+	filter := bson.M{
+		"token_hash": tokenHash,
+		"purpose":    purpose,
+		"used_at":    nil,
+		"expires_at": bson.M{"$gt": at},
+	}
+
+	err = r.collection.FindOneAndUpdate(ctx, filter,
+		bson.D{{Key: "$set", Value: bson.D{{Key: "used_at", Value: at}}}},
+	).Decode(&userToken)
+
+	return userToken, notFound(err)
+}
+
+type memoryUserTokenRepository struct {
+	mu     sync.Mutex
+	tokens []models.UserToken
+}
+
+func (r *memoryUserTokenRepository) Create(ctx context.Context, userToken models.UserToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.tokens = append(r.tokens, userToken)
+
+	return nil
+}
+
+func (r *memoryUserTokenRepository) InvalidateUnused(ctx context.Context, userId string, purpose string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.tokens {
+		if r.tokens[i].User_id == userId && r.tokens[i].Purpose == purpose && r.tokens[i].Used_at == nil {
+			r.tokens[i].Used_at = &at
+		}
+	}
+
+	return nil
+}
+
+func (r *memoryUserTokenRepository) Consume(ctx context.Context, tokenHash string, purpose string, at time.Time) (models.UserToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.tokens {
+		token := &r.tokens[i]
+
+		if token.Token_hash == tokenHash && token.Purpose == purpose && token.Used_at == nil && token.Expires_at.After(at) {
+			consumed := *token
+			token.Used_at = &at
+			return consumed, nil
+		}
+	}
+
+	return models.UserToken{}, ErrNotFound
+}
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	controllers "golang-restaurant-management/controllers"
+	"golang-restaurant-management/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ApiKeyRoutes(incomingRoutes *gin.Engine, repos *repository.Repositories) {
+	incomingRoutes.GET("/apikeys", controllers.GetApiKeys(repos))
+	incomingRoutes.POST("/apikeys", controllers.CreateApiKey(repos))
+	incomingRoutes.DELETE("/apikeys/:api_key_id", controllers.RevokeApiKey(repos))
+}
@@ -0,0 +1,95 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"golang-restaurant-management/helpers"
+	"golang-restaurant-management/repository"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestApiKeyRoutes(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer(t)
+	table := s.seedTable(t, 1)
+
+	var apiKeyId, key string
+
+	s.run(t, []routeCase{
+		{name: "list needs apikey:manage", method: http.MethodGet, path: "/apikeys", as: helpers.RoleManager, status: http.StatusForbidden},
+		{name: "create needs apikey:manage", method: http.MethodPost, path: "/apikeys", as: helpers.RoleManager, body: map[string]string{"name": "Bar", "role": helpers.RoleWaiter}, status: http.StatusForbidden},
+		{name: "create without a name", method: http.MethodPost, path: "/apikeys", as: helpers.RoleAdmin, body: map[string]string{"role": helpers.RoleWaiter}, status: http.StatusBadRequest},
+		{name: "create an admin key", method: http.MethodPost, path: "/apikeys", as: helpers.RoleAdmin, body: map[string]string{"name": "Root", "role": helpers.RoleAdmin}, status: http.StatusBadRequest},
+		{name: "create for an unknown table", method: http.MethodPost, path: "/apikeys", as: helpers.RoleAdmin, body: map[string]string{"name": "Tablet", "role": helpers.RoleUser, "table_id": "missing"}, status: http.StatusBadRequest},
+		{name: "create", method: http.MethodPost, path: "/apikeys", as: helpers.RoleAdmin, body: map[string]string{"name": "Tablet", "role": helpers.RoleUser, "table_id": table.Table_id}, status: http.StatusOK, check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+			body := decode(t, rec)
+
+			key, _ = body["api_key"].(string)
+
+			details, _ := body["details"].(map[string]interface{})
+			apiKeyId, _ = details["api_key_id"].(string)
+		}},
+		{name: "list", method: http.MethodGet, path: "/apikeys", as: helpers.RoleAdmin, status: http.StatusOK, check: expectLength(1)},
+	})
+
+	if rec := s.request(http.MethodGet, "/orderItems", "", nil, helpers.ApiKeyHeader, key); rec.Code != http.StatusOK {
+		t.Errorf("new key = %d, want 200", rec.Code)
+	}
+
+	s.run(t, []routeCase{
+		{name: "revoke unknown", method: http.MethodDelete, path: "/apikeys/missing", as: helpers.RoleAdmin, status: http.StatusNotFound},
+		{name: "revoke", method: http.MethodDelete, path: "/apikeys/" + apiKeyId, as: helpers.RoleAdmin, status: http.StatusOK},
+		{name: "revoke twice", method: http.MethodDelete, path: "/apikeys/" + apiKeyId, as: helpers.RoleAdmin, status: http.StatusNotFound},
+	})
+
+	if rec := s.request(http.MethodGet, "/orderItems", "", nil, helpers.ApiKeyHeader, key); rec.Code != http.StatusUnauthorized {
+		t.Errorf("revoked key = %d, want 401", rec.Code)
+	}
+}
+
+// failingApiKeyUse counts the attempts to record a key's use and fails them.
+type failingApiKeyUse struct {
+	repository.ApiKeyRepository
+	writes int
+}
+
+func (r *failingApiKeyUse) MarkUsed(ctx context.Context, apiKeyId string, at time.Time, staleBefore time.Time) error {
+	r.writes++
+	return errors.New("the database is read-only")
+}
+
+func TestApiKeyUseIsRecordedAtMostOncePerMinute(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer(t)
+	key := s.seedApiKey(t, helpers.RoleWaiter, nil)
+
+	apiKeys := &failingApiKeyUse{ApiKeyRepository: s.repos.ApiKeys}
+	s.repos.ApiKeys = apiKeys
+
+	if rec := s.request(http.MethodGet, "/orders", "", nil, helpers.ApiKeyHeader, key); rec.Code != http.StatusOK {
+		t.Errorf("request while the use cannot be recorded = %d, want 200", rec.Code)
+	}
+
+	s.repos.ApiKeys = apiKeys.ApiKeyRepository
+
+	for i := 0; i < 3; i++ {
+		if rec := s.request(http.MethodGet, "/orders", "", nil, helpers.ApiKeyHeader, key); rec.Code != http.StatusOK {
+			t.Fatalf("request %d = %d, want 200", i, rec.Code)
+		}
+	}
+
+	s.repos.ApiKeys = apiKeys
+
+	if rec := s.request(http.MethodGet, "/orders", "", nil, helpers.ApiKeyHeader, key); rec.Code != http.StatusOK {
+		t.Fatalf("request after the use was recorded = %d, want 200", rec.Code)
+	}
+
+	if apiKeys.writes != 1 {
+		t.Errorf("recorded the use %d times, want only while it was never recorded", apiKeys.writes)
+	}
+}
@@ -0,0 +1,12 @@
+package routes
+
+import (
+	controllers "golang-restaurant-management/controllers"
+	"golang-restaurant-management/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+func EventRoutes(incomingRoutes *gin.Engine, repos *repository.Repositories) {
+	incomingRoutes.GET("/events", controllers.StreamEvents(repos))
+}
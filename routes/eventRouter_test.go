@@ -0,0 +1,300 @@
+package routes
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"golang-restaurant-management/helpers"
+	"golang-restaurant-management/models"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// sseEvent is one server-sent event of the /events stream.
+type sseEvent struct {
+	name string
+	data map[string]interface{}
+}
+
+type eventStream struct {
+	events chan sseEvent
+}
+
+// openEventStream subscribes to the topics over a real HTTP connection and
+// waits until the server confirms the subscription. The stream is closed
+// when the test ends.
+func (s *testServer) openEventStream(t *testing.T, token string, topics string, headers ...string) *eventStream {
+	t.Helper()
+
+	srv := httptest.NewServer(s.router)
+	t.Cleanup(srv.Close)
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/events?topics="+url.QueryEscape(topics), nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { resp.Body.Close() })
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /events = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	stream := &eventStream{events: make(chan sseEvent, 64)}
+
+	go func() {
+		defer close(stream.events)
+
+		var event sseEvent
+
+		scanner := bufio.NewScanner(resp.Body)
+
+		for scanner.Scan() {
+			line := scanner.Text()
+
+			switch {
+			case strings.HasPrefix(line, "event:"):
+				event.name = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+			case strings.HasPrefix(line, "data:"):
+				json.Unmarshal([]byte(strings.TrimPrefix(line, "data:")), &event.data)
+			case line == "" && event.name != "":
+				stream.events <- event
+				event = sseEvent{}
+			}
+		}
+	}()
+
+	stream.waitFor(t, "ready", "", nil)
+
+	return stream
+}
+
+// waitFor returns the next event of the given type whose data carries
+// want under key, skipping everything else. Other tests share the event
+// bus, so unrelated events may show up on the broader topics.
+func (e *eventStream) waitFor(t *testing.T, name string, key string, want interface{}) sseEvent {
+	t.Helper()
+
+	timeout := time.After(2 * time.Second)
+
+	for {
+		select {
+		case event, ok := <-e.events:
+			if !ok {
+				t.Fatalf("stream closed while waiting for %s", name)
+			}
+
+			if event.name != name {
+				continue
+			}
+
+			if key == "" {
+				return event
+			}
+
+			data, _ := event.data["data"].(map[string]interface{})
+
+			if fmt.Sprint(data[key]) == fmt.Sprint(want) {
+				return event
+			}
+		case <-timeout:
+			t.Fatalf("no %s event with %s = %v", name, key, want)
+		}
+	}
+}
+
+// next returns the next event of the stream.
+func (e *eventStream) next(t *testing.T) sseEvent {
+	t.Helper()
+
+	select {
+	case event, ok := <-e.events:
+		if !ok {
+			t.Fatal("stream closed while waiting for an event")
+		}
+
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event")
+	}
+
+	return sseEvent{}
+}
+
+func TestEventStreamRejectsForbiddenTopics(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer(t)
+	own := s.seedTable(t, 1)
+	other := s.seedTable(t, 2)
+	key := s.seedApiKey(t, helpers.RoleUser, &own.Table_id)
+
+	s.run(t, []routeCase{
+		{name: "stream requires a login", method: http.MethodGet, path: "/events?topics=orders", status: http.StatusUnauthorized},
+		{name: "stream without topics", method: http.MethodGet, path: "/events", as: helpers.RoleWaiter, status: http.StatusBadRequest},
+		{name: "stream an unknown topic", method: http.MethodGet, path: "/events?topics=orders,weather", as: helpers.RoleWaiter, status: http.StatusBadRequest},
+		{name: "stream invoices without invoice:read", method: http.MethodGet, path: "/events?topics=" + helpers.TopicInvoices, as: helpers.RoleChef, status: http.StatusForbidden},
+		{name: "stream tables without table:read", method: http.MethodGet, path: "/events?topics=" + helpers.TopicTables, as: helpers.RoleChef, status: http.StatusForbidden},
+	})
+
+	for _, topic := range []string{helpers.TopicOrders, helpers.TableTopic(other.Table_id)} {
+		if rec := s.request(http.MethodGet, "/events?topics="+topic, "", nil, helpers.ApiKeyHeader, key); rec.Code != http.StatusForbidden {
+			t.Errorf("table-scoped key following %s = %d, want %d", topic, rec.Code, http.StatusForbidden)
+		}
+	}
+}
+
+func TestEventStreamFollowsOrdersAndTickets(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer(t)
+	table := s.seedTable(t, 3)
+	menu := s.seedMenu(t)
+	tart := s.seedFood(t, menu.Menu_id, "Tart", 6)
+	waiter := s.as(t, helpers.RoleWaiter)
+	chef := s.as(t, helpers.RoleChef)
+
+	s.run(t, []routeCase{
+		{name: "route desserts to pastry", method: http.MethodPatch, path: "/menus/" + menu.Menu_id, as: helpers.RoleManager, body: map[string]string{"station": "pastry"}, status: http.StatusOK},
+	})
+
+	floor := s.openEventStream(t, waiter.token, helpers.TableTopic(table.Table_id))
+	pastry := s.openEventStream(t, chef.token, helpers.StationTopic("pastry"))
+
+	rec := s.request(http.MethodPost, "/orderItems", waiter.token, map[string]interface{}{
+		"table_id":    table.Table_id,
+		"order_items": []map[string]interface{}{{"food_id": tart.Food_id, "quantity": 2}},
+	})
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /orderItems = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	items := decodeList(t, rec)
+	orderId, _ := items[0]["order_id"].(string)
+
+	floor.waitFor(t, helpers.EventOrderCreated, "order_id", orderId)
+	floor.waitFor(t, helpers.EventOrderItemCreated, "order_item_id", items[0]["order_item_id"])
+
+	s.run(t, []routeCase{
+		{name: "change the item", method: http.MethodPatch, path: "/orderItems/" + items[0]["order_item_id"].(string), token: waiter.token, body: map[string]interface{}{"quantity": 3}, status: http.StatusOK},
+		{name: "send to the kitchen", method: http.MethodPatch, path: "/orders/" + orderId, token: waiter.token, body: map[string]string{"status": models.OrderStatusSentToKitchen}, status: http.StatusOK},
+	})
+
+	floor.waitFor(t, helpers.EventOrderItemUpdated, "quantity", 3)
+	floor.waitFor(t, helpers.EventOrderUpdated, "status", models.OrderStatusSentToKitchen)
+	ticket := pastry.waitFor(t, helpers.EventTicketCreated, "order_id", orderId)
+	ticketId, _ := ticket.data["data"].(map[string]interface{})["ticket_id"].(string)
+
+	s.run(t, []routeCase{
+		{name: "bump", method: http.MethodPost, path: "/kitchen/tickets/" + ticketId + "/bump", token: chef.token, status: http.StatusOK},
+	})
+
+	pastry.waitFor(t, helpers.EventTicketUpdated, "status", models.PrepStatusReady)
+	floor.waitFor(t, helpers.EventTicketUpdated, "status", models.PrepStatusReady)
+}
+
+func TestEventStreamFollowsTablesAndInvoices(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer(t)
+	table := s.seedTable(t, 4)
+	menu := s.seedMenu(t)
+	order, _ := s.seedOrder(t, table.Table_id)
+	key := s.seedApiKey(t, helpers.RoleUser, &table.Table_id)
+
+	device := s.openEventStream(t, "", helpers.TableTopic(table.Table_id), helpers.ApiKeyHeader, key)
+	kitchen := s.openEventStream(t, s.as(t, helpers.RoleChef).token, helpers.OrderTopic(order.Order_id)+","+helpers.TableTopic(table.Table_id))
+	backOffice := s.openEventStream(t, s.as(t, helpers.RoleManager).token, helpers.TopicTables+","+helpers.TopicInvoices)
+
+	var invoiceId string
+
+	s.run(t, []routeCase{
+		{name: "update the table", method: http.MethodPatch, path: "/tables/" + table.Table_id, as: helpers.RoleHost, body: map[string]int{"number_of_guests": 6}, status: http.StatusOK},
+		{name: "create an invoice", method: http.MethodPost, path: "/invoices", as: helpers.RoleCashier, body: map[string]string{"order_id": order.Order_id, "payment_method": "CARD"}, status: http.StatusOK, check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+			invoiceId, _ = decode(t, rec)["invoice_id"].(string)
+		}},
+	})
+
+	backOffice.waitFor(t, helpers.EventTableUpdated, "table_id", table.Table_id)
+	backOffice.waitFor(t, helpers.EventInvoiceCreated, "invoice_id", invoiceId)
+
+	s.run(t, []routeCase{
+		{name: "add an item", method: http.MethodPost, path: "/orders/" + order.Order_id + "/items", as: helpers.RoleWaiter, body: map[string]interface{}{"order_items": []map[string]interface{}{{"food_id": s.seedFood(t, menu.Menu_id, "Tea", 3).Food_id, "quantity": 1}}}, status: http.StatusOK},
+	})
+
+	// Neither the table update nor the invoice reach followers of the table
+	// and the order that lack table:read and invoice:read.
+	for name, stream := range map[string]*eventStream{"device": device, "kitchen": kitchen} {
+		if event := stream.next(t); event.name != helpers.EventOrderItemCreated {
+			t.Errorf("%s got %s first, want %s", name, event.name, helpers.EventOrderItemCreated)
+		}
+	}
+
+	s.run(t, []routeCase{
+		{name: "delete the invoice", method: http.MethodDelete, path: "/invoices/" + invoiceId, as: helpers.RoleManager, status: http.StatusOK},
+	})
+
+	backOffice.waitFor(t, helpers.EventInvoiceDeleted, "invoice_id", invoiceId)
+}
+
+func TestEventStreamEndsWhenTheTokenExpires(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer(t)
+	waiter := s.seedUser(t, helpers.RoleWaiter)
+	now := time.Now()
+
+	token, err := helpers.SignClaims(&helpers.SignedDetails{
+		Email:      *waiter.Email,
+		First_name: *waiter.First_name,
+		Last_name:  *waiter.Last_name,
+		Uid:        waiter.User_id,
+		User_type:  *waiter.User_type,
+		Token_type: helpers.AccessTokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "short-lived",
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(2 * time.Second)),
+		},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream := s.openEventStream(t, token, helpers.TopicOrders)
+	timeout := time.After(5 * time.Second)
+
+	for {
+		select {
+		case _, ok := <-stream.events:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("the stream outlived its token")
+		}
+	}
+}
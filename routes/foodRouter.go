@@ -2,14 +2,15 @@ package routes
 
 import (
 	controllers "golang-restaurant-management/controllers"
+	"golang-restaurant-management/repository"
 
 	"github.com/gin-gonic/gin"
 )
 
-func FoodRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.GET("/foods", controllers.GetFoods())
-	incomingRoutes.GET("/foods/:food_id", controllers.GetFood())
-	incomingRoutes.POST("/foods", controllers.CreateFood())
-	incomingRoutes.PATCH("/foods/:food_id", controllers.UpdateFood())
-	incomingRoutes.DELETE("/foods/:food_id", controllers.DeleteFood())
+func FoodRoutes(incomingRoutes *gin.Engine, repos *repository.Repositories) {
+	incomingRoutes.GET("/foods", controllers.GetFoods(repos))
+	incomingRoutes.GET("/foods/:food_id", controllers.GetFood(repos))
+	incomingRoutes.POST("/foods", controllers.CreateFood(repos))
+	incomingRoutes.PATCH("/foods/:food_id", controllers.UpdateFood(repos))
+	incomingRoutes.DELETE("/foods/:food_id", controllers.DeleteFood(repos))
 }
@@ -0,0 +1,56 @@
+package routes
+
+import (
+	"golang-restaurant-management/helpers"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFoodRoutes(t *testing.T) {
+	s := newTestServer(t)
+	menu := s.seedMenu(t)
+	food := s.seedFood(t, menu.Menu_id, "Steak", 24.5)
+	s.seedFood(t, menu.Menu_id, "Salad", 9)
+
+	newFood := map[string]interface{}{"name": "Soup", "price": 6.499, "food_image": "soup.png", "menu_id": menu.Menu_id}
+
+	s.run(t, []routeCase{
+		{name: "list requires a login", method: http.MethodGet, path: "/foods", status: http.StatusUnauthorized},
+		{name: "list", method: http.MethodGet, path: "/foods", as: helpers.RoleUser, status: http.StatusOK, check: expectField("total_count", 2)},
+		{name: "list a page", method: http.MethodGet, path: "/foods?recordPerPage=1&page=2", as: helpers.RoleUser, status: http.StatusOK, check: expectPageSize("food_items", 1)},
+		{name: "get", method: http.MethodGet, path: "/foods/" + food.Food_id, as: helpers.RoleUser, status: http.StatusOK, check: expectField("name", "Steak")},
+		{name: "get unknown", method: http.MethodGet, path: "/foods/missing", as: helpers.RoleUser, status: http.StatusNotFound},
+		{name: "create needs food:write", method: http.MethodPost, path: "/foods", as: helpers.RoleWaiter, body: newFood, status: http.StatusForbidden},
+		{name: "create without name", method: http.MethodPost, path: "/foods", as: helpers.RoleManager, body: map[string]interface{}{"price": 5, "food_image": "x.png", "menu_id": menu.Menu_id}, status: http.StatusBadRequest},
+		{name: "create on an unknown menu", method: http.MethodPost, path: "/foods", as: helpers.RoleManager, body: map[string]interface{}{"name": "Soup", "price": 5, "food_image": "x.png", "menu_id": "missing"}, status: http.StatusBadRequest},
+		{name: "create rounds the price", method: http.MethodPost, path: "/foods", as: helpers.RoleManager, body: newFood, status: http.StatusOK, check: expectField("price", 6.5)},
+		{name: "update", method: http.MethodPatch, path: "/foods/" + food.Food_id, as: helpers.RoleManager, body: map[string]interface{}{"price": 26}, status: http.StatusOK, check: expectField("price", 26)},
+		{name: "create with duplicate variants", method: http.MethodPost, path: "/foods", as: helpers.RoleManager, body: map[string]interface{}{"name": "Pizza", "price": 8, "food_image": "x.png", "menu_id": menu.Menu_id, "variants": []map[string]interface{}{{"name": "Small", "price": 8}, {"name": "Small", "price": 9}}}, status: http.StatusBadRequest},
+		{name: "create with variants", method: http.MethodPost, path: "/foods", as: helpers.RoleManager, body: map[string]interface{}{"name": "Pizza", "price": 8, "food_image": "x.png", "menu_id": menu.Menu_id, "variants": []map[string]interface{}{{"name": "Small", "price": 8}, {"name": "Large", "price": 12.499}}}, status: http.StatusOK, check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+			variants, _ := decode(t, rec)["variants"].([]interface{})
+
+			if len(variants) != 2 || variants[1].(map[string]interface{})["price"] != 12.5 {
+				t.Errorf("unexpected variants: %v", variants)
+			}
+		}},
+		{name: "update a variant without a price", method: http.MethodPatch, path: "/foods/" + food.Food_id, as: helpers.RoleManager, body: map[string]interface{}{"variants": []map[string]interface{}{{"name": "Half"}}}, status: http.StatusBadRequest},
+		{name: "update the variants", method: http.MethodPatch, path: "/foods/" + food.Food_id, as: helpers.RoleManager, body: map[string]interface{}{"variants": []map[string]interface{}{{"name": "Half", "price": 14}}}, status: http.StatusOK, check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+			if variants, _ := decode(t, rec)["variants"].([]interface{}); len(variants) != 1 {
+				t.Errorf("unexpected variants: %v", variants)
+			}
+		}},
+		{name: "update modifier groups with max below min", method: http.MethodPatch, path: "/foods/" + food.Food_id, as: helpers.RoleManager, body: map[string]interface{}{"modifier_groups": []map[string]interface{}{{"name": "Sides", "min_selections": 2, "max_selections": 1, "options": []map[string]interface{}{{"name": "Fries"}, {"name": "Salad"}}}}}, status: http.StatusBadRequest},
+		{name: "update modifier groups without options", method: http.MethodPatch, path: "/foods/" + food.Food_id, as: helpers.RoleManager, body: map[string]interface{}{"modifier_groups": []map[string]interface{}{{"name": "Sides"}}}, status: http.StatusBadRequest},
+		{name: "update the modifier groups", method: http.MethodPatch, path: "/foods/" + food.Food_id, as: helpers.RoleManager, body: map[string]interface{}{"modifier_groups": []map[string]interface{}{{"name": "Sides", "required": true, "options": []map[string]interface{}{{"name": "Fries", "price_delta": 3}, {"name": "Salad"}}}}}, status: http.StatusOK, check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+			if groups, _ := decode(t, rec)["modifier_groups"].([]interface{}); len(groups) != 1 {
+				t.Errorf("unexpected modifier groups: %v", groups)
+			}
+		}},
+		{name: "update onto an unknown menu", method: http.MethodPatch, path: "/foods/" + food.Food_id, as: helpers.RoleManager, body: map[string]interface{}{"menu_id": "missing"}, status: http.StatusBadRequest},
+		{name: "update unknown", method: http.MethodPatch, path: "/foods/missing", as: helpers.RoleManager, body: map[string]interface{}{"price": 26}, status: http.StatusNotFound},
+		{name: "delete needs food:write", method: http.MethodDelete, path: "/foods/" + food.Food_id, as: helpers.RoleCashier, status: http.StatusForbidden},
+		{name: "delete", method: http.MethodDelete, path: "/foods/" + food.Food_id, as: helpers.RoleManager, status: http.StatusOK},
+		{name: "delete again", method: http.MethodDelete, path: "/foods/" + food.Food_id, as: helpers.RoleManager, status: http.StatusNotFound},
+	})
+}
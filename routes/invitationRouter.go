@@ -0,0 +1,15 @@
+package routes
+
+import (
+	controllers "golang-restaurant-management/controllers"
+	"golang-restaurant-management/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+func InvitationRoutes(incomingRoutes *gin.Engine, repos *repository.Repositories) {
+	incomingRoutes.GET("/invitations", controllers.GetInvitations(repos))
+	incomingRoutes.POST("/invitations", controllers.CreateInvitation(repos))
+	incomingRoutes.DELETE("/invitations/:invitation_id", controllers.RevokeInvitation(repos))
+	incomingRoutes.POST("/invitations/accept", controllers.AcceptInvitation(repos))
+}
@@ -0,0 +1,63 @@
+package routes
+
+import (
+	"golang-restaurant-management/helpers"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestInvitationRoutes(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer(t)
+	waiter := s.as(t, helpers.RoleWaiter)
+
+	var revokedId string
+
+	s.run(t, []routeCase{
+		{name: "list needs user:invite", method: http.MethodGet, path: "/invitations", as: helpers.RoleWaiter, status: http.StatusForbidden},
+		{name: "invite with an unknown role", method: http.MethodPost, path: "/invitations", as: helpers.RoleManager, body: map[string]string{"email": "chef@example.com", "user_type": "OWNER"}, status: http.StatusBadRequest},
+		{name: "manager invites an admin", method: http.MethodPost, path: "/invitations", as: helpers.RoleManager, body: map[string]string{"email": "boss@example.com", "user_type": helpers.RoleAdmin}, status: http.StatusForbidden},
+		{name: "invite an existing user", method: http.MethodPost, path: "/invitations", as: helpers.RoleManager, body: map[string]string{"email": *waiter.Email, "user_type": helpers.RoleChef}, status: http.StatusBadRequest},
+		{name: "invite", method: http.MethodPost, path: "/invitations", as: helpers.RoleManager, body: map[string]string{"email": "chef@example.com", "user_type": helpers.RoleChef}, status: http.StatusOK, check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+			revokedId, _ = decode(t, rec)["invitation_id"].(string)
+		}},
+	})
+
+	superseded := testMailer.tokenSentTo(t, "chef@example.com")
+
+	s.run(t, []routeCase{
+		{name: "invite again", method: http.MethodPost, path: "/invitations", as: helpers.RoleManager, body: map[string]string{"email": "chef@example.com", "user_type": helpers.RoleChef}, status: http.StatusOK},
+		{name: "only the latest invitation is pending", method: http.MethodGet, path: "/invitations", as: helpers.RoleManager, status: http.StatusOK, check: expectLength(1)},
+		{name: "revoke a superseded invitation", method: http.MethodDelete, path: "/invitations/" + revokedId, as: helpers.RoleManager, status: http.StatusNotFound},
+	})
+
+	token := testMailer.tokenSentTo(t, "chef@example.com")
+
+	accept := func(token string, phone string) map[string]string {
+		return map[string]string{"token": token, "first_name": "Julia", "last_name": "Child", "password": "password", "phone": phone}
+	}
+
+	s.run(t, []routeCase{
+		{name: "accept without a name", method: http.MethodPost, path: "/invitations/accept", body: map[string]string{"token": token, "password": "password", "phone": "555-8000"}, status: http.StatusBadRequest},
+		{name: "accept a superseded invitation", method: http.MethodPost, path: "/invitations/accept", body: accept(superseded, "555-8000"), status: http.StatusBadRequest},
+		{name: "accept with a taken phone", method: http.MethodPost, path: "/invitations/accept", body: accept(token, *waiter.Phone), status: http.StatusBadRequest},
+		{name: "accept", method: http.MethodPost, path: "/invitations/accept", body: accept(token, "555-8000"), status: http.StatusOK},
+		{name: "accept twice", method: http.MethodPost, path: "/invitations/accept", body: accept(token, "555-8001"), status: http.StatusBadRequest},
+		{name: "login as the invited user", method: http.MethodPost, path: "/users/login", body: map[string]string{"email": "chef@example.com", "Password": "password"}, status: http.StatusOK, check: expectField("user_type", helpers.RoleChef)},
+	})
+
+	var pendingId string
+
+	s.run(t, []routeCase{
+		{name: "invite a host", method: http.MethodPost, path: "/invitations", as: helpers.RoleAdmin, body: map[string]string{"email": "host@example.com", "user_type": helpers.RoleHost}, status: http.StatusOK, check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+			pendingId, _ = decode(t, rec)["invitation_id"].(string)
+		}},
+	})
+
+	s.run(t, []routeCase{
+		{name: "revoke", method: http.MethodDelete, path: "/invitations/" + pendingId, as: helpers.RoleManager, status: http.StatusOK},
+		{name: "accept a revoked invitation", method: http.MethodPost, path: "/invitations/accept", body: accept(testMailer.tokenSentTo(t, "host@example.com"), "555-8002"), status: http.StatusBadRequest},
+	})
+}
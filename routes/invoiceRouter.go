@@ -2,14 +2,15 @@ package routes
 
 import (
 	controllers "golang-restaurant-management/controllers"
+	"golang-restaurant-management/repository"
 
 	"github.com/gin-gonic/gin"
 )
 
-func InvoiceRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.GET("/invoices", controllers.GetInvoices())
-	incomingRoutes.GET("/invoices/:invoice_id", controllers.GetInvoice())
-	incomingRoutes.POST("/invoices", controllers.CreateInvoice())
-	incomingRoutes.PATCH("/invoices/:invoice_id", controllers.UpdateInvoice())
-	incomingRoutes.DELETE("/invoices/:invoice_id", controllers.DeleteInvoice())
+func InvoiceRoutes(incomingRoutes *gin.Engine, repos *repository.Repositories) {
+	incomingRoutes.GET("/invoices", controllers.GetInvoices(repos))
+	incomingRoutes.GET("/invoices/:invoice_id", controllers.GetInvoice(repos))
+	incomingRoutes.POST("/invoices", controllers.CreateInvoice(repos))
+	incomingRoutes.PATCH("/invoices/:invoice_id", controllers.UpdateInvoice(repos))
+	incomingRoutes.DELETE("/invoices/:invoice_id", controllers.DeleteInvoice(repos))
 }
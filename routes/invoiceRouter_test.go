@@ -0,0 +1,43 @@
+package routes
+
+import (
+	"golang-restaurant-management/helpers"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestInvoiceRoutes(t *testing.T) {
+	s := newTestServer(t)
+	menu := s.seedMenu(t)
+	steak := s.seedFood(t, menu.Menu_id, "Steak", 24.5)
+	table := s.seedTable(t, 3)
+	order, _ := s.seedOrder(t, table.Table_id, steak, steak)
+	other, _ := s.seedOrder(t, table.Table_id, steak)
+	invoice := s.seedInvoice(t, order.Order_id)
+
+	s.run(t, []routeCase{
+		{name: "list requires a login", method: http.MethodGet, path: "/invoices", status: http.StatusUnauthorized},
+		{name: "list needs invoice:read", method: http.MethodGet, path: "/invoices", as: helpers.RoleChef, status: http.StatusForbidden},
+		{name: "list", method: http.MethodGet, path: "/invoices", as: helpers.RoleCashier, status: http.StatusOK, check: expectLength(1)},
+		{name: "get totals the order", method: http.MethodGet, path: "/invoices/" + invoice.Invoice_id, as: helpers.RoleCashier, status: http.StatusOK, check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+			body := decode(t, rec)
+
+			if body["Payment_due"] != 49.0 || body["Table_number"] != 3.0 || body["Payment_method"] != "null" {
+				t.Errorf("unexpected invoice view: %v", body)
+			}
+		}},
+		{name: "get unknown", method: http.MethodGet, path: "/invoices/missing", as: helpers.RoleCashier, status: http.StatusNotFound},
+		{name: "create without an order", method: http.MethodPost, path: "/invoices", as: helpers.RoleWaiter, body: map[string]string{"payment_method": "CARD"}, status: http.StatusBadRequest},
+		{name: "create for an unknown order", method: http.MethodPost, path: "/invoices", as: helpers.RoleWaiter, body: map[string]string{"order_id": "missing"}, status: http.StatusBadRequest},
+		{name: "create with an unknown payment method", method: http.MethodPost, path: "/invoices", as: helpers.RoleWaiter, body: map[string]string{"order_id": other.Order_id, "payment_method": "CHEQUE"}, status: http.StatusBadRequest},
+		{name: "create as paid needs invoice:pay", method: http.MethodPost, path: "/invoices", as: helpers.RoleWaiter, body: map[string]string{"order_id": other.Order_id, "payment_status": "PAID"}, status: http.StatusForbidden},
+		{name: "create", method: http.MethodPost, path: "/invoices", as: helpers.RoleWaiter, body: map[string]string{"order_id": other.Order_id, "payment_method": "CARD"}, status: http.StatusOK, check: expectField("payment_status", "PENDING")},
+		{name: "mark paid needs invoice:pay", method: http.MethodPatch, path: "/invoices/" + invoice.Invoice_id, as: helpers.RoleWaiter, body: map[string]string{"payment_status": "PAID"}, status: http.StatusForbidden},
+		{name: "mark paid", method: http.MethodPatch, path: "/invoices/" + invoice.Invoice_id, as: helpers.RoleCashier, body: map[string]string{"payment_status": "PAID", "payment_method": "CASH"}, status: http.StatusOK, check: expectField("payment_status", "PAID")},
+		{name: "update unknown", method: http.MethodPatch, path: "/invoices/missing", as: helpers.RoleCashier, body: map[string]string{"payment_method": "CASH"}, status: http.StatusNotFound},
+		{name: "delete needs invoice:delete", method: http.MethodDelete, path: "/invoices/" + invoice.Invoice_id, as: helpers.RoleCashier, status: http.StatusForbidden},
+		{name: "delete", method: http.MethodDelete, path: "/invoices/" + invoice.Invoice_id, as: helpers.RoleManager, status: http.StatusOK},
+		{name: "delete again", method: http.MethodDelete, path: "/invoices/" + invoice.Invoice_id, as: helpers.RoleManager, status: http.StatusNotFound},
+	})
+}
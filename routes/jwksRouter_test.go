@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestJwks(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer(t)
+
+	s.run(t, []routeCase{
+		{name: "public key set", method: http.MethodGet, path: "/.well-known/jwks.json", status: http.StatusOK, check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+			if _, ok := decode(t, rec)["keys"]; !ok {
+				t.Errorf("response has no keys: %s", rec.Body.String())
+			}
+		}},
+	})
+}
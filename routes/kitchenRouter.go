@@ -0,0 +1,16 @@
+package routes
+
+import (
+	controllers "golang-restaurant-management/controllers"
+	"golang-restaurant-management/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+func KitchenRoutes(incomingRoutes *gin.Engine, repos *repository.Repositories) {
+	incomingRoutes.GET("/kitchen/tickets", controllers.GetTickets(repos))
+	incomingRoutes.GET("/kitchen/tickets/:ticket_id", controllers.GetTicket(repos))
+	incomingRoutes.POST("/kitchen/tickets/:ticket_id/start", controllers.StartTicket(repos))
+	incomingRoutes.POST("/kitchen/tickets/:ticket_id/bump", controllers.BumpTicket(repos))
+	incomingRoutes.POST("/kitchen/tickets/:ticket_id/recall", controllers.RecallTicket(repos))
+}
@@ -0,0 +1,312 @@
+package routes
+
+import (
+	"context"
+	"golang-restaurant-management/helpers"
+	"golang-restaurant-management/models"
+	"golang-restaurant-management/repository"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestKitchenRoutes(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer(t)
+	table := s.seedTable(t, 7)
+	grillMenu := s.seedMenu(t)
+	burger := s.seedFood(t, grillMenu.Menu_id, "Burger", 12)
+	beer := s.seedFood(t, grillMenu.Menu_id, "Beer", 5)
+	soup := s.seedFood(t, s.seedMenu(t).Menu_id, "Soup", 4.5)
+	order, items := s.seedOrder(t, table.Table_id, burger, beer, soup)
+	chef := s.as(t, helpers.RoleChef)
+
+	var grillTicket, barTicket string
+
+	s.run(t, []routeCase{
+		{name: "route a menu to the grill", method: http.MethodPatch, path: "/menus/" + grillMenu.Menu_id, as: helpers.RoleManager, body: map[string]string{"station": "grill"}, status: http.StatusOK, check: expectField("station", "grill")},
+		{name: "route a food to the bar", method: http.MethodPatch, path: "/foods/" + beer.Food_id, as: helpers.RoleManager, body: map[string]string{"station": "bar"}, status: http.StatusOK, check: expectField("station", "bar")},
+		{name: "route a food to an empty station", method: http.MethodPatch, path: "/foods/" + beer.Food_id, as: helpers.RoleManager, body: map[string]string{"station": ""}, status: http.StatusBadRequest},
+		{name: "nothing to cook yet", method: http.MethodGet, path: "/kitchen/tickets", token: chef.token, status: http.StatusOK, check: expectLength(0)},
+		{name: "send to the kitchen", method: http.MethodPatch, path: "/orders/" + order.Order_id, as: helpers.RoleWaiter, body: map[string]string{"status": models.OrderStatusSentToKitchen}, status: http.StatusOK},
+		{name: "list requires a login", method: http.MethodGet, path: "/kitchen/tickets", status: http.StatusUnauthorized},
+		{name: "one ticket per station", method: http.MethodGet, path: "/kitchen/tickets", token: chef.token, status: http.StatusOK, check: expectLength(3)},
+		{name: "list by an unknown status", method: http.MethodGet, path: "/kitchen/tickets?status=EATEN", token: chef.token, status: http.StatusBadRequest},
+		{name: "list the grill", method: http.MethodGet, path: "/kitchen/tickets?station=grill", token: chef.token, status: http.StatusOK, check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+			tickets := decodeList(t, rec)
+
+			if len(tickets) != 1 {
+				t.Fatalf("got %d grill tickets, want 1", len(tickets))
+			}
+
+			grillTicket, _ = tickets[0]["ticket_id"].(string)
+			lines, _ := tickets[0]["items"].([]interface{})
+
+			if tickets[0]["table_number"] != float64(7) || tickets[0]["status"] != models.PrepStatusQueued || len(lines) != 1 {
+				t.Errorf("unexpected grill ticket: %v", tickets[0])
+			}
+		}},
+		{name: "list the bar", method: http.MethodGet, path: "/kitchen/tickets?station=bar", token: chef.token, status: http.StatusOK, check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+			tickets := decodeList(t, rec)
+
+			if len(tickets) != 1 {
+				t.Fatalf("got %d bar tickets, want 1", len(tickets))
+			}
+
+			barTicket, _ = tickets[0]["ticket_id"].(string)
+		}},
+		{name: "unassigned foods go to the kitchen", method: http.MethodGet, path: "/kitchen/tickets?station=" + helpers.DefaultStation, token: chef.token, status: http.StatusOK, check: expectLength(1)},
+		{name: "items are queued", method: http.MethodGet, path: "/orderItems/" + items[0].Order_item_id, token: chef.token, status: http.StatusOK, check: expectField("prep_status", models.PrepStatusQueued)},
+	})
+
+	s.run(t, []routeCase{
+		{name: "get", method: http.MethodGet, path: "/kitchen/tickets/" + grillTicket, token: chef.token, status: http.StatusOK, check: expectField("station", "grill")},
+		{name: "get unknown", method: http.MethodGet, path: "/kitchen/tickets/missing", token: chef.token, status: http.StatusNotFound},
+		{name: "start needs kitchen:bump", method: http.MethodPost, path: "/kitchen/tickets/" + grillTicket + "/start", as: helpers.RoleWaiter, status: http.StatusForbidden},
+		{name: "start unknown", method: http.MethodPost, path: "/kitchen/tickets/missing/start", token: chef.token, status: http.StatusNotFound},
+		{name: "recall a queued ticket", method: http.MethodPost, path: "/kitchen/tickets/" + grillTicket + "/recall", token: chef.token, status: http.StatusConflict},
+		{name: "start", method: http.MethodPost, path: "/kitchen/tickets/" + grillTicket + "/start", token: chef.token, status: http.StatusOK, check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+			body := decode(t, rec)
+
+			if body["status"] != models.PrepStatusCooking || body["started_at"] == nil {
+				t.Errorf("ticket was not started: %v", body)
+			}
+		}},
+		{name: "start twice", method: http.MethodPost, path: "/kitchen/tickets/" + grillTicket + "/start", token: chef.token, status: http.StatusConflict},
+		{name: "items are cooking", method: http.MethodGet, path: "/orderItems/" + items[0].Order_item_id, token: chef.token, status: http.StatusOK, check: expectField("prep_status", models.PrepStatusCooking)},
+		{name: "bump", method: http.MethodPost, path: "/kitchen/tickets/" + grillTicket + "/bump", token: chef.token, status: http.StatusOK, check: expectField("status", models.PrepStatusReady)},
+		{name: "bump twice", method: http.MethodPost, path: "/kitchen/tickets/" + grillTicket + "/bump", token: chef.token, status: http.StatusConflict},
+		{name: "items are ready", method: http.MethodGet, path: "/orderItems/" + items[0].Order_item_id, token: chef.token, status: http.StatusOK, check: expectField("prep_status", models.PrepStatusReady)},
+		{name: "bumped tickets leave the board", method: http.MethodGet, path: "/kitchen/tickets", token: chef.token, status: http.StatusOK, check: expectLength(2)},
+		{name: "list ready tickets", method: http.MethodGet, path: "/kitchen/tickets?status=" + models.PrepStatusReady, token: chef.token, status: http.StatusOK, check: expectLength(1)},
+		{name: "recall unknown", method: http.MethodPost, path: "/kitchen/tickets/missing/recall", token: chef.token, status: http.StatusNotFound},
+		{name: "recall", method: http.MethodPost, path: "/kitchen/tickets/" + grillTicket + "/recall", token: chef.token, status: http.StatusOK, check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+			body := decode(t, rec)
+
+			if body["status"] != models.PrepStatusCooking || body["ready_at"] != nil {
+				t.Errorf("ticket was not recalled: %v", body)
+			}
+		}},
+		{name: "recalled items are cooking again", method: http.MethodGet, path: "/orderItems/" + items[0].Order_item_id, token: chef.token, status: http.StatusOK, check: expectField("prep_status", models.PrepStatusCooking)},
+		{name: "bump unknown", method: http.MethodPost, path: "/kitchen/tickets/missing/bump", token: chef.token, status: http.StatusNotFound},
+		{name: "bump straight from the queue", method: http.MethodPost, path: "/kitchen/tickets/" + barTicket + "/bump", token: chef.token, status: http.StatusOK, check: expectField("status", models.PrepStatusReady)},
+		{name: "serve", method: http.MethodPatch, path: "/orders/" + order.Order_id, as: helpers.RoleWaiter, body: map[string]string{"status": models.OrderStatusServed}, status: http.StatusOK},
+		{name: "served items", method: http.MethodGet, path: "/orderItems/" + items[1].Order_item_id, token: chef.token, status: http.StatusOK, check: expectField("prep_status", models.PrepStatusServed)},
+	})
+}
+
+func TestCancelledOrderLeavesTheKitchen(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer(t)
+	table := s.seedTable(t, 1)
+	order, _ := s.seedOrder(t, table.Table_id, s.seedFood(t, s.seedMenu(t).Menu_id, "Soup", 4.5))
+
+	s.run(t, []routeCase{
+		{name: "send to the kitchen", method: http.MethodPatch, path: "/orders/" + order.Order_id, as: helpers.RoleWaiter, body: map[string]string{"status": models.OrderStatusSentToKitchen}, status: http.StatusOK},
+		{name: "ticket is queued", method: http.MethodGet, path: "/kitchen/tickets", as: helpers.RoleChef, status: http.StatusOK, check: expectLength(1)},
+		{name: "cancel", method: http.MethodPost, path: "/orders/" + order.Order_id + "/cancel", as: helpers.RoleWaiter, status: http.StatusOK},
+		{name: "ticket is gone", method: http.MethodGet, path: "/kitchen/tickets", as: helpers.RoleChef, status: http.StatusOK, check: expectLength(0)},
+	})
+}
+
+func TestStationScopedApiKeySeesOnlyItsStation(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer(t)
+	table := s.seedTable(t, 1)
+	menu := s.seedMenu(t)
+	order, _ := s.seedOrder(t, table.Table_id, s.seedFood(t, menu.Menu_id, "Burger", 12), s.seedFood(t, menu.Menu_id, "Beer", 5))
+
+	station := "bar"
+	wine := s.seedFood(t, menu.Menu_id, "Wine", 7)
+	s.seedOrderItem(t, order.Order_id, wine)
+
+	s.run(t, []routeCase{
+		{name: "route wine to the bar", method: http.MethodPatch, path: "/foods/" + wine.Food_id, as: helpers.RoleManager, body: map[string]string{"station": station}, status: http.StatusOK},
+		{name: "send to the kitchen", method: http.MethodPatch, path: "/orders/" + order.Order_id, as: helpers.RoleWaiter, body: map[string]string{"status": models.OrderStatusSentToKitchen}, status: http.StatusOK},
+	})
+
+	all := decodeList(t, s.request(http.MethodGet, "/kitchen/tickets", s.as(t, helpers.RoleChef).token, nil))
+
+	var barTicket, kitchenTicket string
+
+	for _, ticket := range all {
+		id, _ := ticket["ticket_id"].(string)
+
+		if ticket["station"] == station {
+			barTicket = id
+		} else {
+			kitchenTicket = id
+		}
+	}
+
+	if barTicket == "" || kitchenTicket == "" {
+		t.Fatalf("expected a bar and a kitchen ticket: %v", all)
+	}
+
+	key := s.seedScopedApiKey(t, helpers.RoleChef, nil, &station)
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		status int
+		length int
+	}{
+		{name: "list only its station", method: http.MethodGet, path: "/kitchen/tickets", status: http.StatusOK, length: 1},
+		{name: "list another station", method: http.MethodGet, path: "/kitchen/tickets?station=" + helpers.DefaultStation, status: http.StatusForbidden},
+		{name: "get a ticket of another station", method: http.MethodGet, path: "/kitchen/tickets/" + kitchenTicket, status: http.StatusForbidden},
+		{name: "start a ticket of another station", method: http.MethodPost, path: "/kitchen/tickets/" + kitchenTicket + "/start", status: http.StatusForbidden},
+		{name: "bump its own ticket", method: http.MethodPost, path: "/kitchen/tickets/" + barTicket + "/bump", status: http.StatusOK},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := s.request(tc.method, tc.path, "", nil, helpers.ApiKeyHeader, key)
+
+			if rec.Code != tc.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tc.status, rec.Body.String())
+			}
+
+			if tc.length > 0 {
+				expectLength(tc.length)(t, rec)
+			}
+		})
+	}
+}
+
+func TestCourseFiring(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer(t)
+	table := s.seedTable(t, 5)
+	menu := s.seedMenu(t)
+	soup := s.seedFood(t, menu.Menu_id, "Soup", 4.5)
+	steak := s.seedFood(t, menu.Menu_id, "Steak", 24)
+	waiter := s.as(t, helpers.RoleWaiter)
+	chef := s.as(t, helpers.RoleChef)
+
+	rec := s.request(http.MethodPost, "/orderItems", waiter.token, map[string]interface{}{
+		"table_id": table.Table_id,
+		"order_items": []map[string]interface{}{
+			{"food_id": soup.Food_id, "quantity": 1},
+			{"food_id": steak.Food_id, "quantity": 1, "course": 2, "hold": true},
+		},
+	})
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /orderItems = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	items := decodeList(t, rec)
+	orderId, _ := items[0]["order_id"].(string)
+	soupId, _ := items[0]["order_item_id"].(string)
+	steakId, _ := items[1]["order_item_id"].(string)
+
+	if _, echoed := items[1]["hold"]; echoed || items[1]["prep_status"] != models.PrepStatusHeld {
+		t.Fatalf("steak is not held: %v", items[1])
+	}
+
+	fire := func(course string) string {
+		return "/orders/" + orderId + "/courses/" + course + "/fire"
+	}
+
+	s.run(t, []routeCase{
+		{name: "fire an open order", method: http.MethodPost, path: fire("2"), token: waiter.token, status: http.StatusConflict},
+		{name: "move a held item to another course", method: http.MethodPatch, path: "/orderItems/" + steakId, token: waiter.token, body: map[string]int{"course": 3}, status: http.StatusOK, check: expectField("course", 3)},
+		{name: "move it back", method: http.MethodPatch, path: "/orderItems/" + steakId, token: waiter.token, body: map[string]int{"course": 2}, status: http.StatusOK},
+		{name: "release a held item", method: http.MethodPatch, path: "/orderItems/" + steakId, token: waiter.token, body: map[string]bool{"hold": false}, status: http.StatusOK, check: expectField("prep_status", "")},
+		{name: "hold it again", method: http.MethodPatch, path: "/orderItems/" + steakId, token: waiter.token, body: map[string]bool{"hold": true}, status: http.StatusOK, check: expectField("prep_status", models.PrepStatusHeld)},
+		{name: "send to the kitchen", method: http.MethodPatch, path: "/orders/" + orderId, token: waiter.token, body: map[string]string{"status": models.OrderStatusSentToKitchen}, status: http.StatusOK},
+		{name: "only the first course is sent", method: http.MethodGet, path: "/kitchen/tickets", token: chef.token, status: http.StatusOK, check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+			tickets := decodeList(t, rec)
+
+			if len(tickets) != 1 {
+				t.Fatalf("got %d tickets, want 1", len(tickets))
+			}
+
+			if lines, _ := tickets[0]["items"].([]interface{}); len(lines) != 1 {
+				t.Errorf("ticket has %d items, want 1", len(lines))
+			}
+		}},
+		{name: "release a held item of an order in the kitchen", method: http.MethodPatch, path: "/orderItems/" + steakId, token: waiter.token, body: map[string]bool{"hold": false}, status: http.StatusConflict},
+		{name: "move a sent item to another course", method: http.MethodPatch, path: "/orderItems/" + soupId, token: waiter.token, body: map[string]int{"course": 2}, status: http.StatusConflict},
+		{name: "serve with held items", method: http.MethodPatch, path: "/orders/" + orderId, token: waiter.token, body: map[string]string{"status": models.OrderStatusServed}, status: http.StatusConflict},
+		{name: "fire needs order:write", method: http.MethodPost, path: fire("2"), token: chef.token, status: http.StatusForbidden},
+		{name: "fire an invalid course", method: http.MethodPost, path: fire("main"), token: waiter.token, status: http.StatusBadRequest},
+		{name: "fire an unknown order", method: http.MethodPost, path: "/orders/missing/courses/2/fire", token: waiter.token, status: http.StatusNotFound},
+		{name: "fire a course without held items", method: http.MethodPost, path: fire("3"), token: waiter.token, status: http.StatusConflict},
+		{name: "fire", method: http.MethodPost, path: fire("2"), token: waiter.token, status: http.StatusOK, check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+			tickets := decodeList(t, rec)
+
+			if len(tickets) != 1 {
+				t.Fatalf("got %d tickets, want 1", len(tickets))
+			}
+
+			lines, _ := tickets[0]["items"].([]interface{})
+			line, _ := lines[0].(map[string]interface{})
+
+			if line["order_item_id"] != steakId || line["course"] != float64(2) {
+				t.Errorf("unexpected ticket item: %v", line)
+			}
+		}},
+		{name: "fire twice", method: http.MethodPost, path: fire("2"), token: waiter.token, status: http.StatusConflict},
+		{name: "fired items are queued", method: http.MethodGet, path: "/orderItems/" + steakId, token: waiter.token, status: http.StatusOK, check: expectField("prep_status", models.PrepStatusQueued)},
+		{name: "both courses are on the board", method: http.MethodGet, path: "/kitchen/tickets", token: chef.token, status: http.StatusOK, check: expectLength(2)},
+		{name: "serve", method: http.MethodPatch, path: "/orders/" + orderId, token: waiter.token, body: map[string]string{"status": models.OrderStatusServed}, status: http.StatusOK},
+	})
+}
+
+// staleItems keeps answering with the items of the first listing, the way
+// a request that read them just before a concurrent one changed them does.
+type staleItems struct {
+	repository.OrderRepository
+	items []models.OrderItem
+}
+
+func (r *staleItems) ListItemsByOrder(ctx context.Context, orderId string) ([]models.OrderItem, error) {
+	if r.items == nil {
+		items, err := r.OrderRepository.ListItemsByOrder(ctx, orderId)
+
+		if err != nil {
+			return nil, err
+		}
+
+		r.items = items
+	}
+
+	return r.items, nil
+}
+
+func TestConcurrentFiresSendACourseOnce(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer(t)
+	menu := s.seedMenu(t)
+	steak := s.seedFood(t, menu.Menu_id, "Steak", 24.5)
+	order, items := s.seedOrder(t, s.seedTable(t, 1).Table_id, steak)
+	waiter := s.as(t, helpers.RoleWaiter)
+
+	course := 2
+	items[0].Prep_status = models.PrepStatusHeld
+	items[0].Course = &course
+
+	if err := s.repos.Orders.UpdateItem(context.Background(), items[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	s.run(t, []routeCase{
+		{name: "send to the kitchen", method: http.MethodPatch, path: "/orders/" + order.Order_id, token: waiter.token, body: map[string]string{"status": models.OrderStatusSentToKitchen}, status: http.StatusOK},
+	})
+
+	s.repos.Orders = &staleItems{OrderRepository: s.repos.Orders}
+	fire := "/orders/" + order.Order_id + "/courses/2/fire"
+
+	s.run(t, []routeCase{
+		{name: "fire", method: http.MethodPost, path: fire, token: waiter.token, status: http.StatusOK},
+		{name: "fire with a stale read", method: http.MethodPost, path: fire, token: waiter.token, status: http.StatusConflict},
+		{name: "the kitchen got the course once", method: http.MethodGet, path: "/kitchen/tickets", as: helpers.RoleChef, status: http.StatusOK, check: expectLength(1)},
+	})
+}
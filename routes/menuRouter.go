@@ -2,14 +2,15 @@ package routes
 
 import (
 	controllers "golang-restaurant-management/controllers"
+	"golang-restaurant-management/repository"
 
 	"github.com/gin-gonic/gin"
 )
 
-func MenuRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.GET("/menus", controllers.GetMenus())
-	incomingRoutes.GET("/menus/:menu_id", controllers.GetMenu())
-	incomingRoutes.POST("/menus", controllers.CreateMenu())
-	incomingRoutes.PATCH("/menus/:menu_id", controllers.UpdateMenu())
-	incomingRoutes.DELETE("/menus/:menu_id", controllers.DeleteMenu())
+func MenuRoutes(incomingRoutes *gin.Engine, repos *repository.Repositories) {
+	incomingRoutes.GET("/menus", controllers.GetMenus(repos))
+	incomingRoutes.GET("/menus/:menu_id", controllers.GetMenu(repos))
+	incomingRoutes.POST("/menus", controllers.CreateMenu(repos))
+	incomingRoutes.PATCH("/menus/:menu_id", controllers.UpdateMenu(repos))
+	incomingRoutes.DELETE("/menus/:menu_id", controllers.DeleteMenu(repos))
 }
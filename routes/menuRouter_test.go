@@ -0,0 +1,30 @@
+package routes
+
+import (
+	"golang-restaurant-management/helpers"
+	"net/http"
+	"testing"
+)
+
+func TestMenuRoutes(t *testing.T) {
+	s := newTestServer(t)
+	menu := s.seedMenu(t)
+	unused := s.seedMenu(t)
+
+	s.run(t, []routeCase{
+		{name: "list requires a login", method: http.MethodGet, path: "/menus", status: http.StatusUnauthorized},
+		{name: "list", method: http.MethodGet, path: "/menus", as: helpers.RoleUser, status: http.StatusOK, check: expectLength(2)},
+		{name: "get", method: http.MethodGet, path: "/menus/" + menu.Menu_id, as: helpers.RoleUser, status: http.StatusOK, check: expectField("name", "Dinner")},
+		{name: "get unknown", method: http.MethodGet, path: "/menus/missing", as: helpers.RoleUser, status: http.StatusNotFound},
+		{name: "create needs menu:write", method: http.MethodPost, path: "/menus", as: helpers.RoleWaiter, body: map[string]string{"name": "Lunch", "category": "Main"}, status: http.StatusForbidden},
+		{name: "create without category", method: http.MethodPost, path: "/menus", as: helpers.RoleManager, body: map[string]string{"name": "Lunch"}, status: http.StatusBadRequest},
+		{name: "create with malformed JSON", method: http.MethodPost, path: "/menus", as: helpers.RoleManager, body: "{", status: http.StatusBadRequest},
+		{name: "create", method: http.MethodPost, path: "/menus", as: helpers.RoleManager, body: map[string]string{"name": "Lunch", "category": "Main"}, status: http.StatusOK, check: expectField("name", "Lunch")},
+		{name: "update", method: http.MethodPatch, path: "/menus/" + menu.Menu_id, as: helpers.RoleManager, body: map[string]string{"category": "Seasonal"}, status: http.StatusOK, check: expectField("category", "Seasonal")},
+		{name: "update unknown", method: http.MethodPatch, path: "/menus/missing", as: helpers.RoleManager, body: map[string]string{"category": "Seasonal"}, status: http.StatusNotFound},
+		{name: "delete needs menu:write", method: http.MethodDelete, path: "/menus/" + unused.Menu_id, as: helpers.RoleChef, status: http.StatusForbidden},
+		{name: "delete", method: http.MethodDelete, path: "/menus/" + unused.Menu_id, as: helpers.RoleManager, status: http.StatusOK},
+		{name: "delete again", method: http.MethodDelete, path: "/menus/" + unused.Menu_id, as: helpers.RoleManager, status: http.StatusNotFound},
+		{name: "list after changes", method: http.MethodGet, path: "/menus", as: helpers.RoleUser, status: http.StatusOK, check: expectLength(2)},
+	})
+}
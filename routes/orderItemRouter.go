@@ -2,15 +2,18 @@ package routes
 
 import (
 	controllers "golang-restaurant-management/controllers"
+	"golang-restaurant-management/repository"
 
 	"github.com/gin-gonic/gin"
 )
 
-func OrderItemsRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.GET("/orderItems", controllers.GetOrderItems())
-	incomingRoutes.GET("/orderItems/:orderItem_id", controllers.GetOrderItem())
-	incomingRoutes.GET("/orderItems-order/:order_id", controllers.GetOrderItemsByOrder())
-	incomingRoutes.POST("/orderItems", controllers.CreateOrderItem())
-	incomingRoutes.PATCH("orderItems/:orderItem_id", controllers.UpdateOrderItem())
-	incomingRoutes.DELETE("orderItems/:orderItem_id", controllers.DeleteOrderItem())
+func OrderItemsRoutes(incomingRoutes *gin.Engine, repos *repository.Repositories) {
+	incomingRoutes.GET("/orderItems", controllers.GetOrderItems(repos))
+	incomingRoutes.GET("/orderItems/:orderItem_id", controllers.GetOrderItem(repos))
+	incomingRoutes.GET("/orderItems-order/:order_id", controllers.GetOrderItemsByOrder(repos))
+	incomingRoutes.POST("/orderItems", controllers.CreateOrderItem(repos))
+	incomingRoutes.PATCH("orderItems/:orderItem_id", controllers.UpdateOrderItem(repos))
+	incomingRoutes.DELETE("orderItems/:orderItem_id", controllers.DeleteOrderItem(repos))
+	incomingRoutes.POST("/orderItems/:orderItem_id/void", controllers.VoidOrderItem(repos))
+	incomingRoutes.POST("/orderItems/:orderItem_id/comp", controllers.CompOrderItem(repos))
 }
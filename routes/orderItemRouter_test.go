@@ -0,0 +1,458 @@
+package routes
+
+import (
+	"context"
+	"golang-restaurant-management/helpers"
+	"golang-restaurant-management/models"
+	"golang-restaurant-management/repository"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOrderItemRoutes(t *testing.T) {
+	s := newTestServer(t)
+	menu := s.seedMenu(t)
+	steak := s.seedFood(t, menu.Menu_id, "Steak", 24.5)
+	salad := s.seedFood(t, menu.Menu_id, "Salad", 9)
+	table := s.seedTable(t, 7)
+	order, items := s.seedOrder(t, table.Table_id, steak, salad)
+	single, singleItems := s.seedOrder(t, table.Table_id, salad)
+
+	pack := map[string]interface{}{
+		"table_id": table.Table_id,
+		"order_items": []map[string]interface{}{
+			{"food_id": steak.Food_id, "quantity": 2, "unit_price": 24.5},
+		},
+	}
+
+	invalidPack := map[string]interface{}{
+		"table_id": table.Table_id,
+		"order_items": []map[string]interface{}{
+			{"food_id": steak.Food_id, "quantity": 0, "unit_price": 24.5},
+		},
+	}
+
+	s.run(t, []routeCase{
+		{name: "list requires a login", method: http.MethodGet, path: "/orderItems", status: http.StatusUnauthorized},
+		{name: "list", method: http.MethodGet, path: "/orderItems", as: helpers.RoleWaiter, status: http.StatusOK, check: expectLength(3)},
+		{name: "get", method: http.MethodGet, path: "/orderItems/" + items[0].Order_item_id, as: helpers.RoleChef, status: http.StatusOK, check: expectField("order_id", order.Order_id)},
+		{name: "get unknown", method: http.MethodGet, path: "/orderItems/missing", as: helpers.RoleChef, status: http.StatusNotFound},
+		{name: "items of an order", method: http.MethodGet, path: "/orderItems-order/" + order.Order_id, as: helpers.RoleWaiter, status: http.StatusOK, check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+			body := decodeSummary(t, rec)
+
+			if body["payment_due"] != 33.5 || body["total_count"] != 2.0 || body["table_number"] != 7.0 {
+				t.Errorf("unexpected summary: %v", body)
+			}
+		}},
+		{name: "items of an unknown order", method: http.MethodGet, path: "/orderItems-order/missing", as: helpers.RoleWaiter, status: http.StatusNotFound},
+		{name: "create needs order:write", method: http.MethodPost, path: "/orderItems", as: helpers.RoleChef, body: pack, status: http.StatusForbidden},
+		{name: "create without items", method: http.MethodPost, path: "/orderItems", as: helpers.RoleWaiter, body: map[string]interface{}{"table_id": table.Table_id, "order_items": []interface{}{}}, status: http.StatusBadRequest},
+		{name: "create without a table", method: http.MethodPost, path: "/orderItems", as: helpers.RoleWaiter, body: map[string]interface{}{"order_items": pack["order_items"]}, status: http.StatusBadRequest},
+		{name: "create with an invalid quantity", method: http.MethodPost, path: "/orderItems", as: helpers.RoleWaiter, body: invalidPack, status: http.StatusBadRequest},
+		{name: "create", method: http.MethodPost, path: "/orderItems", as: helpers.RoleWaiter, body: pack, status: http.StatusOK, check: expectLength(1)},
+		{name: "update", method: http.MethodPatch, path: "/orderItems/" + items[0].Order_item_id, as: helpers.RoleWaiter, body: map[string]int{"quantity": 3}, status: http.StatusOK, check: expectField("quantity", 3)},
+		{name: "update with an invalid quantity", method: http.MethodPatch, path: "/orderItems/" + items[0].Order_item_id, as: helpers.RoleWaiter, body: map[string]int{"quantity": 0}, status: http.StatusBadRequest},
+		{name: "update unknown", method: http.MethodPatch, path: "/orderItems/missing", as: helpers.RoleWaiter, body: map[string]int{"quantity": 3}, status: http.StatusNotFound},
+		{name: "delete one of several items", method: http.MethodDelete, path: "/orderItems/" + items[1].Order_item_id, as: helpers.RoleWaiter, status: http.StatusOK, check: expectField("message", "Order item deleted")},
+		{name: "delete the last item", method: http.MethodDelete, path: "/orderItems/" + singleItems[0].Order_item_id, as: helpers.RoleWaiter, status: http.StatusOK, check: expectField("message", "Order item deleted")},
+		{name: "order of the last item is kept", method: http.MethodGet, path: "/orderItems-order/" + single.Order_id, as: helpers.RoleWaiter, status: http.StatusOK, check: expectSummaryField("total_count", 0)},
+		{name: "send the order to the kitchen", method: http.MethodPatch, path: "/orders/" + order.Order_id, as: helpers.RoleWaiter, body: map[string]string{"status": models.OrderStatusSentToKitchen}, status: http.StatusOK},
+		{name: "delete an item in the kitchen", method: http.MethodDelete, path: "/orderItems/" + items[0].Order_item_id, as: helpers.RoleWaiter, status: http.StatusConflict},
+		{name: "delete unknown", method: http.MethodDelete, path: "/orderItems/missing", as: helpers.RoleWaiter, status: http.StatusNotFound},
+	})
+}
+
+func TestTableScopedApiKeyOrdersOnlyForItsTable(t *testing.T) {
+	s := newTestServer(t)
+	menu := s.seedMenu(t)
+	steak := s.seedFood(t, menu.Menu_id, "Steak", 24.5)
+	own := s.seedTable(t, 1)
+	other := s.seedTable(t, 2)
+
+	key := s.seedApiKey(t, helpers.RoleUser, &own.Table_id)
+
+	packFor := func(tableId string) map[string]interface{} {
+		return map[string]interface{}{
+			"table_id":    tableId,
+			"order_items": []map[string]interface{}{{"food_id": steak.Food_id, "quantity": 1, "unit_price": 24.5}},
+		}
+	}
+
+	if rec := s.request(http.MethodPost, "/orderItems", "", packFor(other.Table_id), helpers.ApiKeyHeader, key); rec.Code != http.StatusForbidden {
+		t.Errorf("ordering for another table = %d, want 403", rec.Code)
+	}
+
+	if rec := s.request(http.MethodPost, "/orderItems", "", packFor(own.Table_id), helpers.ApiKeyHeader, key); rec.Code != http.StatusOK {
+		t.Errorf("ordering for its own table = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+}
+
+// countingOrders counts the writes that reach the order store.
+type countingOrders struct {
+	repository.OrderRepository
+	writes int
+}
+
+func (r *countingOrders) Create(ctx context.Context, order models.Order) error {
+	r.writes++
+	return r.OrderRepository.Create(ctx, order)
+}
+
+func (r *countingOrders) CreateItems(ctx context.Context, items []models.OrderItem) error {
+	r.writes++
+	return r.OrderRepository.CreateItems(ctx, items)
+}
+
+func (r *countingOrders) CreateWithItems(ctx context.Context, order models.Order, items []models.OrderItem) error {
+	r.writes++
+	return r.OrderRepository.CreateWithItems(ctx, order, items)
+}
+
+func TestRejectedOrderPackWritesNothing(t *testing.T) {
+	s := newTestServer(t)
+	menu := s.seedMenu(t)
+	steak := s.seedFood(t, menu.Menu_id, "Steak", 24.5)
+	table := s.seedTable(t, 1)
+
+	orders := &countingOrders{OrderRepository: s.repos.Orders}
+	s.repos.Orders = orders
+
+	item := func(quantity int) map[string]interface{} {
+		return map[string]interface{}{"food_id": steak.Food_id, "quantity": quantity, "unit_price": 24.5}
+	}
+
+	pack := map[string]interface{}{
+		"table_id":    table.Table_id,
+		"order_items": []map[string]interface{}{item(1), item(2), item(0)},
+	}
+
+	if rec := s.request(http.MethodPost, "/orderItems", s.as(t, helpers.RoleWaiter).token, pack); rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", rec.Code)
+	}
+
+	if orders.writes != 0 {
+		t.Errorf("a rejected pack caused %d writes", orders.writes)
+	}
+
+	pack = map[string]interface{}{
+		"table_id":    "missing",
+		"order_items": []map[string]interface{}{item(1)},
+	}
+
+	if rec := s.request(http.MethodPost, "/orderItems", s.as(t, helpers.RoleWaiter).token, pack); rec.Code != http.StatusBadRequest {
+		t.Fatalf("ordering for an unknown table = %d, want 400", rec.Code)
+	}
+
+	if orders.writes != 0 {
+		t.Errorf("a pack for an unknown table caused %d writes", orders.writes)
+	}
+}
+
+func TestOrderItemPricing(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer(t)
+	menu := s.seedMenu(t)
+	steak := s.seedFood(t, menu.Menu_id, "Steak", 24.5)
+	salad := s.seedFood(t, menu.Menu_id, "Salad", 9)
+	table := s.seedTable(t, 1)
+	_, items := s.seedOrder(t, table.Table_id, steak)
+	manager := s.as(t, helpers.RoleManager)
+
+	packOf := func(item map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"table_id": table.Table_id, "order_items": []map[string]interface{}{item}}
+	}
+
+	firstItem := func(check func(t *testing.T, item map[string]interface{})) func(t *testing.T, rec *httptest.ResponseRecorder) {
+		return func(t *testing.T, rec *httptest.ResponseRecorder) {
+			list := decodeList(t, rec)
+
+			if len(list) != 1 {
+				t.Fatalf("got %d items, want 1", len(list))
+			}
+
+			check(t, list[0])
+		}
+	}
+
+	override := map[string]string{"reason": "Birthday treat"}
+
+	s.run(t, []routeCase{
+		{name: "client price is ignored", method: http.MethodPost, path: "/orderItems", as: helpers.RoleWaiter, body: packOf(map[string]interface{}{"food_id": steak.Food_id, "quantity": 1, "unit_price": 0.01}), status: http.StatusOK, check: firstItem(func(t *testing.T, item map[string]interface{}) {
+			if item["unit_price"] != 24.5 || item["price_override"] != nil {
+				t.Errorf("unexpected price: %v", item)
+			}
+		})},
+		{name: "unknown food", method: http.MethodPost, path: "/orderItems", as: helpers.RoleWaiter, body: packOf(map[string]interface{}{"food_id": "missing", "quantity": 1}), status: http.StatusBadRequest},
+		{name: "waiters cannot override", method: http.MethodPost, path: "/orderItems", as: helpers.RoleWaiter, body: packOf(map[string]interface{}{"food_id": steak.Food_id, "quantity": 1, "unit_price": 0.01, "price_override": override}), status: http.StatusForbidden},
+		{name: "override without a reason", method: http.MethodPost, path: "/orderItems", token: manager.token, body: packOf(map[string]interface{}{"food_id": steak.Food_id, "quantity": 1, "unit_price": 10, "price_override": map[string]string{}}), status: http.StatusBadRequest},
+		{name: "override without a price", method: http.MethodPost, path: "/orderItems", token: manager.token, body: packOf(map[string]interface{}{"food_id": steak.Food_id, "quantity": 1, "price_override": override}), status: http.StatusBadRequest},
+		{name: "override", method: http.MethodPost, path: "/orderItems", token: manager.token, body: packOf(map[string]interface{}{"food_id": steak.Food_id, "quantity": 1, "unit_price": 10, "price_override": override}), status: http.StatusOK, check: firstItem(func(t *testing.T, item map[string]interface{}) {
+			recorded, _ := item["price_override"].(map[string]interface{})
+
+			if item["unit_price"] != 10.0 || recorded["menu_price"] != 24.5 || recorded["overridden_by"] != manager.User_id || recorded["reason"] != "Birthday treat" {
+				t.Errorf("override was not recorded: %v", item)
+			}
+		})},
+		{name: "update ignores a bare price", method: http.MethodPatch, path: "/orderItems/" + items[0].Order_item_id, as: helpers.RoleWaiter, body: map[string]interface{}{"unit_price": 0.01}, status: http.StatusOK, check: expectField("unit_price", 24.5)},
+		{name: "update to another food reprices", method: http.MethodPatch, path: "/orderItems/" + items[0].Order_item_id, as: helpers.RoleWaiter, body: map[string]interface{}{"food_id": salad.Food_id, "unit_price": 0.01}, status: http.StatusOK, check: expectField("unit_price", 9)},
+		{name: "update to an unknown food", method: http.MethodPatch, path: "/orderItems/" + items[0].Order_item_id, as: helpers.RoleWaiter, body: map[string]interface{}{"food_id": "missing"}, status: http.StatusBadRequest},
+		{name: "update with an override", method: http.MethodPatch, path: "/orderItems/" + items[0].Order_item_id, token: manager.token, body: map[string]interface{}{"unit_price": 5, "price_override": override}, status: http.StatusOK, check: expectField("unit_price", 5)},
+	})
+}
+
+func TestOrderItemVariants(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer(t)
+	menu := s.seedMenu(t)
+	soup := s.seedFood(t, menu.Menu_id, "Soup", 6.5)
+	pizza := s.seedFood(t, menu.Menu_id, "Pizza", 8)
+	table := s.seedTable(t, 1)
+
+	small, large := 8.0, 12.5
+	pizza.Variants = []models.FoodVariant{{Name: "Small", Price: &small}, {Name: "Large", Price: &large}}
+
+	if err := s.repos.Foods.Update(context.Background(), pizza); err != nil {
+		t.Fatal(err)
+	}
+
+	packOf := func(items ...map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"table_id": table.Table_id, "order_items": items}
+	}
+
+	var orderId, itemId string
+
+	s.run(t, []routeCase{
+		{name: "variant of a food without variants", method: http.MethodPost, path: "/orderItems", as: helpers.RoleWaiter, body: packOf(map[string]interface{}{"food_id": soup.Food_id, "quantity": 1, "variant": "Large"}), status: http.StatusBadRequest},
+		{name: "food with variants ordered without one", method: http.MethodPost, path: "/orderItems", as: helpers.RoleWaiter, body: packOf(map[string]interface{}{"food_id": pizza.Food_id, "quantity": 1}), status: http.StatusBadRequest},
+		{name: "unknown variant", method: http.MethodPost, path: "/orderItems", as: helpers.RoleWaiter, body: packOf(map[string]interface{}{"food_id": pizza.Food_id, "quantity": 1, "variant": "Huge"}), status: http.StatusBadRequest},
+		{name: "create", method: http.MethodPost, path: "/orderItems", as: helpers.RoleWaiter, body: packOf(
+			map[string]interface{}{"food_id": pizza.Food_id, "quantity": 3, "variant": "Large"},
+			map[string]interface{}{"food_id": soup.Food_id, "quantity": 2},
+		), status: http.StatusOK, check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+			list := decodeList(t, rec)
+
+			if len(list) != 2 || list[0]["unit_price"] != 12.5 || list[0]["quantity"] != 3.0 {
+				t.Fatalf("unexpected items: %v", list)
+			}
+
+			orderId, _ = list[0]["order_id"].(string)
+			itemId, _ = list[0]["order_item_id"].(string)
+		}},
+	})
+
+	s.run(t, []routeCase{
+		{name: "summary multiplies quantity and price", method: http.MethodGet, path: "/orderItems-order/" + orderId, as: helpers.RoleWaiter, status: http.StatusOK, check: expectSummaryField("payment_due", 50.5)},
+		{name: "switch to an unknown variant", method: http.MethodPatch, path: "/orderItems/" + itemId, as: helpers.RoleWaiter, body: map[string]string{"variant": "Huge"}, status: http.StatusBadRequest},
+		{name: "switch variant", method: http.MethodPatch, path: "/orderItems/" + itemId, as: helpers.RoleWaiter, body: map[string]string{"variant": "Small"}, status: http.StatusOK, check: expectField("unit_price", 8)},
+		{name: "summary after the switch", method: http.MethodGet, path: "/orderItems-order/" + orderId, as: helpers.RoleWaiter, status: http.StatusOK, check: expectSummaryField("payment_due", 37)},
+	})
+}
+
+func TestOrderItemModifiers(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer(t)
+	menu := s.seedMenu(t)
+	burger := s.seedFood(t, menu.Menu_id, "Burger", 12)
+	table := s.seedTable(t, 1)
+
+	burger.Modifier_groups = []models.ModifierGroup{
+		{Name: "Doneness", Required: true, Max_selections: 1, Options: []models.ModifierOption{{Name: "Medium"}, {Name: "Well done"}}},
+		{Name: "Extras", Options: []models.ModifierOption{{Name: "Cheese", Price_delta: 1.5}, {Name: "Bacon", Price_delta: 2}}},
+		{Name: "Remove", Options: []models.ModifierOption{{Name: "Onions"}}},
+	}
+
+	if err := s.repos.Foods.Update(context.Background(), burger); err != nil {
+		t.Fatal(err)
+	}
+
+	burgerWith := func(modifiers ...[2]string) map[string]interface{} {
+		selected := []map[string]string{}
+
+		for _, m := range modifiers {
+			selected = append(selected, map[string]string{"group": m[0], "option": m[1]})
+		}
+
+		return map[string]interface{}{
+			"table_id":    table.Table_id,
+			"order_items": []map[string]interface{}{{"food_id": burger.Food_id, "quantity": 2, "modifiers": selected, "notes": "Sauce on the side"}},
+		}
+	}
+
+	var orderId, itemId string
+
+	s.run(t, []routeCase{
+		{name: "required group left out", method: http.MethodPost, path: "/orderItems", as: helpers.RoleWaiter, body: burgerWith([2]string{"Extras", "Cheese"}), status: http.StatusBadRequest},
+		{name: "too many picks", method: http.MethodPost, path: "/orderItems", as: helpers.RoleWaiter, body: burgerWith([2]string{"Doneness", "Medium"}, [2]string{"Doneness", "Well done"}), status: http.StatusBadRequest},
+		{name: "unknown group", method: http.MethodPost, path: "/orderItems", as: helpers.RoleWaiter, body: burgerWith([2]string{"Doneness", "Medium"}, [2]string{"Sauce", "Mayo"}), status: http.StatusBadRequest},
+		{name: "unknown option", method: http.MethodPost, path: "/orderItems", as: helpers.RoleWaiter, body: burgerWith([2]string{"Doneness", "Raw"}), status: http.StatusBadRequest},
+		{name: "option picked twice", method: http.MethodPost, path: "/orderItems", as: helpers.RoleWaiter, body: burgerWith([2]string{"Doneness", "Medium"}, [2]string{"Extras", "Cheese"}, [2]string{"Extras", "Cheese"}), status: http.StatusBadRequest},
+		{name: "create", method: http.MethodPost, path: "/orderItems", as: helpers.RoleWaiter, body: burgerWith([2]string{"Doneness", "Medium"}, [2]string{"Extras", "Cheese"}, [2]string{"Extras", "Bacon"}, [2]string{"Remove", "Onions"}), status: http.StatusOK, check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+			list := decodeList(t, rec)
+
+			if len(list) != 1 || list[0]["unit_price"] != 15.5 || list[0]["notes"] != "Sauce on the side" {
+				t.Fatalf("unexpected items: %v", list)
+			}
+
+			orderId, _ = list[0]["order_id"].(string)
+			itemId, _ = list[0]["order_item_id"].(string)
+		}},
+	})
+
+	s.run(t, []routeCase{
+		{name: "summary includes the modifiers", method: http.MethodGet, path: "/orderItems-order/" + orderId, as: helpers.RoleWaiter, status: http.StatusOK, check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+			body := decodeSummary(t, rec)
+			lines, _ := body["order_items"].([]interface{})
+
+			if body["payment_due"] != 31.0 || len(lines) != 1 {
+				t.Fatalf("unexpected summary: %v", body)
+			}
+
+			if modifiers, _ := lines[0].(map[string]interface{})["modifiers"].([]interface{}); len(modifiers) != 4 {
+				t.Errorf("unexpected modifiers: %v", modifiers)
+			}
+		}},
+		{name: "update the notes", method: http.MethodPatch, path: "/orderItems/" + itemId, as: helpers.RoleWaiter, body: map[string]string{"notes": "No sauce"}, status: http.StatusOK, check: expectField("unit_price", 15.5)},
+		{name: "update the modifiers", method: http.MethodPatch, path: "/orderItems/" + itemId, as: helpers.RoleWaiter, body: map[string]interface{}{"modifiers": []map[string]string{{"group": "Doneness", "option": "Well done"}}}, status: http.StatusOK, check: expectField("unit_price", 12)},
+		{name: "update to an invalid selection", method: http.MethodPatch, path: "/orderItems/" + itemId, as: helpers.RoleWaiter, body: map[string]interface{}{"modifiers": []map[string]string{}}, status: http.StatusBadRequest},
+	})
+
+	invoice := s.seedInvoice(t, orderId)
+
+	s.run(t, []routeCase{
+		{name: "invoice totals include the modifiers", method: http.MethodGet, path: "/invoices/" + invoice.Invoice_id, as: helpers.RoleCashier, status: http.StatusOK, check: expectField("Payment_due", 24)},
+	})
+}
+
+func TestVoidAndCompOrderItems(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer(t)
+	menu := s.seedMenu(t)
+	table := s.seedTable(t, 3)
+	order, items := s.seedOrder(t, table.Table_id, s.seedFood(t, menu.Menu_id, "Soup", 6.5), s.seedFood(t, menu.Menu_id, "Steak", 24), s.seedFood(t, menu.Menu_id, "Wine", 7))
+	waiter := s.as(t, helpers.RoleWaiter)
+	manager := s.as(t, helpers.RoleManager)
+
+	for pin, user := range map[string]testUser{"1111": waiter, "4321": manager} {
+		if rec := s.request(http.MethodPost, "/users/pin", user.token, map[string]string{"password": testPassword, "pin": pin}); rec.Code != http.StatusOK {
+			t.Fatalf("POST /users/pin = %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	soup, steak, wine := items[0].Order_item_id, items[1].Order_item_id, items[2].Order_item_id
+
+	approvedBy := func(userId string, pin string) map[string]interface{} {
+		return map[string]interface{}{
+			"reason_code": models.ReasonGuestChangedMind,
+			"approval":    map[string]string{"user_id": userId, "pin": pin},
+		}
+	}
+
+	reason := map[string]interface{}{"reason_code": models.ReasonQualityIssue, "note": "Overcooked"}
+
+	s.run(t, []routeCase{
+		{name: "void needs order:write", method: http.MethodPost, path: "/orderItems/" + soup + "/void", as: helpers.RoleChef, body: reason, status: http.StatusForbidden},
+		{name: "void without a reason", method: http.MethodPost, path: "/orderItems/" + soup + "/void", token: manager.token, body: map[string]string{}, status: http.StatusBadRequest},
+		{name: "void with an unknown reason", method: http.MethodPost, path: "/orderItems/" + soup + "/void", token: manager.token, body: map[string]string{"reason_code": "BORED"}, status: http.StatusBadRequest},
+		{name: "void unknown", method: http.MethodPost, path: "/orderItems/missing/void", token: manager.token, body: reason, status: http.StatusNotFound},
+		{name: "void without approval", method: http.MethodPost, path: "/orderItems/" + soup + "/void", token: waiter.token, body: reason, status: http.StatusForbidden},
+		{name: "void with a wrong PIN", method: http.MethodPost, path: "/orderItems/" + soup + "/void", token: waiter.token, body: approvedBy(manager.User_id, "0000"), status: http.StatusUnauthorized},
+		{name: "void approved by a waiter", method: http.MethodPost, path: "/orderItems/" + soup + "/void", token: waiter.token, body: approvedBy(waiter.User_id, "1111"), status: http.StatusForbidden},
+		{name: "comp an item that has not reached the kitchen", method: http.MethodPost, path: "/orderItems/" + soup + "/comp", token: manager.token, body: reason, status: http.StatusConflict},
+		{name: "void approved with a manager's PIN", method: http.MethodPost, path: "/orderItems/" + soup + "/void", token: waiter.token, body: approvedBy(manager.User_id, "4321"), status: http.StatusOK, check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+			body := decode(t, rec)
+			adjustment, _ := body["adjustment"].(map[string]interface{})
+
+			if body["prep_status"] != models.PrepStatusVoided || body["unit_price"] != 0.0 {
+				t.Errorf("item was not voided: %v", body)
+			}
+
+			if adjustment["type"] != models.AdjustmentVoid || adjustment["unit_price"] != 6.5 || adjustment["requested_by"] != waiter.User_id || adjustment["approved_by"] != manager.User_id {
+				t.Errorf("unexpected adjustment: %v", adjustment)
+			}
+		}},
+		{name: "void twice", method: http.MethodPost, path: "/orderItems/" + soup + "/void", token: manager.token, body: reason, status: http.StatusConflict},
+		{name: "change a voided item", method: http.MethodPatch, path: "/orderItems/" + soup, token: waiter.token, body: map[string]int{"quantity": 2}, status: http.StatusConflict},
+		{name: "delete a voided item", method: http.MethodDelete, path: "/orderItems/" + soup, token: waiter.token, status: http.StatusConflict},
+		{name: "send to the kitchen", method: http.MethodPatch, path: "/orders/" + order.Order_id, token: waiter.token, body: map[string]string{"status": models.OrderStatusSentToKitchen}, status: http.StatusOK},
+		{name: "change the quantity of a sent item", method: http.MethodPatch, path: "/orderItems/" + steak, token: waiter.token, body: map[string]int{"quantity": 2}, status: http.StatusConflict},
+		{name: "change the notes of a sent item", method: http.MethodPatch, path: "/orderItems/" + steak, token: waiter.token, body: map[string]string{"notes": "Rare"}, status: http.StatusConflict},
+		{name: "voided items are not sent", method: http.MethodGet, path: "/kitchen/tickets", as: helpers.RoleChef, status: http.StatusOK, check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+			tickets := decodeList(t, rec)
+
+			if len(tickets) != 1 {
+				t.Fatalf("got %d tickets, want 1", len(tickets))
+			}
+
+			if lines, _ := tickets[0]["items"].([]interface{}); len(lines) != 2 {
+				t.Errorf("ticket has %d items, want 2", len(lines))
+			}
+		}},
+		{name: "void an item in the kitchen", method: http.MethodPost, path: "/orderItems/" + steak + "/void", token: manager.token, body: reason, status: http.StatusConflict},
+		{name: "comp unknown", method: http.MethodPost, path: "/orderItems/missing/comp", token: manager.token, body: reason, status: http.StatusNotFound},
+		{name: "comp", method: http.MethodPost, path: "/orderItems/" + steak + "/comp", token: manager.token, body: reason, status: http.StatusOK, check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+			body := decode(t, rec)
+			adjustment, _ := body["adjustment"].(map[string]interface{})
+
+			if body["prep_status"] != models.PrepStatusQueued || body["unit_price"] != 0.0 || adjustment["type"] != models.AdjustmentComp || adjustment["note"] != "Overcooked" {
+				t.Errorf("item was not comped: %v", body)
+			}
+		}},
+		{name: "adjusted lines stay on the order but are not charged", method: http.MethodGet, path: "/orderItems-order/" + order.Order_id, token: waiter.token, status: http.StatusOK, check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+			body := decodeSummary(t, rec)
+			lines, _ := body["order_items"].([]interface{})
+			first, _ := lines[0].(map[string]interface{})
+
+			if body["payment_due"] != 7.0 || body["total_count"] != 3.0 || first["adjustment"] == nil || first["amount"] != 0.0 {
+				t.Errorf("unexpected summary: %v", body)
+			}
+		}},
+		{name: "serve", method: http.MethodPatch, path: "/orders/" + order.Order_id, token: waiter.token, body: map[string]string{"status": models.OrderStatusServed}, status: http.StatusOK},
+		{name: "voided items are not served", method: http.MethodGet, path: "/orderItems/" + soup, token: waiter.token, status: http.StatusOK, check: expectField("prep_status", models.PrepStatusVoided)},
+		{name: "bill", method: http.MethodPatch, path: "/orders/" + order.Order_id, token: waiter.token, body: map[string]string{"status": models.OrderStatusBilled}, status: http.StatusOK},
+		{name: "comp an item of a billed order", method: http.MethodPost, path: "/orderItems/" + wine + "/comp", token: manager.token, body: reason, status: http.StatusConflict},
+	})
+}
+
+// staleItem keeps answering with the item as it was first read, the way a
+// request that loaded it just before a concurrent one changed it does.
+type staleItem struct {
+	repository.OrderRepository
+	item *models.OrderItem
+}
+
+func (r *staleItem) GetItem(ctx context.Context, orderItemId string) (models.OrderItem, error) {
+	if r.item == nil {
+		item, err := r.OrderRepository.GetItem(ctx, orderItemId)
+
+		if err != nil {
+			return item, err
+		}
+
+		r.item = &item
+	}
+
+	return *r.item, nil
+}
+
+func TestConcurrentVoidsApplyOnce(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer(t)
+	menu := s.seedMenu(t)
+	_, items := s.seedOrder(t, s.seedTable(t, 1).Table_id, s.seedFood(t, menu.Menu_id, "Soup", 6.5))
+	manager := s.as(t, helpers.RoleManager)
+	path := "/orderItems/" + items[0].Order_item_id + "/void"
+	reason := map[string]string{"reason_code": models.ReasonGuestChangedMind}
+
+	s.repos.Orders = &staleItem{OrderRepository: s.repos.Orders}
+
+	s.run(t, []routeCase{
+		{name: "void", method: http.MethodPost, path: path, token: manager.token, body: reason, status: http.StatusOK},
+		{name: "void with a stale read", method: http.MethodPost, path: path, token: manager.token, body: reason, status: http.StatusConflict},
+	})
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	controllers "golang-restaurant-management/controllers"
+	"golang-restaurant-management/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+func OrderRoutes(incomingRoutes *gin.Engine, repos *repository.Repositories) {
+	incomingRoutes.GET("/orders", controllers.GetOrders(repos))
+	incomingRoutes.GET("/orders/:order_id", controllers.GetOrder(repos))
+	incomingRoutes.POST("/orders", controllers.CreateOrder(repos))
+	incomingRoutes.PATCH("/orders/:order_id", controllers.UpdateOrder(repos))
+	incomingRoutes.POST("/orders/:order_id/items", controllers.AddOrderItems(repos))
+	incomingRoutes.POST("/orders/:order_id/cancel", controllers.CancelOrder(repos))
+	incomingRoutes.POST("/orders/:order_id/courses/:course/fire", controllers.FireCourse(repos))
+}
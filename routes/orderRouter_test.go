@@ -0,0 +1,276 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"golang-restaurant-management/helpers"
+	"golang-restaurant-management/models"
+	"golang-restaurant-management/repository"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOrderRoutes(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer(t)
+	table := s.seedTable(t, 1)
+	patio := s.seedTable(t, 2)
+	order, _ := s.seedOrder(t, table.Table_id)
+	waiter := s.as(t, helpers.RoleWaiter)
+
+	var createdId string
+
+	s.run(t, []routeCase{
+		{name: "list requires a login", method: http.MethodGet, path: "/orders", status: http.StatusUnauthorized},
+		{name: "list", method: http.MethodGet, path: "/orders", as: helpers.RoleChef, status: http.StatusOK, check: expectLength(1)},
+		{name: "list by an unknown status", method: http.MethodGet, path: "/orders?status=EATEN", as: helpers.RoleChef, status: http.StatusBadRequest},
+		{name: "get", method: http.MethodGet, path: "/orders/" + order.Order_id, as: helpers.RoleHost, status: http.StatusOK, check: expectField("status", models.OrderStatusOpen)},
+		{name: "get unknown", method: http.MethodGet, path: "/orders/missing", as: helpers.RoleHost, status: http.StatusNotFound},
+		{name: "create needs order:write", method: http.MethodPost, path: "/orders", as: helpers.RoleChef, body: map[string]string{"table_id": table.Table_id}, status: http.StatusForbidden},
+		{name: "create without a table", method: http.MethodPost, path: "/orders", as: helpers.RoleWaiter, body: map[string]string{}, status: http.StatusBadRequest},
+		{name: "create for an unknown table", method: http.MethodPost, path: "/orders", as: helpers.RoleWaiter, body: map[string]string{"table_id": "missing"}, status: http.StatusBadRequest},
+		{name: "create", method: http.MethodPost, path: "/orders", token: waiter.token, body: map[string]string{"table_id": table.Table_id, "status": models.OrderStatusClosed}, status: http.StatusOK, check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+			body := decode(t, rec)
+			createdId, _ = body["order_id"].(string)
+
+			history, _ := body["status_history"].([]interface{})
+
+			if body["status"] != models.OrderStatusOpen || len(history) != 1 {
+				t.Errorf("new order is not OPEN with one history entry: %v", body)
+			}
+		}},
+	})
+
+	s.run(t, []routeCase{
+		{name: "update with nothing to change", method: http.MethodPatch, path: "/orders/" + createdId, as: helpers.RoleWaiter, body: map[string]string{}, status: http.StatusBadRequest},
+		{name: "update to an unknown status", method: http.MethodPatch, path: "/orders/" + createdId, as: helpers.RoleWaiter, body: map[string]string{"status": "EATEN"}, status: http.StatusBadRequest},
+		{name: "skip the kitchen", method: http.MethodPatch, path: "/orders/" + createdId, as: helpers.RoleWaiter, body: map[string]string{"status": models.OrderStatusServed}, status: http.StatusConflict},
+		{name: "move to an unknown table", method: http.MethodPatch, path: "/orders/" + createdId, as: helpers.RoleWaiter, body: map[string]string{"table_id": "missing"}, status: http.StatusBadRequest},
+		{name: "move to another table", method: http.MethodPatch, path: "/orders/" + createdId, as: helpers.RoleWaiter, body: map[string]string{"table_id": patio.Table_id}, status: http.StatusOK, check: expectField("table_id", patio.Table_id)},
+		{name: "update unknown", method: http.MethodPatch, path: "/orders/missing", as: helpers.RoleWaiter, body: map[string]string{"status": models.OrderStatusSentToKitchen}, status: http.StatusNotFound},
+		{name: "send an empty order to the kitchen", method: http.MethodPatch, path: "/orders/" + createdId, token: waiter.token, body: map[string]string{"status": models.OrderStatusSentToKitchen}, status: http.StatusBadRequest},
+	})
+
+	s.seedOrderItem(t, createdId, s.seedFood(t, s.seedMenu(t).Menu_id, "Soup", 4.5))
+
+	s.run(t, []routeCase{
+		{name: "send to the kitchen", method: http.MethodPatch, path: "/orders/" + createdId, token: waiter.token, body: map[string]string{"status": models.OrderStatusSentToKitchen}, status: http.StatusOK},
+		{name: "serve", method: http.MethodPatch, path: "/orders/" + createdId, token: waiter.token, body: map[string]string{"status": models.OrderStatusServed}, status: http.StatusOK},
+		{name: "cancel a served order", method: http.MethodPost, path: "/orders/" + createdId + "/cancel", token: waiter.token, status: http.StatusConflict},
+		{name: "bill", method: http.MethodPatch, path: "/orders/" + createdId, token: waiter.token, body: map[string]string{"status": models.OrderStatusBilled}, status: http.StatusOK},
+		{name: "close", method: http.MethodPatch, path: "/orders/" + createdId, token: waiter.token, body: map[string]string{"status": models.OrderStatusClosed}, status: http.StatusOK, check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+			history, _ := decode(t, rec)["status_history"].([]interface{})
+
+			if len(history) != 5 {
+				t.Fatalf("history has %d entries, want 5", len(history))
+			}
+
+			last, _ := history[4].(map[string]interface{})
+
+			if last["status"] != models.OrderStatusClosed || last["changed_by"] != waiter.User_id || last["changed_at"] == nil {
+				t.Errorf("unexpected history entry: %v", last)
+			}
+		}},
+		{name: "reopen a closed order", method: http.MethodPatch, path: "/orders/" + createdId, as: helpers.RoleWaiter, body: map[string]string{"status": models.OrderStatusOpen}, status: http.StatusConflict},
+		{name: "list closed orders", method: http.MethodGet, path: "/orders?status=" + models.OrderStatusClosed, as: helpers.RoleWaiter, status: http.StatusOK, check: expectLength(1)},
+		{name: "cancel needs order:write", method: http.MethodPost, path: "/orders/" + order.Order_id + "/cancel", as: helpers.RoleCashier, status: http.StatusForbidden},
+		{name: "cancel unknown", method: http.MethodPost, path: "/orders/missing/cancel", as: helpers.RoleWaiter, status: http.StatusNotFound},
+		{name: "cancel", method: http.MethodPost, path: "/orders/" + order.Order_id + "/cancel", as: helpers.RoleWaiter, status: http.StatusOK, check: expectField("status", models.OrderStatusCancelled)},
+		{name: "cancel twice", method: http.MethodPost, path: "/orders/" + order.Order_id + "/cancel", as: helpers.RoleWaiter, status: http.StatusConflict},
+	})
+}
+
+func TestTableScopedApiKeySeesAndChangesOnlyItsOrders(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer(t)
+	menu := s.seedMenu(t)
+	soup := s.seedFood(t, menu.Menu_id, "Soup", 4.5)
+	own := s.seedTable(t, 1)
+	other := s.seedTable(t, 2)
+	ownOrder, ownItems := s.seedOrder(t, own.Table_id, soup)
+	otherOrder, otherItems := s.seedOrder(t, other.Table_id, soup)
+	key := s.seedApiKey(t, helpers.RoleUser, &own.Table_id)
+	otherItem := "/orderItems/" + otherItems[0].Order_item_id
+	ownInvoice := s.seedInvoice(t, ownOrder.Order_id)
+	otherInvoice := "/invoices/" + s.seedInvoice(t, otherOrder.Order_id).Invoice_id
+
+	onlyOwn := func(key string, want string) func(t *testing.T, rec *httptest.ResponseRecorder) {
+		return func(t *testing.T, rec *httptest.ResponseRecorder) {
+			t.Helper()
+
+			entries := decodeList(t, rec)
+
+			if len(entries) == 0 {
+				t.Error("nothing was listed")
+			}
+
+			for _, entry := range entries {
+				if entry[key] != want {
+					t.Errorf("listed %s %v of another table", key, entry[key])
+				}
+			}
+		}
+	}
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		body   interface{}
+		status int
+		check  func(t *testing.T, rec *httptest.ResponseRecorder)
+	}{
+		{name: "list orders", method: http.MethodGet, path: "/orders", status: http.StatusOK, check: onlyOwn("table_id", own.Table_id)},
+		{name: "get an order of another table", method: http.MethodGet, path: "/orders/" + otherOrder.Order_id, status: http.StatusForbidden},
+		{name: "get its own order", method: http.MethodGet, path: "/orders/" + ownOrder.Order_id, status: http.StatusOK},
+		{name: "list order items", method: http.MethodGet, path: "/orderItems", status: http.StatusOK, check: onlyOwn("order_id", ownOrder.Order_id)},
+		{name: "list the items of another table's order", method: http.MethodGet, path: "/orderItems-order/" + otherOrder.Order_id, status: http.StatusForbidden},
+		{name: "get an item of another table", method: http.MethodGet, path: otherItem, status: http.StatusForbidden},
+		{name: "get its own item", method: http.MethodGet, path: "/orderItems/" + ownItems[0].Order_item_id, status: http.StatusOK},
+		{name: "change an item of another table", method: http.MethodPatch, path: otherItem, body: map[string]int{"quantity": 2}, status: http.StatusForbidden},
+		{name: "delete an item of another table", method: http.MethodDelete, path: otherItem, status: http.StatusForbidden},
+		{name: "open an order for another table", method: http.MethodPost, path: "/orders", body: map[string]string{"table_id": other.Table_id}, status: http.StatusForbidden},
+		{name: "cancel an order of another table", method: http.MethodPost, path: "/orders/" + otherOrder.Order_id + "/cancel", status: http.StatusForbidden},
+		{name: "open an order for its own table", method: http.MethodPost, path: "/orders", body: map[string]string{"table_id": own.Table_id}, status: http.StatusOK},
+		{name: "list invoices", method: http.MethodGet, path: "/invoices", status: http.StatusOK, check: onlyOwn("order_id", ownOrder.Order_id)},
+		{name: "get an invoice of another table", method: http.MethodGet, path: otherInvoice, status: http.StatusForbidden},
+		{name: "get its own invoice", method: http.MethodGet, path: "/invoices/" + ownInvoice.Invoice_id, status: http.StatusOK},
+		{name: "change an invoice of another table", method: http.MethodPatch, path: otherInvoice, body: map[string]string{"payment_method": "CARD"}, status: http.StatusForbidden},
+		{name: "bill an order of another table", method: http.MethodPost, path: "/invoices", body: map[string]string{"order_id": otherOrder.Order_id}, status: http.StatusForbidden},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := s.request(tc.method, tc.path, "", tc.body, helpers.ApiKeyHeader, key)
+
+			if rec.Code != tc.status {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tc.status, rec.Body.String())
+			}
+
+			if tc.check != nil && rec.Code == tc.status {
+				tc.check(t, rec)
+			}
+		})
+	}
+}
+
+func TestAddOrderItems(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer(t)
+	menu := s.seedMenu(t)
+	soup := s.seedFood(t, menu.Menu_id, "Soup", 4.5)
+	cake := s.seedFood(t, menu.Menu_id, "Cake", 6)
+	order, _ := s.seedOrder(t, s.seedTable(t, 1).Table_id, soup)
+	cancelled, _ := s.seedOrder(t, s.seedTable(t, 2).Table_id, soup)
+	waiter := s.as(t, helpers.RoleWaiter)
+	path := "/orders/" + order.Order_id + "/items"
+
+	add := func(items ...map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"order_items": items}
+	}
+
+	prepStatus := func(want string) func(t *testing.T, rec *httptest.ResponseRecorder) {
+		return func(t *testing.T, rec *httptest.ResponseRecorder) {
+			t.Helper()
+
+			if items := decodeList(t, rec); len(items) != 1 || items[0]["prep_status"] != want {
+				t.Errorf("got %v, want one item with prep_status %q", items, want)
+			}
+		}
+	}
+
+	s.run(t, []routeCase{
+		{name: "add needs order:write", method: http.MethodPost, path: path, as: helpers.RoleChef, body: add(map[string]interface{}{"food_id": cake.Food_id, "quantity": 1}), status: http.StatusForbidden},
+		{name: "add to an unknown order", method: http.MethodPost, path: "/orders/missing/items", token: waiter.token, body: add(map[string]interface{}{"food_id": cake.Food_id, "quantity": 1}), status: http.StatusNotFound},
+		{name: "add nothing", method: http.MethodPost, path: path, token: waiter.token, body: add(), status: http.StatusBadRequest},
+		{name: "add an unknown food", method: http.MethodPost, path: path, token: waiter.token, body: add(map[string]interface{}{"food_id": "missing", "quantity": 1}), status: http.StatusBadRequest},
+		{name: "add to an open order", method: http.MethodPost, path: path, token: waiter.token, body: add(map[string]interface{}{"food_id": cake.Food_id, "quantity": 1}), status: http.StatusOK, check: prepStatus("")},
+		{name: "send to the kitchen", method: http.MethodPatch, path: "/orders/" + order.Order_id, token: waiter.token, body: map[string]string{"status": models.OrderStatusSentToKitchen}, status: http.StatusOK},
+		{name: "add a held course", method: http.MethodPost, path: path, token: waiter.token, body: add(map[string]interface{}{"food_id": cake.Food_id, "quantity": 1, "course": 2, "hold": true}), status: http.StatusOK, check: prepStatus(models.PrepStatusHeld)},
+		{name: "add an item that is sent right away", method: http.MethodPost, path: path, token: waiter.token, body: add(map[string]interface{}{"food_id": soup.Food_id, "quantity": 1}), status: http.StatusOK, check: prepStatus(models.PrepStatusQueued)},
+		{name: "the kitchen got the new item", method: http.MethodGet, path: "/kitchen/tickets", as: helpers.RoleChef, status: http.StatusOK, check: expectLength(2)},
+		{name: "fire the added course", method: http.MethodPost, path: "/orders/" + order.Order_id + "/courses/2/fire", token: waiter.token, status: http.StatusOK},
+		{name: "cancel the other order", method: http.MethodPost, path: "/orders/" + cancelled.Order_id + "/cancel", token: waiter.token, status: http.StatusOK},
+		{name: "add to a cancelled order", method: http.MethodPost, path: "/orders/" + cancelled.Order_id + "/items", token: waiter.token, body: add(map[string]interface{}{"food_id": cake.Food_id, "quantity": 1}), status: http.StatusConflict},
+	})
+}
+
+// failingTickets fails to store tickets while fail is set.
+type failingTickets struct {
+	repository.TicketRepository
+	fail bool
+}
+
+func (r *failingTickets) CreateMany(ctx context.Context, tickets []models.Ticket) error {
+	if r.fail {
+		return errors.New("tickets are unavailable")
+	}
+
+	return r.TicketRepository.CreateMany(ctx, tickets)
+}
+
+func TestFailedKitchenUpdateKeepsTheOrderStatus(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer(t)
+	menu := s.seedMenu(t)
+	soup := s.seedFood(t, menu.Menu_id, "Soup", 4.5)
+	order, _ := s.seedOrder(t, s.seedTable(t, 1).Table_id, soup)
+	waiter := s.as(t, helpers.RoleWaiter)
+	path := "/orders/" + order.Order_id
+	send := map[string]string{"status": models.OrderStatusSentToKitchen}
+
+	tickets := &failingTickets{TicketRepository: s.repos.Tickets, fail: true}
+	s.repos.Tickets = tickets
+
+	if rec := s.request(http.MethodPatch, path, waiter.token, send); rec.Code != http.StatusInternalServerError {
+		t.Fatalf("sending with a failing kitchen = %d, want 500", rec.Code)
+	}
+
+	s.run(t, []routeCase{
+		{name: "the order is still open", method: http.MethodGet, path: path, token: waiter.token, status: http.StatusOK, check: expectField("status", models.OrderStatusOpen)},
+	})
+
+	tickets.fail = false
+
+	s.run(t, []routeCase{
+		{name: "send again", method: http.MethodPatch, path: path, token: waiter.token, body: send, status: http.StatusOK, check: expectField("status", models.OrderStatusSentToKitchen)},
+		{name: "the kitchen got the order once", method: http.MethodGet, path: "/kitchen/tickets", as: helpers.RoleChef, status: http.StatusOK, check: expectLength(1)},
+	})
+}
+
+func TestFailedKitchenUpdateKeepsNoAddedItems(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer(t)
+	menu := s.seedMenu(t)
+	soup := s.seedFood(t, menu.Menu_id, "Soup", 4.5)
+	order, _ := s.seedOrder(t, s.seedTable(t, 1).Table_id, soup)
+	waiter := s.as(t, helpers.RoleWaiter)
+
+	s.run(t, []routeCase{
+		{name: "send to the kitchen", method: http.MethodPatch, path: "/orders/" + order.Order_id, token: waiter.token, body: map[string]string{"status": models.OrderStatusSentToKitchen}, status: http.StatusOK},
+	})
+
+	s.repos.Tickets = &failingTickets{TicketRepository: s.repos.Tickets, fail: true}
+
+	add := map[string]interface{}{"order_items": []map[string]interface{}{{"food_id": soup.Food_id, "quantity": 1}}}
+
+	if rec := s.request(http.MethodPost, "/orders/"+order.Order_id+"/items", waiter.token, add); rec.Code != http.StatusInternalServerError {
+		t.Fatalf("adding with a failing kitchen = %d, want 500", rec.Code)
+	}
+
+	items, err := s.repos.Orders.ListItemsByOrder(context.Background(), order.Order_id)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) != 1 {
+		t.Errorf("the order has %d items, want only the one it was sent with", len(items))
+	}
+}
@@ -0,0 +1,167 @@
+package routes
+
+import (
+	"golang-restaurant-management/helpers"
+	"golang-restaurant-management/middleware"
+	"golang-restaurant-management/repository"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Policy describes who may call a route. Non-public routes always require
+// authentication. On top of that the caller needs Permission or has to own
+// the resource named by the Owner path parameter; with neither set any
+// authenticated caller is allowed.
+type Policy struct {
+	Public     bool
+	Permission string
+	Owner      string
+}
+
+// routePolicies is keyed by "METHOD /path" using the path exactly as it was
+// registered with gin. Routes without an entry are denied.
+var routePolicies = map[string]Policy{
+	"GET /users":                         {Permission: helpers.PermUserRead},
+	"GET /users/:user_id":                {Permission: helpers.PermUserRead, Owner: "user_id"},
+	"POST /users/signup":                 {Public: true},
+	"POST /users/login":                  {Public: true},
+	"POST /users/login/mfa":              {Public: true},
+	"POST /users/login/mfa/enroll":       {Public: true},
+	"POST /users/mfa/enroll":             {},
+	"POST /users/mfa/activate":           {},
+	"DELETE /users/mfa":                  {},
+	"POST /users/pin":                    {},
+	"GET /users/sessions":                {},
+	"DELETE /users/sessions":             {},
+	"DELETE /users/sessions/:session_id": {},
+	"POST /users/refresh":                {Public: true},
+	"POST /users/logout":                 {},
+
+	"POST /users/password-reset/request": {Public: true},
+	"POST /users/password-reset/confirm": {Public: true},
+	"POST /users/verify-email/request":   {},
+	"POST /users/verify-email/confirm":   {Public: true},
+	"POST /users/:user_id/logout":        {Permission: helpers.PermUserManage},
+	"POST /users/:user_id/unlock":        {Permission: helpers.PermUserManage},
+	"POST /users":                        {Permission: helpers.PermUserManage},
+	"PATCH /users/:user_id":              {Permission: helpers.PermUserManage, Owner: "user_id"},
+	"PATCH /users/:user_id/password":     {Owner: "user_id"},
+	"PATCH /users/:user_id/role":         {Permission: helpers.PermUserManage},
+	"DELETE /users/:user_id":             {Permission: helpers.PermUserManage},
+	"POST /users/:user_id/reactivate":    {Permission: helpers.PermUserManage},
+
+	"GET /foods":             {},
+	"GET /foods/:food_id":    {},
+	"POST /foods":            {Permission: helpers.PermFoodWrite},
+	"PATCH /foods/:food_id":  {Permission: helpers.PermFoodWrite},
+	"DELETE /foods/:food_id": {Permission: helpers.PermFoodWrite},
+
+	"GET /menus":             {},
+	"GET /menus/:menu_id":    {},
+	"POST /menus":            {Permission: helpers.PermMenuWrite},
+	"PATCH /menus/:menu_id":  {Permission: helpers.PermMenuWrite},
+	"DELETE /menus/:menu_id": {Permission: helpers.PermMenuWrite},
+
+	"GET /tables":              {Permission: helpers.PermTableRead},
+	"GET /tables/:table_id":    {Permission: helpers.PermTableRead},
+	"POST /tables":             {Permission: helpers.PermTableWrite},
+	"PATCH /tables/:table_id":  {Permission: helpers.PermTableWrite},
+	"DELETE /tables/:table_id": {Permission: helpers.PermTableWrite},
+
+	"GET /orders":                                 {Permission: helpers.PermOrderRead},
+	"GET /orders/:order_id":                       {Permission: helpers.PermOrderRead},
+	"POST /orders":                                {Permission: helpers.PermOrderWrite},
+	"PATCH /orders/:order_id":                     {Permission: helpers.PermOrderWrite},
+	"POST /orders/:order_id/items":                {Permission: helpers.PermOrderWrite},
+	"POST /orders/:order_id/cancel":               {Permission: helpers.PermOrderWrite},
+	"POST /orders/:order_id/courses/:course/fire": {Permission: helpers.PermOrderWrite},
+
+	"GET /orderItems":                  {Permission: helpers.PermOrderRead},
+	"GET /orderItems/:orderItem_id":    {Permission: helpers.PermOrderRead},
+	"GET /orderItems-order/:order_id":  {Permission: helpers.PermOrderRead},
+	"POST /orderItems":                 {Permission: helpers.PermOrderWrite},
+	"PATCH /orderItems/:orderItem_id":  {Permission: helpers.PermOrderWrite},
+	"DELETE /orderItems/:orderItem_id": {Permission: helpers.PermOrderWrite},
+	// Voids and comps also need a manager's approval, checked by the handler.
+	"POST /orderItems/:orderItem_id/void": {Permission: helpers.PermOrderWrite},
+	"POST /orderItems/:orderItem_id/comp": {Permission: helpers.PermOrderWrite},
+
+	"GET /kitchen/tickets":                    {Permission: helpers.PermOrderRead},
+	"GET /kitchen/tickets/:ticket_id":         {Permission: helpers.PermOrderRead},
+	"POST /kitchen/tickets/:ticket_id/start":  {Permission: helpers.PermKitchenBump},
+	"POST /kitchen/tickets/:ticket_id/bump":   {Permission: helpers.PermKitchenBump},
+	"POST /kitchen/tickets/:ticket_id/recall": {Permission: helpers.PermKitchenBump},
+
+	"GET /invoices":                {Permission: helpers.PermInvoiceRead},
+	"GET /invoices/:invoice_id":    {Permission: helpers.PermInvoiceRead},
+	"POST /invoices":               {Permission: helpers.PermInvoiceWrite},
+	"PATCH /invoices/:invoice_id":  {Permission: helpers.PermInvoiceWrite},
+	"DELETE /invoices/:invoice_id": {Permission: helpers.PermInvoiceDelete},
+
+	// Each topic of the stream is checked against its own permission.
+	"GET /events": {},
+
+	"GET /apikeys":                {Permission: helpers.PermApiKeyManage},
+	"POST /apikeys":               {Permission: helpers.PermApiKeyManage},
+	"DELETE /apikeys/:api_key_id": {Permission: helpers.PermApiKeyManage},
+
+	"GET /invitations":                   {Permission: helpers.PermUserInvite},
+	"POST /invitations":                  {Permission: helpers.PermUserInvite},
+	"DELETE /invitations/:invitation_id": {Permission: helpers.PermUserInvite},
+	"POST /invitations/accept":           {Public: true},
+
+	"POST /terminal/pin-login": {},
+
+	"GET /.well-known/jwks.json": {Public: true},
+}
+
+func policyKey(method string, path string) string {
+	return method + " " + path
+}
+
+// Authorize enforces routePolicies. It has to be installed on the engine
+// before any route is registered so that it runs for every route.
+func Authorize(repos *repository.Repositories) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.FullPath() == "" {
+			c.Next()
+			return
+		}
+
+		policy, ok := routePolicies[policyKey(c.Request.Method, c.FullPath())]
+
+		if !ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "No access policy is defined for this route!"})
+			c.Abort()
+			return
+		}
+
+		if policy.Public {
+			c.Next()
+			return
+		}
+
+		if !middleware.Authenticate(c, repos) {
+			return
+		}
+
+		if policy.Owner != "" && c.Param(policy.Owner) == c.GetString("uid") {
+			c.Next()
+			return
+		}
+
+		if policy.Permission != "" {
+			middleware.RequirePermission(policy.Permission)(c)
+			return
+		}
+
+		if policy.Owner != "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only access your own account!"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
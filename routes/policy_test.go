@@ -0,0 +1,32 @@
+package routes
+
+import (
+	"golang-restaurant-management/repository"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestEveryRouteHasPolicy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	Register(router, repository.NewMemoryRepositories())
+
+	registered := map[string]bool{}
+
+	for _, route := range router.Routes() {
+		key := policyKey(route.Method, route.Path)
+		registered[key] = true
+
+		if _, ok := routePolicies[key]; !ok {
+			t.Errorf("route %s has no access policy", key)
+		}
+	}
+
+	for key := range routePolicies {
+		if !registered[key] {
+			t.Errorf("policy %s does not match any registered route", key)
+		}
+	}
+}
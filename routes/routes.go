@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"golang-restaurant-management/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Register installs the access policy and every route group on the router.
+func Register(router *gin.Engine, repos *repository.Repositories) {
+	router.Use(Authorize(repos))
+
+	UserRoutes(router, repos)
+	FoodRoutes(router, repos)
+	MenuRoutes(router, repos)
+	TableRoutes(router, repos)
+	OrderRoutes(router, repos)
+	OrderItemsRoutes(router, repos)
+	KitchenRoutes(router, repos)
+	InvoiceRoutes(router, repos)
+	EventRoutes(router, repos)
+	ApiKeyRoutes(router, repos)
+	InvitationRoutes(router, repos)
+	TerminalRoutes(router, repos)
+	JwksRoutes(router)
+}
@@ -0,0 +1,585 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"golang-restaurant-management/helpers"
+	"golang-restaurant-management/models"
+	"golang-restaurant-management/repository"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// The route tests run the full router, access policy included, on top of
+// repository.NewMemoryRepositories. Every route the router registers has to
+// be hit by at least one of them; TestMain fails the run otherwise.
+
+const testPassword = "secret-password"
+
+var exercisedRoutes sync.Map
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+
+	helpers.SECRET_KEY = "route-test-secret"
+	helpers.DefaultMailer = testMailer
+
+	code := m.Run()
+
+	if code == 0 && flag.Lookup("test.run").Value.String() == "" {
+		code = checkEveryRouteExercised()
+	}
+
+	os.Exit(code)
+}
+
+func checkEveryRouteExercised() int {
+	router := gin.New()
+	Register(router, repository.NewMemoryRepositories())
+
+	var missing []string
+
+	for _, route := range router.Routes() {
+		key := policyKey(route.Method, route.Path)
+
+		if _, ok := exercisedRoutes.Load(key); !ok {
+			missing = append(missing, key)
+		}
+	}
+
+	if len(missing) == 0 {
+		return 0
+	}
+
+	sort.Strings(missing)
+	fmt.Println("routes without a test:\n\t" + strings.Join(missing, "\n\t"))
+
+	return 1
+}
+
+// captureMailer keeps every message so tests can read the links they carry.
+type captureMailer struct {
+	mu       sync.Mutex
+	messages []helpers.MailMessage
+}
+
+var testMailer = &captureMailer{}
+
+func (m *captureMailer) Send(msg helpers.MailMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = append(m.messages, msg)
+
+	return nil
+}
+
+// tokenSentTo returns the token of the newest link mailed to the address.
+// Some mails are sent in the background, so it waits for a moment before
+// giving up.
+func (m *captureMailer) tokenSentTo(t *testing.T, to string) string {
+	t.Helper()
+
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if token, found := m.newestToken(to); found {
+			return token
+		}
+	}
+
+	t.Fatalf("no link was mailed to %s", to)
+
+	return ""
+}
+
+func (m *captureMailer) newestToken(to string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		msg := m.messages[i]
+
+		if msg.To != to {
+			continue
+		}
+
+		if index := strings.Index(msg.Body, "?token="); index >= 0 {
+			return strings.TrimSpace(msg.Body[index+len("?token="):]), true
+		}
+	}
+
+	return "", false
+}
+
+type testServer struct {
+	router *gin.Engine
+	repos  *repository.Repositories
+	users  map[string]testUser
+}
+
+type testUser struct {
+	models.User
+	token        string
+	refreshToken string
+	sessionId    string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	s := &testServer{
+		router: gin.New(),
+		repos:  repository.NewMemoryRepositories(),
+		users:  map[string]testUser{},
+	}
+
+	s.router.Use(func(c *gin.Context) {
+		if c.FullPath() != "" {
+			exercisedRoutes.Store(policyKey(c.Request.Method, c.FullPath()), true)
+		}
+
+		c.Next()
+	})
+
+	Register(s.router, s.repos)
+
+	return s
+}
+
+var userSequence int64
+
+// seedUser stores a user with testPassword and logs it in on a new session.
+// The password is hashed with the minimum bcrypt cost to keep tests fast.
+func (s *testServer) seedUser(t *testing.T, role string) testUser {
+	t.Helper()
+
+	n := atomic.AddInt64(&userSequence, 1)
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	password := string(hash)
+	firstName := "Test"
+	lastName := strings.ToLower(role)
+	email := fmt.Sprintf("%s%d@example.com", strings.ToLower(role), n)
+	phone := fmt.Sprintf("555-%04d", n)
+
+	var user models.User
+
+	user.ID = primitive.NewObjectID()
+	user.User_id = user.ID.Hex()
+	user.First_name = &firstName
+	user.Last_name = &lastName
+	user.Email = &email
+	user.Phone = &phone
+	user.Password = &password
+	user.User_type = &role
+	user.Created_at = time.Now()
+	user.Updated_at = user.Created_at
+
+	if err := s.repos.Users.Create(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+
+	return s.login(t, user)
+}
+
+// login starts a session for the user the same way a password login does.
+func (s *testServer) login(t *testing.T, user models.User) testUser {
+	t.Helper()
+
+	var session models.Session
+
+	session.ID = primitive.NewObjectID()
+	session.Session_id = session.ID.Hex()
+	session.User_id = user.User_id
+	session.Created_at = time.Now()
+	session.Last_seen_at = session.Created_at
+	session.Expires_at = session.Created_at.Add(helpers.RefreshTokenLifetime)
+
+	token, refreshToken, err := helpers.GenerateTokensForFamily(*user.Email, *user.First_name, *user.Last_name, user.User_id, *user.User_type, session.Session_id)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session.Refresh_token_hash = helpers.HashOpaqueToken(refreshToken)
+
+	if err := s.repos.Sessions.Create(context.Background(), session); err != nil {
+		t.Fatal(err)
+	}
+
+	return testUser{User: user, token: token, refreshToken: refreshToken, sessionId: session.Session_id}
+}
+
+// as returns the shared user of the role, creating it on first use.
+func (s *testServer) as(t *testing.T, role string) testUser {
+	t.Helper()
+
+	if user, ok := s.users[role]; ok {
+		return user
+	}
+
+	user := s.seedUser(t, role)
+	s.users[role] = user
+
+	return user
+}
+
+func (s *testServer) seedMenu(t *testing.T) models.Menu {
+	t.Helper()
+
+	var menu models.Menu
+
+	menu.ID = primitive.NewObjectID()
+	menu.Menu_id = menu.ID.Hex()
+	menu.Name = "Dinner"
+	menu.Category = "Main"
+
+	if err := s.repos.Menus.Create(context.Background(), menu); err != nil {
+		t.Fatal(err)
+	}
+
+	return menu
+}
+
+func (s *testServer) seedFood(t *testing.T, menuId string, name string, price float64) models.Food {
+	t.Helper()
+
+	var food models.Food
+
+	image := "https://example.com/" + name + ".png"
+
+	food.ID = primitive.NewObjectID()
+	food.Food_id = food.ID.Hex()
+	food.Name = &name
+	food.Price = &price
+	food.Food_image = &image
+	food.Menu_id = &menuId
+
+	if err := s.repos.Foods.Create(context.Background(), food); err != nil {
+		t.Fatal(err)
+	}
+
+	return food
+}
+
+func (s *testServer) seedTable(t *testing.T, number int) models.Table {
+	t.Helper()
+
+	var table models.Table
+
+	guests := 4
+
+	table.ID = primitive.NewObjectID()
+	table.Table_id = table.ID.Hex()
+	table.Table_number = &number
+	table.Number_of_guests = &guests
+
+	if err := s.repos.Tables.Create(context.Background(), table); err != nil {
+		t.Fatal(err)
+	}
+
+	return table
+}
+
+// seedOrder stores an order for the table with one item per food.
+func (s *testServer) seedOrder(t *testing.T, tableId string, foods ...models.Food) (models.Order, []models.OrderItem) {
+	t.Helper()
+
+	var order models.Order
+
+	order.ID = primitive.NewObjectID()
+	order.Order_id = order.ID.Hex()
+	order.Table_id = &tableId
+	order.Order_Date = time.Now()
+	order.Status = models.OrderStatusOpen
+
+	if err := s.repos.Orders.Create(context.Background(), order); err != nil {
+		t.Fatal(err)
+	}
+
+	var items []models.OrderItem
+
+	for _, food := range foods {
+		var item models.OrderItem
+
+		quantity := 1
+
+		item.ID = primitive.NewObjectID()
+		item.Order_item_id = item.ID.Hex()
+		item.Order_id = order.Order_id
+		item.Food_id = &food.Food_id
+		item.Unit_price = food.Price
+		item.Quantity = &quantity
+
+		items = append(items, item)
+	}
+
+	if len(items) > 0 {
+		if err := s.repos.Orders.CreateItems(context.Background(), items); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return order, items
+}
+
+// seedOrderItem adds one portion of the food to an existing order.
+func (s *testServer) seedOrderItem(t *testing.T, orderId string, food models.Food) models.OrderItem {
+	t.Helper()
+
+	var item models.OrderItem
+
+	quantity := 1
+
+	item.ID = primitive.NewObjectID()
+	item.Order_item_id = item.ID.Hex()
+	item.Order_id = orderId
+	item.Food_id = &food.Food_id
+	item.Unit_price = food.Price
+	item.Quantity = &quantity
+
+	if err := s.repos.Orders.CreateItems(context.Background(), []models.OrderItem{item}); err != nil {
+		t.Fatal(err)
+	}
+
+	return item
+}
+
+func (s *testServer) seedInvoice(t *testing.T, orderId string) models.Invoice {
+	t.Helper()
+
+	var invoice models.Invoice
+
+	status := "PENDING"
+
+	invoice.ID = primitive.NewObjectID()
+	invoice.Invoice_id = invoice.ID.Hex()
+	invoice.Order_id = orderId
+	invoice.Payment_status = &status
+	invoice.Payment_due_date = time.Now().AddDate(0, 0, 1)
+
+	if err := s.repos.Invoices.Create(context.Background(), invoice); err != nil {
+		t.Fatal(err)
+	}
+
+	return invoice
+}
+
+// request sends body as JSON, or verbatim when it is a string, with the
+// token as bearer credentials unless it is empty.
+func (s *testServer) request(method string, path string, token string, body interface{}, headers ...string) *httptest.ResponseRecorder {
+	var payload []byte
+
+	switch b := body.(type) {
+	case nil:
+	case string:
+		payload = []byte(b)
+	default:
+		payload, _ = json.Marshal(b)
+	}
+
+	req := httptest.NewRequest(method, path, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+
+	return rec
+}
+
+// routeCase is one request of a table-driven route test. as names the role
+// of the caller, or is empty for an anonymous request. check, if set, runs
+// on the response after the status matched.
+type routeCase struct {
+	name   string
+	method string
+	path   string
+	as     string
+	token  string
+	body   interface{}
+	status int
+	check  func(t *testing.T, rec *httptest.ResponseRecorder)
+}
+
+// run executes the cases in order against the same server, so later cases
+// see the effects of earlier ones.
+func (s *testServer) run(t *testing.T, cases []routeCase) {
+	t.Helper()
+
+	for _, tc := range cases {
+		token := tc.token
+
+		if token == "" && tc.as != "" {
+			token = s.as(t, tc.as).token
+		}
+
+		rec := s.request(tc.method, tc.path, token, tc.body)
+
+		if rec.Code != tc.status {
+			t.Errorf("%s: %s %s = %d, want %d: %s", tc.name, tc.method, tc.path, rec.Code, tc.status, rec.Body.String())
+			continue
+		}
+
+		if tc.check != nil {
+			tc.check(t, rec)
+		}
+	}
+}
+
+func decode(t *testing.T, rec *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+
+	var body map[string]interface{}
+
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("response is not a JSON object: %s", rec.Body.String())
+	}
+
+	return body
+}
+
+func decodeList(t *testing.T, rec *httptest.ResponseRecorder) []map[string]interface{} {
+	t.Helper()
+
+	var body []map[string]interface{}
+
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("response is not a JSON array: %s", rec.Body.String())
+	}
+
+	return body
+}
+
+// expectField checks a top-level field of a JSON object response.
+func expectField(key string, want interface{}) func(t *testing.T, rec *httptest.ResponseRecorder) {
+	return func(t *testing.T, rec *httptest.ResponseRecorder) {
+		t.Helper()
+
+		if got := decode(t, rec)[key]; fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%s = %v, want %v", key, got, want)
+		}
+	}
+}
+
+// decodeSummary returns the order summary of a GET /orderItems-order
+// response, which is a list with one entry per order.
+func decodeSummary(t *testing.T, rec *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+
+	list := decodeList(t, rec)
+
+	if len(list) != 1 {
+		t.Fatalf("got %d order summaries, want 1: %s", len(list), rec.Body.String())
+	}
+
+	return list[0]
+}
+
+// expectSummaryField checks a field of an order summary response.
+func expectSummaryField(key string, want interface{}) func(t *testing.T, rec *httptest.ResponseRecorder) {
+	return func(t *testing.T, rec *httptest.ResponseRecorder) {
+		t.Helper()
+
+		if got := decodeSummary(t, rec)[key]; fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%s = %v, want %v", key, got, want)
+		}
+	}
+}
+
+// expectNoCredentials checks that a user response carries neither the
+// password hash nor any tokens.
+func expectNoCredentials(t *testing.T, rec *httptest.ResponseRecorder) {
+	t.Helper()
+
+	user := decode(t, rec)
+	for _, key := range []string{"Password", "token", "refresh_token"} {
+		if user[key] != nil {
+			t.Errorf("%s = %v, want nothing", key, user[key])
+		}
+	}
+}
+
+func expectLength(want int) func(t *testing.T, rec *httptest.ResponseRecorder) {
+	return func(t *testing.T, rec *httptest.ResponseRecorder) {
+		t.Helper()
+
+		if got := len(decodeList(t, rec)); got != want {
+			t.Errorf("got %d entries, want %d", got, want)
+		}
+	}
+}
+
+// expectPageSize checks the length of a list nested in a paginated response.
+func expectPageSize(key string, want int) func(t *testing.T, rec *httptest.ResponseRecorder) {
+	return func(t *testing.T, rec *httptest.ResponseRecorder) {
+		t.Helper()
+
+		items, _ := decode(t, rec)[key].([]interface{})
+
+		if len(items) != want {
+			t.Errorf("%s has %d entries, want %d", key, len(items), want)
+		}
+	}
+}
+
+// seedApiKey stores an active device key and returns its plaintext.
+func (s *testServer) seedApiKey(t *testing.T, role string, tableId *string) string {
+	t.Helper()
+
+	return s.seedScopedApiKey(t, role, tableId, nil)
+}
+
+// seedScopedApiKey stores an active device key limited to a table and/or a
+// kitchen station and returns its plaintext.
+func (s *testServer) seedScopedApiKey(t *testing.T, role string, tableId *string, station *string) string {
+	t.Helper()
+
+	key, prefix, err := helpers.GenerateApiKey()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var apiKey models.ApiKey
+
+	name := "Terminal"
+
+	apiKey.ID = primitive.NewObjectID()
+	apiKey.Api_key_id = apiKey.ID.Hex()
+	apiKey.Name = &name
+	apiKey.Role = &role
+	apiKey.Table_id = tableId
+	apiKey.Station = station
+	apiKey.Prefix = prefix
+	apiKey.Key_hash = helpers.HashApiKey(key)
+
+	if err := s.repos.ApiKeys.Create(context.Background(), apiKey); err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
@@ -2,14 +2,15 @@ package routes
 
 import (
 	controllers "golang-restaurant-management/controllers"
+	"golang-restaurant-management/repository"
 
 	"github.com/gin-gonic/gin"
 )
 
-func TableRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.GET("/tables", controllers.GetTables())
-	incomingRoutes.GET("/tables/:table_id", controllers.GetTable())
-	incomingRoutes.POST("/tables", controllers.CreateTable())
-	incomingRoutes.PATCH("/tables/:table_id", controllers.UpdateTable())
-	incomingRoutes.DELETE("/tables/:table_id", controllers.DeleteTable())
+func TableRoutes(incomingRoutes *gin.Engine, repos *repository.Repositories) {
+	incomingRoutes.GET("/tables", controllers.GetTables(repos))
+	incomingRoutes.GET("/tables/:table_id", controllers.GetTable(repos))
+	incomingRoutes.POST("/tables", controllers.CreateTable(repos))
+	incomingRoutes.PATCH("/tables/:table_id", controllers.UpdateTable(repos))
+	incomingRoutes.DELETE("/tables/:table_id", controllers.DeleteTable(repos))
 }
@@ -0,0 +1,62 @@
+package routes
+
+import (
+	"golang-restaurant-management/helpers"
+	"net/http"
+	"testing"
+)
+
+func TestTableRoutes(t *testing.T) {
+	s := newTestServer(t)
+	table := s.seedTable(t, 1)
+
+	s.run(t, []routeCase{
+		{name: "list requires a login", method: http.MethodGet, path: "/tables", status: http.StatusUnauthorized},
+		{name: "list needs table:read", method: http.MethodGet, path: "/tables", as: helpers.RoleUser, status: http.StatusForbidden},
+		{name: "list", method: http.MethodGet, path: "/tables", as: helpers.RoleHost, status: http.StatusOK, check: expectLength(1)},
+		{name: "get", method: http.MethodGet, path: "/tables/" + table.Table_id, as: helpers.RoleWaiter, status: http.StatusOK, check: expectField("table_number", 1)},
+		{name: "get unknown", method: http.MethodGet, path: "/tables/missing", as: helpers.RoleWaiter, status: http.StatusNotFound},
+		{name: "create needs table:write", method: http.MethodPost, path: "/tables", as: helpers.RoleWaiter, body: map[string]int{"table_number": 2, "number_of_guests": 2}, status: http.StatusForbidden},
+		{name: "create without guests", method: http.MethodPost, path: "/tables", as: helpers.RoleHost, body: map[string]int{"table_number": 2}, status: http.StatusBadRequest},
+		{name: "create", method: http.MethodPost, path: "/tables", as: helpers.RoleHost, body: map[string]int{"table_number": 2, "number_of_guests": 2}, status: http.StatusOK, check: expectField("table_number", 2)},
+		{name: "update", method: http.MethodPatch, path: "/tables/" + table.Table_id, as: helpers.RoleHost, body: map[string]int{"number_of_guests": 6}, status: http.StatusOK, check: expectField("number_of_guests", 6)},
+		{name: "update unknown", method: http.MethodPatch, path: "/tables/missing", as: helpers.RoleHost, body: map[string]int{"number_of_guests": 6}, status: http.StatusNotFound},
+		{name: "delete", method: http.MethodDelete, path: "/tables/" + table.Table_id, as: helpers.RoleManager, status: http.StatusOK},
+		{name: "delete again", method: http.MethodDelete, path: "/tables/" + table.Table_id, as: helpers.RoleManager, status: http.StatusNotFound},
+	})
+}
+
+func TestTableScopedApiKeySeesOnlyItsTable(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer(t)
+	own := s.seedTable(t, 1)
+	other := s.seedTable(t, 2)
+	key := s.seedApiKey(t, helpers.RoleHost, &own.Table_id)
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		body   interface{}
+		status int
+	}{
+		{name: "get another table", method: http.MethodGet, path: "/tables/" + other.Table_id, status: http.StatusForbidden},
+		{name: "get its own table", method: http.MethodGet, path: "/tables/" + own.Table_id, status: http.StatusOK},
+		{name: "change another table", method: http.MethodPatch, path: "/tables/" + other.Table_id, body: map[string]int{"number_of_guests": 6}, status: http.StatusForbidden},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if rec := s.request(tc.method, tc.path, "", tc.body, helpers.ApiKeyHeader, key); rec.Code != tc.status {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tc.status, rec.Body.String())
+			}
+		})
+	}
+
+	rec := s.request(http.MethodGet, "/tables", "", nil, helpers.ApiKeyHeader, key)
+
+	if tables := decodeList(t, rec); len(tables) != 1 || tables[0]["table_id"] != own.Table_id {
+		t.Errorf("listed %v, want only its own table", tables)
+	}
+}
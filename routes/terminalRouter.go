@@ -0,0 +1,12 @@
+package routes
+
+import (
+	controllers "golang-restaurant-management/controllers"
+	"golang-restaurant-management/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TerminalRoutes(incomingRoutes *gin.Engine, repos *repository.Repositories) {
+	incomingRoutes.POST("/terminal/pin-login", controllers.PinLogin(repos))
+}
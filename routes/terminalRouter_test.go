@@ -0,0 +1,100 @@
+package routes
+
+import (
+	"context"
+	"golang-restaurant-management/helpers"
+	"net/http"
+	"testing"
+)
+
+func TestPinLogin(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer(t)
+	waiter := s.seedUser(t, helpers.RoleWaiter)
+	key := s.seedApiKey(t, helpers.RoleWaiter, nil)
+
+	if rec := s.request(http.MethodPost, "/users/pin", waiter.token, map[string]string{"password": testPassword, "pin": "4321"}); rec.Code != http.StatusOK {
+		t.Fatalf("setting the PIN = %d, want 200", rec.Code)
+	}
+
+	cases := []struct {
+		name   string
+		token  string
+		key    string
+		body   interface{}
+		status int
+	}{
+		{name: "without a terminal", body: map[string]string{"user_id": waiter.User_id, "pin": "4321"}, status: http.StatusUnauthorized},
+		{name: "with a user token", token: waiter.token, body: map[string]string{"user_id": waiter.User_id, "pin": "4321"}, status: http.StatusForbidden},
+		{name: "with a malformed PIN", key: key, body: map[string]string{"user_id": waiter.User_id, "pin": "12"}, status: http.StatusBadRequest},
+		{name: "with a wrong PIN", key: key, body: map[string]string{"user_id": waiter.User_id, "pin": "0000"}, status: http.StatusUnauthorized},
+		{name: "for an unknown user", key: key, body: map[string]string{"user_id": "missing", "pin": "4321"}, status: http.StatusUnauthorized},
+		{name: "with the right PIN", key: key, body: map[string]string{"user_id": waiter.User_id, "pin": "4321"}, status: http.StatusOK},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var headers []string
+
+			if tc.key != "" {
+				headers = []string{helpers.ApiKeyHeader, tc.key}
+			}
+
+			rec := s.request(http.MethodPost, "/terminal/pin-login", tc.token, tc.body, headers...)
+
+			if rec.Code != tc.status {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tc.status, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestPinLoginLimits(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer(t)
+	waiter := s.seedUser(t, helpers.RoleWaiter)
+	protected := s.seedUser(t, helpers.RoleWaiter)
+	table := s.seedTable(t, 1)
+	other := s.seedTable(t, 2)
+	key := s.seedApiKey(t, helpers.RoleWaiter, &table.Table_id)
+
+	for _, user := range []testUser{waiter, protected} {
+		if rec := s.request(http.MethodPost, "/users/pin", user.token, map[string]string{"password": testPassword, "pin": "4321"}); rec.Code != http.StatusOK {
+			t.Fatalf("setting the PIN = %d, want 200", rec.Code)
+		}
+	}
+
+	stored, err := s.repos.Users.Get(context.Background(), protected.User_id)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored.Mfa_enabled = true
+
+	if err := s.repos.Users.Update(context.Background(), stored, "mfa_enabled"); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec := s.request(http.MethodPost, "/terminal/pin-login", "", map[string]string{"user_id": protected.User_id, "pin": "4321"}, helpers.ApiKeyHeader, key); rec.Code != http.StatusForbidden {
+		t.Errorf("PIN login with MFA enabled = %d, want 403", rec.Code)
+	}
+
+	rec := s.request(http.MethodPost, "/terminal/pin-login", "", map[string]string{"user_id": waiter.User_id, "pin": "4321"}, helpers.ApiKeyHeader, key)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PIN login = %d, want 200", rec.Code)
+	}
+
+	token, _ := decode(t, rec)["token"].(string)
+
+	if rec := s.request(http.MethodPost, "/orders", token, map[string]string{"table_id": other.Table_id}, helpers.ApiKeyHeader, key); rec.Code != http.StatusForbidden {
+		t.Errorf("ordering for another table = %d, want 403", rec.Code)
+	}
+
+	if rec := s.request(http.MethodPost, "/orders", token, map[string]string{"table_id": table.Table_id}, helpers.ApiKeyHeader, key); rec.Code != http.StatusOK {
+		t.Errorf("ordering for the terminal's table = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -2,13 +2,37 @@ package routes
 
 import (
 	controllers "golang-restaurant-management/controllers"
+	"golang-restaurant-management/repository"
 
 	"github.com/gin-gonic/gin"
 )
 
-func UserRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.GET("/users", controllers.GetUsers())
-	incomingRoutes.GET("/users/:user_id", controllers.GetUser())
-	incomingRoutes.POST("/users/signup", controllers.SignUp())
-	incomingRoutes.POST("/users/login", controllers.Login())
+func UserRoutes(incomingRoutes *gin.Engine, repos *repository.Repositories) {
+	incomingRoutes.GET("/users", controllers.GetUsers(repos))
+	incomingRoutes.GET("/users/:user_id", controllers.GetUser(repos))
+	incomingRoutes.POST("/users", controllers.CreateUser(repos))
+	incomingRoutes.PATCH("/users/:user_id", controllers.UpdateUser(repos))
+	incomingRoutes.PATCH("/users/:user_id/password", controllers.ChangePassword(repos))
+	incomingRoutes.PATCH("/users/:user_id/role", controllers.UpdateUserRole(repos))
+	incomingRoutes.DELETE("/users/:user_id", controllers.DeactivateUser(repos))
+	incomingRoutes.POST("/users/:user_id/reactivate", controllers.ReactivateUser(repos))
+	incomingRoutes.POST("/users/signup", controllers.SignUp(repos))
+	incomingRoutes.POST("/users/login", controllers.Login(repos))
+	incomingRoutes.POST("/users/login/mfa", controllers.LoginMfa(repos))
+	incomingRoutes.POST("/users/login/mfa/enroll", controllers.EnrollMfaAtLogin(repos))
+	incomingRoutes.POST("/users/mfa/enroll", controllers.EnrollMfa(repos))
+	incomingRoutes.POST("/users/mfa/activate", controllers.ActivateMfa(repos))
+	incomingRoutes.DELETE("/users/mfa", controllers.DisableMfa(repos))
+	incomingRoutes.POST("/users/pin", controllers.SetPin(repos))
+	incomingRoutes.GET("/users/sessions", controllers.GetSessions(repos))
+	incomingRoutes.DELETE("/users/sessions", controllers.RevokeOtherSessions(repos))
+	incomingRoutes.DELETE("/users/sessions/:session_id", controllers.RevokeSession(repos))
+	incomingRoutes.POST("/users/refresh", controllers.Refresh(repos))
+	incomingRoutes.POST("/users/logout", controllers.Logout(repos))
+	incomingRoutes.POST("/users/password-reset/request", controllers.RequestPasswordReset(repos))
+	incomingRoutes.POST("/users/password-reset/confirm", controllers.ConfirmPasswordReset(repos))
+	incomingRoutes.POST("/users/verify-email/request", controllers.RequestEmailVerification(repos))
+	incomingRoutes.POST("/users/verify-email/confirm", controllers.ConfirmEmailVerification(repos))
+	incomingRoutes.POST("/users/:user_id/logout", controllers.ForceLogout(repos))
+	incomingRoutes.POST("/users/:user_id/unlock", controllers.UnlockUser(repos))
 }
@@ -0,0 +1,393 @@
+package routes
+
+import (
+	"context"
+	"golang-restaurant-management/helpers"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestUserManagementRoutes(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer(t)
+	admin := s.as(t, helpers.RoleAdmin)
+	waiter := s.seedUser(t, helpers.RoleWaiter)
+	other := s.seedUser(t, helpers.RoleUser)
+	promoted := s.seedUser(t, helpers.RoleUser)
+	leaver := s.seedUser(t, helpers.RoleCashier)
+
+	newStaff := map[string]string{
+		"first_name": "New", "last_name": "Staff", "email": "new.staff@example.com",
+		"Password": "password", "phone": "555-9000", "user_type": helpers.RoleChef,
+	}
+
+	duplicate := map[string]string{
+		"first_name": "Copy", "last_name": "Cat", "email": *waiter.Email,
+		"Password": "password", "phone": "555-9001", "user_type": helpers.RoleChef,
+	}
+
+	s.run(t, []routeCase{
+		{name: "list requires a login", method: http.MethodGet, path: "/users", status: http.StatusUnauthorized},
+		{name: "list needs user:read", method: http.MethodGet, path: "/users", token: waiter.token, status: http.StatusForbidden},
+		{name: "list", method: http.MethodGet, path: "/users", as: helpers.RoleManager, status: http.StatusOK, check: expectField("total_count", 6)},
+		{name: "get own account", method: http.MethodGet, path: "/users/" + waiter.User_id, token: waiter.token, status: http.StatusOK, check: expectField("email", *waiter.Email)},
+		{name: "get another account", method: http.MethodGet, path: "/users/" + other.User_id, token: waiter.token, status: http.StatusForbidden},
+		{name: "get unknown", method: http.MethodGet, path: "/users/missing", token: admin.token, status: http.StatusNotFound},
+		{name: "create needs user:manage", method: http.MethodPost, path: "/users", as: helpers.RoleManager, body: newStaff, status: http.StatusForbidden},
+		{name: "create without a phone", method: http.MethodPost, path: "/users", token: admin.token, body: map[string]string{"first_name": "No", "last_name": "Phone", "email": "x@example.com", "Password": "password", "user_type": helpers.RoleChef}, status: http.StatusBadRequest},
+		{name: "create with a taken email", method: http.MethodPost, path: "/users", token: admin.token, body: duplicate, status: http.StatusBadRequest},
+		{name: "create", method: http.MethodPost, path: "/users", token: admin.token, body: newStaff, status: http.StatusOK, check: expectField("user_type", helpers.RoleChef)},
+		{name: "create hands out no credentials", method: http.MethodPost, path: "/users", token: admin.token, body: map[string]string{"first_name": "New", "last_name": "Host", "email": "host@example.com", "Password": "password", "phone": "555-2000", "user_type": helpers.RoleHost}, status: http.StatusOK, check: expectNoCredentials},
+		{name: "get hides the password hash", method: http.MethodGet, path: "/users/" + waiter.User_id, token: waiter.token, status: http.StatusOK, check: expectNoCredentials},
+		{name: "update own profile without the password", method: http.MethodPatch, path: "/users/" + waiter.User_id, token: waiter.token, body: map[string]string{"first_name": "Renamed"}, status: http.StatusUnauthorized},
+		{name: "update own profile", method: http.MethodPatch, path: "/users/" + waiter.User_id, token: waiter.token, body: map[string]string{"first_name": "Renamed", "current_password": testPassword}, status: http.StatusOK, check: expectField("first_name", "Renamed")},
+		{name: "update with nothing to change", method: http.MethodPatch, path: "/users/" + waiter.User_id, token: waiter.token, body: map[string]string{"current_password": testPassword}, status: http.StatusBadRequest},
+		{name: "update to a taken phone", method: http.MethodPatch, path: "/users/" + waiter.User_id, token: admin.token, body: map[string]string{"phone": *other.Phone}, status: http.StatusBadRequest},
+		{name: "update another profile", method: http.MethodPatch, path: "/users/" + other.User_id, token: waiter.token, body: map[string]string{"first_name": "Hacked"}, status: http.StatusForbidden},
+		{name: "admin updates a profile", method: http.MethodPatch, path: "/users/" + other.User_id, token: admin.token, body: map[string]string{"email": "changed@example.com"}, status: http.StatusOK, check: expectField("email_verified", false)},
+		{name: "update unknown", method: http.MethodPatch, path: "/users/missing", token: admin.token, body: map[string]string{"first_name": "Ghost"}, status: http.StatusNotFound},
+		{name: "change another password", method: http.MethodPatch, path: "/users/" + other.User_id + "/password", token: admin.token, body: map[string]string{"current_password": testPassword, "new_password": "another-password"}, status: http.StatusForbidden},
+		{name: "change password with a wrong current one", method: http.MethodPatch, path: "/users/" + waiter.User_id + "/password", token: waiter.token, body: map[string]string{"current_password": "wrong", "new_password": "another-password"}, status: http.StatusUnauthorized},
+		{name: "change password too short", method: http.MethodPatch, path: "/users/" + waiter.User_id + "/password", token: waiter.token, body: map[string]string{"current_password": testPassword, "new_password": "abc"}, status: http.StatusBadRequest},
+		{name: "change role needs user:manage", method: http.MethodPatch, path: "/users/" + promoted.User_id + "/role", as: helpers.RoleManager, body: map[string]string{"user_type": helpers.RoleManager}, status: http.StatusForbidden},
+		{name: "change own role", method: http.MethodPatch, path: "/users/" + admin.User_id + "/role", token: admin.token, body: map[string]string{"user_type": helpers.RoleUser}, status: http.StatusBadRequest},
+		{name: "change to an unknown role", method: http.MethodPatch, path: "/users/" + promoted.User_id + "/role", token: admin.token, body: map[string]string{"user_type": "OWNER"}, status: http.StatusBadRequest},
+		{name: "change role of unknown", method: http.MethodPatch, path: "/users/missing/role", token: admin.token, body: map[string]string{"user_type": helpers.RoleManager}, status: http.StatusNotFound},
+		{name: "change role", method: http.MethodPatch, path: "/users/" + promoted.User_id + "/role", token: admin.token, body: map[string]string{"user_type": helpers.RoleManager}, status: http.StatusOK},
+		{name: "old token after a role change", method: http.MethodGet, path: "/users/" + promoted.User_id, token: promoted.token, status: http.StatusUnauthorized},
+		{name: "deactivate own account", method: http.MethodDelete, path: "/users/" + admin.User_id, token: admin.token, status: http.StatusBadRequest},
+		{name: "deactivate needs user:manage", method: http.MethodDelete, path: "/users/" + leaver.User_id, as: helpers.RoleManager, status: http.StatusForbidden},
+		{name: "deactivate", method: http.MethodDelete, path: "/users/" + leaver.User_id, token: admin.token, status: http.StatusOK},
+		{name: "deactivate twice", method: http.MethodDelete, path: "/users/" + leaver.User_id, token: admin.token, status: http.StatusNotFound},
+		{name: "token of a deactivated user", method: http.MethodGet, path: "/users/" + leaver.User_id, token: leaver.token, status: http.StatusUnauthorized},
+		{name: "login of a deactivated user", method: http.MethodPost, path: "/users/login", body: map[string]string{"email": *leaver.Email, "Password": testPassword}, status: http.StatusForbidden},
+		{name: "reactivate", method: http.MethodPost, path: "/users/" + leaver.User_id + "/reactivate", token: admin.token, status: http.StatusOK},
+		{name: "reactivate an active user", method: http.MethodPost, path: "/users/" + leaver.User_id + "/reactivate", token: admin.token, status: http.StatusNotFound},
+		{name: "force logout needs user:manage", method: http.MethodPost, path: "/users/" + other.User_id + "/logout", token: waiter.token, status: http.StatusForbidden},
+		{name: "force logout unknown", method: http.MethodPost, path: "/users/missing/logout", token: admin.token, status: http.StatusNotFound},
+		{name: "force logout", method: http.MethodPost, path: "/users/" + other.User_id + "/logout", token: admin.token, status: http.StatusOK},
+		{name: "token after a force logout", method: http.MethodGet, path: "/users/" + other.User_id, token: other.token, status: http.StatusUnauthorized},
+		{name: "unlock unknown", method: http.MethodPost, path: "/users/missing/unlock", token: admin.token, status: http.StatusNotFound},
+		{name: "unlock", method: http.MethodPost, path: "/users/" + waiter.User_id + "/unlock", token: admin.token, status: http.StatusOK},
+	})
+}
+
+func TestChangePassword(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer(t)
+	user := s.seedUser(t, helpers.RoleWaiter)
+	otherDevice := s.login(t, user.User)
+	key := s.seedApiKey(t, helpers.RoleWaiter, nil)
+
+	if rec := s.request(http.MethodPost, "/users/pin", user.token, map[string]string{"password": testPassword, "pin": "4321"}); rec.Code != http.StatusOK {
+		t.Fatalf("setting the PIN = %d, want 200", rec.Code)
+	}
+
+	rec := s.request(http.MethodPost, "/terminal/pin-login", "", map[string]string{"user_id": user.User_id, "pin": "4321"}, helpers.ApiKeyHeader, key)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PIN login = %d, want 200", rec.Code)
+	}
+
+	terminal, _ := decode(t, rec)["token"].(string)
+
+	s.run(t, []routeCase{
+		{name: "change password", method: http.MethodPatch, path: "/users/" + user.User_id + "/password", token: user.token, body: map[string]string{"current_password": testPassword, "new_password": "another-password"}, status: http.StatusOK},
+		{name: "current session stays signed in", method: http.MethodGet, path: "/users/" + user.User_id, token: user.token, status: http.StatusOK},
+		{name: "other sessions are signed out", method: http.MethodGet, path: "/users/" + user.User_id, token: otherDevice.token, status: http.StatusUnauthorized},
+		{name: "login with the new password", method: http.MethodPost, path: "/users/login", body: map[string]string{"email": *user.Email, "Password": "another-password"}, status: http.StatusOK},
+	})
+
+	if rec := s.request(http.MethodGet, "/users/"+user.User_id, terminal, nil, helpers.ApiKeyHeader, key); rec.Code != http.StatusUnauthorized {
+		t.Errorf("PIN login token after the password change = %d, want 401", rec.Code)
+	}
+}
+
+func TestSignUp(t *testing.T) {
+	s := newTestServer(t)
+
+	signup := map[string]string{
+		"first_name": "Guest", "last_name": "User", "email": "guest@example.com",
+		"Password": "password", "phone": "555-1000", "user_type": helpers.RoleAdmin,
+	}
+
+	s.run(t, []routeCase{
+		{name: "signup with malformed JSON", method: http.MethodPost, path: "/users/signup", body: "{", status: http.StatusBadRequest},
+		{name: "signup without a name", method: http.MethodPost, path: "/users/signup", body: map[string]string{"email": "x@example.com", "Password": "password", "phone": "1"}, status: http.StatusBadRequest},
+		{name: "signup is always a plain user", method: http.MethodPost, path: "/users/signup", body: signup, status: http.StatusOK, check: expectField("user_type", helpers.RoleUser)},
+		{name: "signup hands out no credentials", method: http.MethodPost, path: "/users/signup", body: map[string]string{"first_name": "Second", "last_name": "Guest", "email": "guest2@example.com", "Password": "password", "phone": "555-1001"}, status: http.StatusOK, check: expectNoCredentials},
+		{name: "signup twice", method: http.MethodPost, path: "/users/signup", body: signup, status: http.StatusBadRequest},
+	})
+
+	if token := testMailer.tokenSentTo(t, "guest@example.com"); token == "" {
+		t.Error("no verification email was sent")
+	}
+
+	t.Setenv("PUBLIC_SIGNUP_ENABLED", "false")
+
+	s.run(t, []routeCase{
+		{name: "signup when disabled", method: http.MethodPost, path: "/users/signup", body: signup, status: http.StatusForbidden},
+	})
+}
+
+func TestLogin(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer(t)
+	user := s.seedUser(t, helpers.RoleWaiter)
+	locked := s.seedUser(t, helpers.RoleWaiter)
+
+	s.run(t, []routeCase{
+		{name: "login without a password", method: http.MethodPost, path: "/users/login", body: map[string]string{"email": *user.Email}, status: http.StatusBadRequest},
+		{name: "login with a wrong password", method: http.MethodPost, path: "/users/login", body: map[string]string{"email": *user.Email, "Password": "wrong"}, status: http.StatusUnauthorized},
+		{name: "login with an unknown email", method: http.MethodPost, path: "/users/login", body: map[string]string{"email": "nobody@example.com", "Password": "wrong"}, status: http.StatusUnauthorized},
+		{name: "login", method: http.MethodPost, path: "/users/login", body: map[string]string{"email": *user.Email, "Password": testPassword}, status: http.StatusOK, check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+			body := decode(t, rec)
+
+			token, _ := body["token"].(string)
+
+			if rec := s.request(http.MethodGet, "/users/"+user.User_id, token, nil); rec.Code != http.StatusOK {
+				t.Errorf("token from login = %d, want 200", rec.Code)
+			}
+		}},
+	})
+
+	for i := 0; i < 3; i++ {
+		s.request(http.MethodPost, "/users/login", "", map[string]string{"email": *locked.Email, "Password": "wrong"})
+	}
+
+	s.run(t, []routeCase{
+		{name: "login after repeated failures", method: http.MethodPost, path: "/users/login", body: map[string]string{"email": *locked.Email, "Password": testPassword}, status: http.StatusTooManyRequests},
+		{name: "unlock", method: http.MethodPost, path: "/users/" + locked.User_id + "/unlock", as: helpers.RoleAdmin, status: http.StatusOK},
+		{name: "login after unlock", method: http.MethodPost, path: "/users/login", body: map[string]string{"email": *locked.Email, "Password": testPassword}, status: http.StatusOK},
+	})
+}
+
+func TestMfaRoutes(t *testing.T) {
+	s := newTestServer(t)
+	user := s.seedUser(t, helpers.RoleManager)
+
+	var secret string
+	var recoveryCodes []interface{}
+
+	currentCode := func() string {
+		code, err := helpers.TotpCode(secret, time.Now())
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return code
+	}
+
+	s.run(t, []routeCase{
+		{name: "enroll requires a login", method: http.MethodPost, path: "/users/mfa/enroll", status: http.StatusUnauthorized},
+		{name: "activate before enrolling", method: http.MethodPost, path: "/users/mfa/activate", token: user.token, body: map[string]string{"code": "000000"}, status: http.StatusBadRequest},
+		{name: "enroll", method: http.MethodPost, path: "/users/mfa/enroll", token: user.token, status: http.StatusOK, check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+			secret, _ = decode(t, rec)["secret"].(string)
+		}},
+		{name: "activate with a wrong code", method: http.MethodPost, path: "/users/mfa/activate", token: user.token, body: map[string]string{"code": "000000"}, status: http.StatusUnauthorized},
+	})
+
+	s.run(t, []routeCase{
+		{name: "activate", method: http.MethodPost, path: "/users/mfa/activate", token: user.token, body: map[string]string{"code": currentCode()}, status: http.StatusOK, check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+			recoveryCodes, _ = decode(t, rec)["recovery_codes"].([]interface{})
+		}},
+		{name: "enroll again", method: http.MethodPost, path: "/users/mfa/enroll", token: user.token, status: http.StatusBadRequest},
+		{name: "login asks for the second factor", method: http.MethodPost, path: "/users/login", body: map[string]string{"email": *user.Email, "Password": testPassword}, status: http.StatusOK, check: expectField("mfa_required", true)},
+	})
+
+	if len(recoveryCodes) == 0 {
+		t.Fatal("no recovery codes were returned")
+	}
+
+	mfaToken, err := helpers.GenerateMfaPendingToken(user.User_id, false)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.run(t, []routeCase{
+		{name: "mfa login without a token", method: http.MethodPost, path: "/users/login/mfa", body: map[string]string{"code": "000000"}, status: http.StatusBadRequest},
+		{name: "mfa login with a forged token", method: http.MethodPost, path: "/users/login/mfa", body: map[string]string{"mfa_token": user.token, "code": "000000"}, status: http.StatusUnauthorized},
+		{name: "mfa login with a wrong code", method: http.MethodPost, path: "/users/login/mfa", body: map[string]string{"mfa_token": mfaToken, "code": "000000"}, status: http.StatusUnauthorized},
+		{name: "mfa login with a recovery code", method: http.MethodPost, path: "/users/login/mfa", body: map[string]string{"mfa_token": mfaToken, "recovery_code": recoveryCodes[0].(string)}, status: http.StatusOK, check: expectField("user_id", user.User_id)},
+		{name: "recovery codes are single-use", method: http.MethodPost, path: "/users/login/mfa", body: map[string]string{"mfa_token": mfaToken, "recovery_code": recoveryCodes[0].(string)}, status: http.StatusUnauthorized},
+		{name: "mfa tokens are single-use", method: http.MethodPost, path: "/users/login/mfa", body: map[string]string{"mfa_token": mfaToken, "recovery_code": recoveryCodes[3].(string)}, status: http.StatusUnauthorized},
+		{name: "enrolling at login without a token", method: http.MethodPost, path: "/users/login/mfa/enroll", body: map[string]string{}, status: http.StatusBadRequest},
+		{name: "enrolling at login needs an enrollment token", method: http.MethodPost, path: "/users/login/mfa/enroll", body: map[string]string{"mfa_token": mfaToken}, status: http.StatusUnauthorized},
+		{name: "disable with a wrong recovery code", method: http.MethodDelete, path: "/users/mfa", token: user.token, body: map[string]string{"current_password": testPassword, "recovery_code": "wrong"}, status: http.StatusUnauthorized},
+		{name: "disable without the password", method: http.MethodDelete, path: "/users/mfa", token: user.token, body: map[string]string{"recovery_code": recoveryCodes[1].(string)}, status: http.StatusBadRequest},
+		{name: "disable with a wrong password", method: http.MethodDelete, path: "/users/mfa", token: user.token, body: map[string]string{"current_password": "wrong-password", "recovery_code": recoveryCodes[1].(string)}, status: http.StatusUnauthorized},
+		{name: "disable", method: http.MethodDelete, path: "/users/mfa", token: user.token, body: map[string]string{"current_password": testPassword, "recovery_code": recoveryCodes[1].(string)}, status: http.StatusOK},
+		{name: "disable again", method: http.MethodDelete, path: "/users/mfa", token: user.token, body: map[string]string{"current_password": testPassword, "recovery_code": recoveryCodes[2].(string)}, status: http.StatusBadRequest},
+	})
+}
+
+func TestMfaEnrollmentAtLogin(t *testing.T) {
+	t.Setenv("MFA_REQUIRED_FOR_ADMIN", "true")
+
+	s := newTestServer(t)
+	admin := s.seedUser(t, helpers.RoleAdmin)
+
+	var mfaToken, secret string
+
+	s.run(t, []routeCase{
+		{name: "login requires enrollment", method: http.MethodPost, path: "/users/login", body: map[string]string{"email": *admin.Email, "Password": testPassword}, status: http.StatusOK, check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+			body := decode(t, rec)
+
+			if body["mfa_enrollment_required"] != true {
+				t.Errorf("enrollment was not required: %v", body)
+			}
+
+			mfaToken, _ = body["mfa_token"].(string)
+		}},
+		{name: "disable is refused", method: http.MethodDelete, path: "/users/mfa", token: admin.token, body: map[string]string{"current_password": testPassword, "code": "000000"}, status: http.StatusForbidden},
+	})
+
+	s.run(t, []routeCase{
+		{name: "enroll at login", method: http.MethodPost, path: "/users/login/mfa/enroll", body: map[string]string{"mfa_token": mfaToken}, status: http.StatusOK, check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+			secret, _ = decode(t, rec)["secret"].(string)
+		}},
+		{name: "enroll again with the same token", method: http.MethodPost, path: "/users/login/mfa/enroll", body: map[string]string{"mfa_token": mfaToken}, status: http.StatusConflict},
+	})
+
+	code, err := helpers.TotpCode(secret, time.Now())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.run(t, []routeCase{
+		{name: "first mfa login activates", method: http.MethodPost, path: "/users/login/mfa", body: map[string]string{"mfa_token": mfaToken, "code": code}, status: http.StatusOK, check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+			if codes, _ := decode(t, rec)["recovery_codes"].([]interface{}); len(codes) == 0 {
+				t.Error("no recovery codes were returned")
+			}
+		}},
+		{name: "codes cannot be replayed", method: http.MethodPost, path: "/users/login/mfa", body: map[string]string{"mfa_token": mfaToken, "code": code}, status: http.StatusUnauthorized},
+		{name: "enroll with a used token", method: http.MethodPost, path: "/users/login/mfa/enroll", body: map[string]string{"mfa_token": mfaToken}, status: http.StatusUnauthorized},
+	})
+
+	other := s.seedUser(t, helpers.RoleAdmin)
+	otherToken, err := helpers.GenerateMfaPendingToken(other.User_id, true)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.run(t, []routeCase{
+		{name: "deactivate", method: http.MethodDelete, path: "/users/" + other.User_id, token: admin.token, status: http.StatusOK},
+		{name: "enroll as a deactivated user", method: http.MethodPost, path: "/users/login/mfa/enroll", body: map[string]string{"mfa_token": otherToken}, status: http.StatusUnauthorized},
+	})
+}
+
+func TestSessionRoutes(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer(t)
+	user := s.seedUser(t, helpers.RoleWaiter)
+	phone := s.login(t, user.User)
+	tablet := s.login(t, user.User)
+	stranger := s.seedUser(t, helpers.RoleWaiter)
+
+	s.run(t, []routeCase{
+		{name: "list requires a login", method: http.MethodGet, path: "/users/sessions", status: http.StatusUnauthorized},
+		{name: "list", method: http.MethodGet, path: "/users/sessions", token: user.token, status: http.StatusOK, check: expectLength(3)},
+		{name: "revoke a session of someone else", method: http.MethodDelete, path: "/users/sessions/" + stranger.sessionId, token: user.token, status: http.StatusNotFound},
+		{name: "revoke a session", method: http.MethodDelete, path: "/users/sessions/" + phone.sessionId, token: user.token, status: http.StatusOK},
+		{name: "revoked session is signed out", method: http.MethodGet, path: "/users/sessions", token: phone.token, status: http.StatusUnauthorized},
+		{name: "revoke the other sessions", method: http.MethodDelete, path: "/users/sessions", token: user.token, status: http.StatusOK},
+		{name: "other session is signed out", method: http.MethodGet, path: "/users/sessions", token: tablet.token, status: http.StatusUnauthorized},
+		{name: "only the current session is left", method: http.MethodGet, path: "/users/sessions", token: user.token, status: http.StatusOK, check: expectLength(1)},
+		{name: "logout", method: http.MethodPost, path: "/users/logout", token: user.token, status: http.StatusOK},
+		{name: "token after logout", method: http.MethodGet, path: "/users/sessions", token: user.token, status: http.StatusUnauthorized},
+	})
+}
+
+func TestRefreshRoutes(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer(t)
+	user := s.seedUser(t, helpers.RoleWaiter)
+
+	var rotated string
+
+	s.run(t, []routeCase{
+		{name: "refresh without a token", method: http.MethodPost, path: "/users/refresh", body: map[string]string{}, status: http.StatusUnauthorized},
+		{name: "refresh with an access token", method: http.MethodPost, path: "/users/refresh", body: map[string]string{"refresh_token": user.token}, status: http.StatusUnauthorized},
+		{name: "refresh", method: http.MethodPost, path: "/users/refresh", body: map[string]string{"refresh_token": user.refreshToken}, status: http.StatusOK, check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+			rotated, _ = decode(t, rec)["refresh_token"].(string)
+		}},
+		{name: "reusing a rotated token", method: http.MethodPost, path: "/users/refresh", body: map[string]string{"refresh_token": user.refreshToken}, status: http.StatusUnauthorized},
+	})
+
+	s.run(t, []routeCase{
+		{name: "reuse revoked the session", method: http.MethodPost, path: "/users/refresh", body: map[string]string{"refresh_token": rotated}, status: http.StatusUnauthorized},
+	})
+}
+
+func TestPasswordResetRoutes(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer(t)
+	user := s.seedUser(t, helpers.RoleWaiter)
+
+	s.run(t, []routeCase{
+		{name: "request without an email", method: http.MethodPost, path: "/users/password-reset/request", body: map[string]string{}, status: http.StatusBadRequest},
+		{name: "request for an unknown email", method: http.MethodPost, path: "/users/password-reset/request", body: map[string]string{"email": "nobody@example.com"}, status: http.StatusOK},
+		{name: "request", method: http.MethodPost, path: "/users/password-reset/request", body: map[string]string{"email": *user.Email}, status: http.StatusOK},
+		{name: "confirm with a wrong token", method: http.MethodPost, path: "/users/password-reset/confirm", body: map[string]string{"token": "wrong", "password": "another-password"}, status: http.StatusBadRequest},
+	})
+
+	token := testMailer.tokenSentTo(t, *user.Email)
+
+	s.run(t, []routeCase{
+		{name: "confirm", method: http.MethodPost, path: "/users/password-reset/confirm", body: map[string]string{"token": token, "password": "another-password"}, status: http.StatusOK},
+		{name: "tokens are single-use", method: http.MethodPost, path: "/users/password-reset/confirm", body: map[string]string{"token": token, "password": "third-password"}, status: http.StatusBadRequest},
+		{name: "sessions end after a reset", method: http.MethodGet, path: "/users/sessions", token: user.token, status: http.StatusUnauthorized},
+	})
+}
+
+func TestEmailVerificationRoutes(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer(t)
+	user := s.seedUser(t, helpers.RoleUser)
+
+	s.run(t, []routeCase{
+		{name: "request requires a login", method: http.MethodPost, path: "/users/verify-email/request", status: http.StatusUnauthorized},
+		{name: "request", method: http.MethodPost, path: "/users/verify-email/request", token: user.token, status: http.StatusOK},
+		{name: "confirm with a wrong token", method: http.MethodPost, path: "/users/verify-email/confirm", body: map[string]string{"token": "wrong"}, status: http.StatusBadRequest},
+	})
+
+	token := testMailer.tokenSentTo(t, *user.Email)
+
+	s.run(t, []routeCase{
+		{name: "confirm", method: http.MethodPost, path: "/users/verify-email/confirm", body: map[string]string{"token": token}, status: http.StatusOK},
+		{name: "request once verified", method: http.MethodPost, path: "/users/verify-email/request", token: user.token, status: http.StatusBadRequest},
+	})
+
+	verified, err := s.repos.Users.Get(context.Background(), user.User_id)
+
+	if err != nil || !verified.Email_verified {
+		t.Errorf("email was not marked as verified: %v", err)
+	}
+}
+
+func TestSetPin(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer(t)
+	user := s.seedUser(t, helpers.RoleWaiter)
+
+	s.run(t, []routeCase{
+		{name: "set requires a login", method: http.MethodPost, path: "/users/pin", body: map[string]string{"password": testPassword, "pin": "1234"}, status: http.StatusUnauthorized},
+		{name: "set a non-numeric pin", method: http.MethodPost, path: "/users/pin", token: user.token, body: map[string]string{"password": testPassword, "pin": "abcd"}, status: http.StatusBadRequest},
+		{name: "set with a wrong password", method: http.MethodPost, path: "/users/pin", token: user.token, body: map[string]string{"password": "wrong", "pin": "1234"}, status: http.StatusUnauthorized},
+		{name: "set", method: http.MethodPost, path: "/users/pin", token: user.token, body: map[string]string{"password": testPassword, "pin": "1234"}, status: http.StatusOK},
+	})
+}